@@ -87,6 +87,7 @@ func AutoMigrate() error {
 		&models.TaskLog{},
 		&models.ScheduledTask{},
 		&models.TransferState{},
+		&models.FileMapping{},
 	)
 	
 	if err != nil {
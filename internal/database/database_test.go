@@ -3,7 +3,6 @@ package database
 import (
 	"os"
 	"testing"
-	"time"
 
 	"fastdfs-migration-system/internal/models"
 )
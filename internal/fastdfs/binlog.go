@@ -0,0 +1,398 @@
+package fastdfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BinlogOp binlog记录的操作类型，大写表示在本存储服务器上发生的操作，小写表示从同组其他存储服务器同步来的操作
+type BinlogOp byte
+
+// binlog操作类型，取值与storage_sync.h中的STORAGE_OP_TYPE_*一致
+const (
+	BinlogOpCreate   BinlogOp = 'C' // 上传文件
+	BinlogOpDelete   BinlogOp = 'D' // 删除文件
+	BinlogOpAppend   BinlogOp = 'A' // 追加appender文件
+	BinlogOpModify   BinlogOp = 'M' // 修改appender文件
+	BinlogOpTruncate BinlogOp = 'T' // 截断appender文件
+	BinlogOpUpdate   BinlogOp = 'U' // 设置元数据
+	BinlogOpLink     BinlogOp = 'L' // 创建指向已有文件的链接
+	BinlogOpRename   BinlogOp = 'R' // appender文件重新生成文件名（V6.02起）
+)
+
+// Source 获取对应的源操作类型，即转为大写
+func (op BinlogOp) Source() BinlogOp {
+	if op >= 'a' && op <= 'z' {
+		return op - 'a' + 'A'
+	}
+	return op
+}
+
+// IsReplica 是否为从其他存储服务器同步来的操作
+func (op BinlogOp) IsReplica() bool {
+	return op >= 'a' && op <= 'z'
+}
+
+// String 获取操作类型的字符
+func (op BinlogOp) String() string {
+	return string(rune(op))
+}
+
+// BinlogPosition binlog中的读取位置，可序列化后持久化用于断点续读
+type BinlogPosition struct {
+	File   string `json:"file"`   // binlog文件名，如binlog.003
+	Offset int64  `json:"offset"` // 文件内的字节偏移
+}
+
+// BinlogRecord 一条binlog记录，格式为"<timestamp> <op> <filename> [extra...]"
+type BinlogRecord struct {
+	Timestamp int64
+	Op        BinlogOp
+	FileName  string         // 组内文件名，如M00/00/00/xxx.jpg
+	Extra     []string       // 操作的附加字段，如追加的偏移和长度、链接的源文件名
+	Next      BinlogPosition // 这条记录之后的位置，从这里续读不会重复读到这条记录
+}
+
+// parseBinlogRecord 解析一行binlog
+func parseBinlogRecord(line string) (*BinlogRecord, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || len(fields[1]) != 1 {
+		return nil, fmt.Errorf("invalid binlog record: %q", line)
+	}
+
+	timestamp, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binlog timestamp: %q", line)
+	}
+
+	op := BinlogOp(fields[1][0])
+	switch op.Source() {
+	case BinlogOpCreate, BinlogOpDelete, BinlogOpAppend, BinlogOpModify,
+		BinlogOpTruncate, BinlogOpUpdate, BinlogOpLink, BinlogOpRename:
+	default:
+		return nil, fmt.Errorf("unknown binlog operation %q: %q", fields[1], line)
+	}
+
+	return &BinlogRecord{
+		Timestamp: timestamp,
+		Op:        op,
+		FileName:  fields[2],
+		Extra:     fields[3:],
+	}, nil
+}
+
+// BinlogFiles 列出存储服务器sync目录中的binlog文件，按序号排序
+// binlog.index、压缩后的binlog等其他文件被忽略
+func BinlogFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binlog directory: %w", err)
+	}
+
+	type binlogFile struct {
+		path  string
+		index int
+	}
+	var files []binlogFile
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), "binlog.")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		index, err := strconv.Atoi(suffix)
+		if err != nil {
+			continue
+		}
+		files = append(files, binlogFile{path: filepath.Join(dir, entry.Name()), index: index})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].index < files[j].index })
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.path
+	}
+	return paths, nil
+}
+
+// BinlogReader 按顺序读取多个binlog文件中的记录
+type BinlogReader struct {
+	paths  []string
+	index  int // 当前读取的文件在paths中的位置
+	file   *os.File
+	reader *bufio.Reader
+	offset int64
+}
+
+// NewBinlogReader 从from开始读取paths中的binlog，from为零值时从第一个文件开头读取
+// paths应按写入顺序排列，可以由BinlogFiles获取
+func NewBinlogReader(paths []string, from BinlogPosition) (*BinlogReader, error) {
+	r := &BinlogReader{paths: paths}
+	if from.File == "" {
+		return r, nil
+	}
+
+	r.index = -1
+	for i, path := range paths {
+		if filepath.Base(path) == from.File {
+			r.index = i
+			break
+		}
+	}
+	if r.index < 0 {
+		return nil, fmt.Errorf("binlog %s not found", from.File)
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	if _, err := r.file.Seek(from.Offset, io.SeekStart); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to seek binlog %s: %w", from.File, err)
+	}
+	r.offset = from.Offset
+	return r, nil
+}
+
+// Next 读取下一条记录，所有文件都读完时返回io.EOF
+// 文件末尾没有换行符的记录可能仍在写入，不会被读取
+func (r *BinlogReader) Next() (*BinlogRecord, error) {
+	for r.index < len(r.paths) {
+		if r.file == nil {
+			if err := r.open(); err != nil {
+				return nil, err
+			}
+		}
+
+		line, err := r.reader.ReadString('\n')
+		if err == nil {
+			start := r.offset
+			r.offset += int64(len(line))
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			record, err := parseBinlogRecord(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s at offset %d: %w", filepath.Base(r.paths[r.index]), start, err)
+			}
+			record.Next = r.Position()
+			return record, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read binlog %s: %w", r.paths[r.index], err)
+		}
+
+		// 最后一个文件仍可能被追加，停在不完整的记录之前
+		if r.index == len(r.paths)-1 {
+			if len(line) > 0 {
+				r.file.Seek(r.offset, io.SeekStart)
+				r.reader.Reset(r.file)
+			}
+			return nil, io.EOF
+		}
+
+		r.file.Close()
+		r.file = nil
+		r.index++
+		r.offset = 0
+	}
+	return nil, io.EOF
+}
+
+// Position 获取当前的读取位置
+func (r *BinlogReader) Position() BinlogPosition {
+	if len(r.paths) == 0 {
+		return BinlogPosition{}
+	}
+	index := r.index
+	if index >= len(r.paths) {
+		index = len(r.paths) - 1
+	}
+	return BinlogPosition{File: filepath.Base(r.paths[index]), Offset: r.offset}
+}
+
+// Close 关闭当前打开的binlog文件
+func (r *BinlogReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// open 打开当前位置的binlog文件
+func (r *BinlogReader) open() error {
+	file, err := os.Open(r.paths[r.index])
+	if err != nil {
+		return fmt.Errorf("failed to open binlog: %w", err)
+	}
+	r.file = file
+	r.reader = bufio.NewReader(file)
+	return nil
+}
+
+// LoadBinlogPosition 读取持久化的binlog位置，文件不存在时返回零值，表示从头读取
+func LoadBinlogPosition(path string) (BinlogPosition, error) {
+	var position BinlogPosition
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return position, nil
+	}
+	if err != nil {
+		return position, fmt.Errorf("failed to read binlog position: %w", err)
+	}
+	if err := json.Unmarshal(data, &position); err != nil {
+		return position, fmt.Errorf("invalid binlog position file %s: %w", path, err)
+	}
+	return position, nil
+}
+
+// SaveBinlogPosition 持久化binlog位置，先写临时文件再重命名，避免中途失败留下不完整的文件
+func SaveBinlogPosition(path string, position BinlogPosition) error {
+	data, err := json.Marshal(position)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write binlog position: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write binlog position: %w", err)
+	}
+	return nil
+}
+
+// BinlogEnumerator 根据binlog枚举组内的文件，实现FileEnumerator
+// 上传、链接和重新生成文件名的记录产生文件，删除、追加等记录不产生文件；
+// 枚举后才被删除的文件在迁移时会返回ErrFileNotFound
+type BinlogEnumerator struct {
+	groupName    string
+	reader       *BinlogReader
+	positionPath string
+	sourceOnly   bool
+}
+
+// BinlogEnumeratorConfig binlog枚举配置
+type BinlogEnumeratorConfig struct {
+	GroupName    string   // binlog所属存储服务器的组名，binlog中的文件名不含组名
+	Dir          string   // 存储服务器的data/sync目录，与Files二选一
+	Files        []string // 按顺序排列的binlog文件
+	PositionFile string   // 持久化读取位置的文件，为空时总是从头读取
+	// SourceOnly 只枚举在该存储服务器上发生的操作；同时读取组内多个存储服务器的binlog时设为true以避免重复
+	SourceOnly bool
+}
+
+// NewBinlogEnumerator 创建binlog枚举器，设置了PositionFile时从上次保存的位置继续
+func NewBinlogEnumerator(config BinlogEnumeratorConfig) (*BinlogEnumerator, error) {
+	if config.GroupName == "" {
+		return nil, fmt.Errorf("group name is required")
+	}
+
+	paths := config.Files
+	if config.Dir != "" {
+		var err error
+		paths, err = BinlogFiles(config.Dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no binlog files")
+	}
+
+	var from BinlogPosition
+	if config.PositionFile != "" {
+		var err error
+		from, err = LoadBinlogPosition(config.PositionFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reader, err := NewBinlogReader(paths, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinlogEnumerator{
+		groupName:    config.GroupName,
+		reader:       reader,
+		positionPath: config.PositionFile,
+		sourceOnly:   config.SourceOnly,
+	}, nil
+}
+
+// Next 获取下一个文件，没有更多文件时返回io.EOF
+func (e *BinlogEnumerator) Next(ctx context.Context) (*FileInfo, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		record, err := e.reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e.sourceOnly && record.Op.IsReplica() {
+			continue
+		}
+		// 第一次设置元数据时存储服务器为元数据文件写入创建记录，元数据文件不是需要迁移的数据文件
+		if strings.HasSuffix(record.FileName, FDFS_STORAGE_META_FILE_EXT) {
+			continue
+		}
+
+		// 链接和重新生成文件名的记录中，第一个文件名是新产生的文件
+		switch record.Op.Source() {
+		case BinlogOpCreate, BinlogOpLink, BinlogOpRename:
+			return e.fileInfo(record), nil
+		}
+	}
+}
+
+// Position 获取已枚举到的位置
+func (e *BinlogEnumerator) Position() BinlogPosition {
+	return e.reader.Position()
+}
+
+// Checkpoint 将当前位置保存到PositionFile，调用方应在已枚举的文件处理完成后调用
+func (e *BinlogEnumerator) Checkpoint() error {
+	if e.positionPath == "" {
+		return nil
+	}
+	return SaveBinlogPosition(e.positionPath, e.reader.Position())
+}
+
+// Close 关闭binlog文件
+func (e *BinlogEnumerator) Close() error {
+	return e.reader.Close()
+}
+
+// fileInfo 由binlog记录生成文件信息，文件名可以解码时带上其中的大小、CRC32和源存储服务器
+func (e *BinlogEnumerator) fileInfo(record *BinlogRecord) *FileInfo {
+	fileID := e.groupName + "/" + record.FileName
+	if info, err := DecodeFileID(fileID); err == nil {
+		fileInfo := info.ToFileInfo()
+		if fileInfo.CreateTime == 0 {
+			fileInfo.CreateTime = record.Timestamp
+		}
+		return fileInfo
+	}
+
+	return &FileInfo{
+		GroupName:  e.groupName,
+		FileName:   record.FileName,
+		CreateTime: record.Timestamp,
+	}
+}
@@ -0,0 +1,175 @@
+package fastdfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestBinlog 在dir中写入binlog文件
+func writeTestBinlog(t *testing.T, dir string, name string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "")), 0644); err != nil {
+		t.Fatalf("Failed to write binlog: %v", err)
+	}
+}
+
+func TestBinlogReader(t *testing.T) {
+	dir := t.TempDir()
+	writeTestBinlog(t, dir, "binlog.000",
+		"1640995200 C M00/00/00/a.jpg\n",
+		"1640995201 a M00/00/00/b.log 0 128\n",
+		"\n",
+	)
+	writeTestBinlog(t, dir, "binlog.001",
+		"1640995202 D M00/00/00/a.jpg\n",
+		"1640995203 C M00/00/00/partial",
+	)
+	writeTestBinlog(t, dir, "binlog.index", "current_write=1\n")
+
+	paths, err := BinlogFiles(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[1]) != "binlog.001" {
+		t.Fatalf("Unexpected binlog files: %v", paths)
+	}
+
+	reader, err := NewBinlogReader(paths, BinlogPosition{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	var records []*BinlogRecord
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected read error: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 complete records, got %d", len(records))
+	}
+	appendRecord := records[1]
+	if appendRecord.Op.Source() != BinlogOpAppend || !appendRecord.Op.IsReplica() || appendRecord.Timestamp != 1640995201 ||
+		len(appendRecord.Extra) != 2 || appendRecord.Extra[1] != "128" {
+		t.Errorf("Unexpected append record: %+v", appendRecord)
+	}
+	if want := (BinlogPosition{File: "binlog.001", Offset: 29}); records[2].Next != want || reader.Position() != want {
+		t.Errorf("Expected position %+v, got %+v and %+v", want, records[2].Next, reader.Position())
+	}
+
+	// 不完整的记录写完后可以继续读取
+	f, _ := os.OpenFile(paths[1], os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(".jpg\n")
+	f.Close()
+	record, err := reader.Next()
+	if err != nil || record.FileName != "M00/00/00/partial.jpg" {
+		t.Errorf("Expected completed record, got %+v (%v)", record, err)
+	}
+}
+
+func TestBinlogReader_InvalidRecord(t *testing.T) {
+	dir := t.TempDir()
+	writeTestBinlog(t, dir, "binlog.000", "1640995200 C M00/00/00/a.jpg\n", "garbage\n")
+
+	reader, err := NewBinlogReader([]string{filepath.Join(dir, "binlog.000")}, BinlogPosition{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := reader.Next(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := reader.Next(); err == nil || !strings.Contains(err.Error(), "binlog.000 at offset 29") {
+		t.Errorf("Expected error with position, got %v", err)
+	}
+
+	if _, err := NewBinlogReader([]string{filepath.Join(dir, "binlog.000")}, BinlogPosition{File: "binlog.009"}); err == nil {
+		t.Error("Expected error for unknown binlog file")
+	}
+}
+
+func TestBinlogEnumerator_Resume(t *testing.T) {
+	dir := t.TempDir()
+	first := encodeTestFileName([4]byte{10, 0, 0, 1}, 1640995200, 1024, 0x12345678, "jpg")
+	second := encodeTestFileName([4]byte{10, 0, 0, 2}, 1640995300, 2048, 0x9ABCDEF0, "png")
+	writeTestBinlog(t, dir, "binlog.000",
+		"1640995200 C "+first+"\n",
+		"1640995250 U "+first+"\n",
+		"1640995260 C "+first+FDFS_STORAGE_META_FILE_EXT+"\n",
+		"1640995300 c "+second+"\n",
+		"1640995400 C M00/00/00/not-decodable.txt\n",
+	)
+	positionFile := filepath.Join(t.TempDir(), "position.json")
+
+	config := BinlogEnumeratorConfig{GroupName: "group1", Dir: dir, PositionFile: positionFile}
+	enumerator, err := NewBinlogEnumerator(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	file, err := enumerator.Next(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file.GroupName != "group1" || file.FileName != first || file.FileSize != 1024 || file.SourceIPAddr != "10.0.0.1" {
+		t.Errorf("Unexpected file: %+v", file)
+	}
+	if err := enumerator.Checkpoint(); err != nil {
+		t.Fatalf("Unexpected checkpoint error: %v", err)
+	}
+	enumerator.Close()
+
+	// 从保存的位置继续，元数据更新记录和元数据文件的创建记录不产生文件
+	enumerator, err = NewBinlogEnumerator(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer enumerator.Close()
+
+	file, err = enumerator.Next(ctx)
+	if err != nil || file.FileName != second {
+		t.Fatalf("Expected %s after resume, got %+v (%v)", second, file, err)
+	}
+	file, err = enumerator.Next(ctx)
+	if err != nil || file.FileName != "M00/00/00/not-decodable.txt" || file.CreateTime != 1640995400 {
+		t.Errorf("Expected binlog timestamp for undecodable name, got %+v (%v)", file, err)
+	}
+	if _, err := enumerator.Next(ctx); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	// 只枚举源操作时跳过同步来的记录
+	sourceOnly, err := NewBinlogEnumerator(BinlogEnumeratorConfig{GroupName: "group1", Dir: dir, SourceOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer sourceOnly.Close()
+	count := 0
+	for {
+		_, err := sourceOnly.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 source files, got %d", count)
+	}
+}
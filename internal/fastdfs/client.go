@@ -1,20 +1,29 @@
 package fastdfs
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// streamBufferSize 流式传输时单次读写的缓冲区大小
+const streamBufferSize = 64 * 1024
+
 // Client FastDFS客户端
 type Client struct {
 	trackerAddr string
 	trackerPort int
 	timeout     time.Duration
 	conn        net.Conn
+	ctx         context.Context // 当前操作绑定的context，由withContext设置
+	storagePool *StoragePool    // 存储服务器连接池，为nil时每次操作都新建存储服务器连接
+	dialect     *Dialect        // 集群版本对应的协议方言，为nil时不限制版本相关的命令
 }
 
 // NewClient 创建新的FastDFS客户端
@@ -28,15 +37,26 @@ func NewClient(trackerAddr string, trackerPort int) *Client {
 
 // Connect 连接到tracker服务器
 func (c *Client) Connect() error {
-	addr := fmt.Sprintf("%s:%d", c.trackerAddr, c.trackerPort)
-	conn, err := net.DialTimeout("tcp", addr, c.timeout)
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext 在ctx的控制下连接到服务器，客户端既用于tracker也用于存储服务器
+func (c *Client) ConnectContext(ctx context.Context) error {
+	addr := c.addr()
+	dialer := &net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		return fmt.Errorf("failed to connect to tracker %s: %w", addr, err)
+		return fmt.Errorf("failed to connect to %s: %w", addr, newNetworkError("dial", addr, err))
 	}
 	c.conn = conn
 	return nil
 }
 
+// addr 获取所连接服务器的地址
+func (c *Client) addr() string {
+	return net.JoinHostPort(c.trackerAddr, strconv.Itoa(c.trackerPort))
+}
+
 // Close 关闭连接
 func (c *Client) Close() error {
 	if c.conn != nil {
@@ -45,6 +65,29 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// Dialect 获取客户端使用的协议方言，未指定时返回nil
+func (c *Client) Dialect() *Dialect {
+	return c.dialect
+}
+
+// SetDialect 指定客户端使用的协议方言
+func (c *Client) SetDialect(dialect *Dialect) {
+	c.dialect = dialect
+}
+
+// VerifyVersion 检查tracker上报的存储服务器版本是否与客户端的协议方言一致，未指定方言时不检查
+func (c *Client) VerifyVersion() error {
+	if c.dialect == nil {
+		return nil
+	}
+	
+	groups, err := c.GetTopology()
+	if err != nil {
+		return fmt.Errorf("failed to get cluster topology: %w", err)
+	}
+	return c.dialect.CheckStorageVersions(groups)
+}
+
 // IsConnected 检查连接状态
 func (c *Client) IsConnected() bool {
 	return c.conn != nil
@@ -52,187 +95,514 @@ func (c *Client) IsConnected() bool {
 
 // Ping 测试连接
 func (c *Client) Ping() error {
-	if !c.IsConnected() {
-		return fmt.Errorf("client not connected")
+	return c.PingContext(context.Background())
+}
+
+// PingContext 在ctx的控制下测试连接
+func (c *Client) PingContext(ctx context.Context) error {
+	_, err := c.trackerRequest(ctx, FDFS_PROTO_CMD_ACTIVE_TEST, "ping", nil)
+	return err
+}
+
+// GetStorageServer 获取用于上传文件的存储服务器信息
+func (c *Client) GetStorageServer(groupName string) (*StorageServer, error) {
+	return c.GetStorageServerContext(context.Background(), groupName)
+}
+
+// GetStorageServerContext 在ctx的控制下获取用于上传文件的存储服务器信息
+func (c *Client) GetStorageServerContext(ctx context.Context, groupName string) (*StorageServer, error) {
+	// 构建请求数据，未指定组名时由tracker选择组
+	var data []byte
+	var command byte = TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITHOUT_GROUP_ONE
+	if groupName != "" {
+		data = make([]byte, FDFS_GROUP_NAME_MAX_LEN)
+		copy(data, []byte(groupName))
+		command = TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITH_GROUP_ONE
+	}
+	
+	respData, err := c.trackerRequest(ctx, command, "get storage", data)
+	if err != nil {
+		return nil, err
 	}
 	
-	// 发送ping命令
-	header := &Header{
-		Length: 0,
-		Command: FDFS_PROTO_CMD_ACTIVE_TEST,
-		Status: 0,
+	if len(respData) < TRACKER_QUERY_STORAGE_STORE_BODY_LEN {
+		return nil, fmt.Errorf("invalid response length: %d", len(respData))
 	}
 	
-	err := c.sendHeader(header)
+	return parseStorageServer(respData)
+}
+
+// ListFiles 列出指定组的文件
+func (c *Client) ListFiles(groupName string, startFileName string, limit int) ([]*FileInfo, error) {
+	storageServer, err := c.GetStorageServer(groupName)
 	if err != nil {
-		return fmt.Errorf("failed to send ping: %w", err)
+		return nil, fmt.Errorf("failed to get storage server: %w", err)
 	}
 	
-	// 接收响应
-	respHeader, err := c.receiveHeader()
+	var files []*FileInfo
+	err = c.onStorage(context.Background(), storageServer, func(storageClient *Client) error {
+		files, err = storageClient.listFilesFromStorage(groupName, startFileName, limit)
+		return err
+	})
+	return files, err
+}
+
+// DownloadFile 下载文件
+func (c *Client) DownloadFile(fileID string) ([]byte, error) {
+	return c.DownloadFileContext(context.Background(), fileID)
+}
+
+// DownloadFileContext 在ctx的控制下下载文件
+func (c *Client) DownloadFileContext(ctx context.Context, fileID string) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.DownloadTo(ctx, fileID, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DownloadTo 以流的方式下载文件并写入w，返回写入的字节数
+func (c *Client) DownloadTo(ctx context.Context, fileID string, w io.Writer) (int64, error) {
+	return c.DownloadRangeTo(ctx, fileID, 0, 0, w)
+}
+
+// DownloadRange 下载文件从offset开始的length个字节，length为0表示下载到文件末尾
+func (c *Client) DownloadRange(fileID string, offset int64, length int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.DownloadRangeTo(context.Background(), fileID, offset, length, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DownloadRangeTo 以流的方式下载文件的指定区间并写入w，返回写入的字节数
+func (c *Client) DownloadRangeTo(ctx context.Context, fileID string, offset int64, length int64, w io.Writer) (int64, error) {
+	if offset < 0 || length < 0 {
+		return 0, fmt.Errorf("invalid download range: offset %d, length %d", offset, length)
+	}
+	
+	groupName, fileName, err := parseFileID(fileID)
 	if err != nil {
-		return fmt.Errorf("failed to receive ping response: %w", err)
+		return 0, fmt.Errorf("invalid file ID: %w", err)
 	}
 	
-	if respHeader.Status != 0 {
-		return fmt.Errorf("ping failed with status: %d", respHeader.Status)
+	storageServers, err := c.queryStorageByFile(ctx, TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL, groupName, fileName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get storage server: %w", err)
 	}
 	
-	return nil
+	// 依次尝试所有副本，已有数据写入w后不能再切换副本
+	var lastErr error
+	for _, storageServer := range storageServers {
+		written, err := c.downloadFromServer(ctx, storageServer, groupName, fileName, offset, length, w)
+		if err == nil {
+			return written, nil
+		}
+		if written > 0 || ctx.Err() != nil {
+			return written, err
+		}
+		lastErr = err
+	}
+	
+	return 0, fmt.Errorf("download failed on all %d storage servers: %w", len(storageServers), lastErr)
 }
 
-// GetStorageServer 获取存储服务器信息
-func (c *Client) GetStorageServer(groupName string) (*StorageServer, error) {
-	if !c.IsConnected() {
-		return nil, fmt.Errorf("client not connected")
+// downloadFromServer 从指定的存储服务器下载文件区间
+func (c *Client) downloadFromServer(ctx context.Context, storageServer *StorageServer, groupName string, fileName string, offset int64, length int64, w io.Writer) (int64, error) {
+	var written int64
+	err := c.onStorage(ctx, storageServer, func(storageClient *Client) error {
+		var err error
+		written, err = storageClient.downloadFromStorageTo(ctx, groupName, fileName, offset, length, w)
+		return err
+	})
+	return written, err
+}
+
+// UploadFile 上传文件
+func (c *Client) UploadFile(groupName string, fileName string, data []byte) (string, error) {
+	return c.UploadFileContext(context.Background(), groupName, fileName, data)
+}
+
+// UploadFileContext 在ctx的控制下上传文件
+func (c *Client) UploadFileContext(ctx context.Context, groupName string, fileName string, data []byte) (string, error) {
+	return c.UploadFrom(ctx, groupName, getFileExtension(fileName), int64(len(data)), bytes.NewReader(data))
+}
+
+// UploadFrom 以流的方式上传文件，size为r中待上传的字节数
+func (c *Client) UploadFrom(ctx context.Context, groupName string, extName string, size int64, r io.Reader) (string, error) {
+	if size < 0 {
+		return "", fmt.Errorf("invalid upload size: %d", size)
 	}
 	
-	// 构建请求数据
-	data := make([]byte, FDFS_GROUP_NAME_MAX_LEN)
-	copy(data, []byte(groupName))
-	
-	header := &Header{
-		Length: int64(len(data)),
-		Command: TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITHOUT_GROUP_ONE,
-		Status: 0,
+	return c.uploadFrom(ctx, STORAGE_PROTO_CMD_UPLOAD_FILE, groupName, extName, size, r)
+}
+
+// DeleteFile 删除文件
+func (c *Client) DeleteFile(fileID string) error {
+	return c.DeleteFileContext(context.Background(), fileID)
+}
+
+// DeleteFileContext 在ctx的控制下删除文件
+func (c *Client) DeleteFileContext(ctx context.Context, fileID string) error {
+	groupName, fileName, err := parseFileID(fileID)
+	if err != nil {
+		return fmt.Errorf("invalid file ID: %w", err)
 	}
 	
-	err := c.sendHeader(header)
+	// 删除属于更新操作，必须发往源存储服务器
+	return c.updateOnStorage(ctx, groupName, fileName, func(storageClient *Client) error {
+		return storageClient.deleteFromStorage(groupName, fileName)
+	})
+}
+
+// GetFileInfo 获取文件信息
+func (c *Client) GetFileInfo(fileID string) (*FileInfo, error) {
+	return c.GetFileInfoContext(context.Background(), fileID)
+}
+
+// GetFileInfoContext 在ctx的控制下获取文件信息
+func (c *Client) GetFileInfoContext(ctx context.Context, fileID string) (*FileInfo, error) {
+	groupName, fileName, err := parseFileID(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send get storage request: %w", err)
+		return nil, fmt.Errorf("invalid file ID: %w", err)
 	}
 	
-	err = c.sendData(data)
+	var fileInfo *FileInfo
+	err = c.readFromReplicas(ctx, groupName, fileName, func(storageClient *Client) error {
+		info, err := storageClient.getFileInfoFromStorage(groupName, fileName)
+		if err != nil {
+			return err
+		}
+		fileInfo = info
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to send get storage data: %w", err)
+		return nil, err
 	}
 	
-	// 接收响应
-	respHeader, err := c.receiveHeader()
+	return fileInfo, nil
+}
+
+// GetMetadata 获取文件的元数据
+func (c *Client) GetMetadata(fileID string) (map[string]string, error) {
+	return c.GetMetadataContext(context.Background(), fileID)
+}
+
+// GetMetadataContext 在ctx的控制下获取文件的元数据
+func (c *Client) GetMetadataContext(ctx context.Context, fileID string) (map[string]string, error) {
+	groupName, fileName, err := parseFileID(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to receive get storage response: %w", err)
+		return nil, fmt.Errorf("invalid file ID: %w", err)
 	}
 	
-	if respHeader.Status != 0 {
-		return nil, fmt.Errorf("get storage failed with status: %d", respHeader.Status)
+	var metadata map[string]string
+	err = c.readFromReplicas(ctx, groupName, fileName, func(storageClient *Client) error {
+		meta, err := storageClient.getMetadataFromStorage(groupName, fileName)
+		if err != nil {
+			return err
+		}
+		metadata = meta
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	
-	if respHeader.Length < TRACKER_QUERY_STORAGE_STORE_BODY_LEN {
-		return nil, fmt.Errorf("invalid response length: %d", respHeader.Length)
+	return metadata, nil
+}
+
+// SetMetadata 设置文件的元数据，flag指定覆盖或合并原有元数据
+func (c *Client) SetMetadata(fileID string, metadata map[string]string, flag MetadataFlag) error {
+	return c.SetMetadataContext(context.Background(), fileID, metadata, flag)
+}
+
+// SetMetadataContext 在ctx的控制下设置文件的元数据
+func (c *Client) SetMetadataContext(ctx context.Context, fileID string, metadata map[string]string, flag MetadataFlag) error {
+	if flag != MetadataOverwrite && flag != MetadataMerge {
+		return fmt.Errorf("invalid metadata flag: %c", flag)
 	}
 	
-	respData := make([]byte, respHeader.Length)
-	err = c.receiveData(respData)
+	groupName, fileName, err := parseFileID(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to receive storage server data: %w", err)
+		return fmt.Errorf("invalid file ID: %w", err)
 	}
 	
-	return parseStorageServer(respData)
+	return c.updateOnStorage(ctx, groupName, fileName, func(storageClient *Client) error {
+		return storageClient.setMetadataToStorage(groupName, fileName, metadata, flag)
+	})
 }
 
-// ListFiles 列出指定组的文件
-func (c *Client) ListFiles(groupName string, startFileName string, limit int) ([]*FileInfo, error) {
-	storageServer, err := c.GetStorageServer(groupName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get storage server: %w", err)
+// UploadAppenderFile 上传appender文件，上传后可以继续追加、修改和截断
+func (c *Client) UploadAppenderFile(groupName string, fileName string, data []byte) (string, error) {
+	return c.UploadAppenderFrom(context.Background(), groupName, getFileExtension(fileName), int64(len(data)), bytes.NewReader(data))
+}
+
+// UploadAppenderFrom 以流的方式上传appender文件
+func (c *Client) UploadAppenderFrom(ctx context.Context, groupName string, extName string, size int64, r io.Reader) (string, error) {
+	if size < 0 {
+		return "", fmt.Errorf("invalid upload size: %d", size)
 	}
 	
-	// 连接到存储服务器
-	storageClient := NewClient(storageServer.IPAddr, storageServer.Port)
-	err = storageClient.Connect()
+	return c.uploadFrom(ctx, STORAGE_PROTO_CMD_UPLOAD_APPENDER_FILE, groupName, extName, size, r)
+}
+
+// UploadSlaveFile 上传master文件的slave文件（如缩略图），slave文件名由master文件名加prefix构成
+func (c *Client) UploadSlaveFile(masterFileID string, prefix string, extName string, data []byte) (string, error) {
+	return c.UploadSlaveFrom(context.Background(), masterFileID, prefix, extName, int64(len(data)), bytes.NewReader(data))
+}
+
+// UploadSlaveFrom 以流的方式上传master文件的slave文件
+func (c *Client) UploadSlaveFrom(ctx context.Context, masterFileID string, prefix string, extName string, size int64, r io.Reader) (string, error) {
+	if size < 0 {
+		return "", fmt.Errorf("invalid upload size: %d", size)
+	}
+	
+	if prefix == "" || len(prefix) > FDFS_FILE_PREFIX_MAX_LEN {
+		return "", fmt.Errorf("invalid slave prefix: %q", prefix)
+	}
+	
+	groupName, masterFileName, err := parseFileID(masterFileID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to storage server: %w", err)
+		return "", fmt.Errorf("invalid master file ID: %w", err)
+	}
+	
+	// slave文件必须上传到master文件所在的存储服务器
+	var slaveFileID string
+	err = c.updateOnStorage(ctx, groupName, masterFileName, func(storageClient *Client) error {
+		fileID, err := storageClient.uploadSlaveToStorageFrom(ctx, masterFileName, prefix, extName, size, r)
+		if err != nil {
+			return err
+		}
+		slaveFileID = fileID
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
-	defer storageClient.Close()
 	
-	return storageClient.listFilesFromStorage(groupName, startFileName, limit)
+	return slaveFileID, nil
 }
 
-// DownloadFile 下载文件
-func (c *Client) DownloadFile(fileID string) ([]byte, error) {
-	groupName, fileName, err := parseFileID(fileID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid file ID: %w", err)
+// AppendFile 向appender文件末尾追加数据
+func (c *Client) AppendFile(appenderFileID string, data []byte) error {
+	return c.AppendFrom(context.Background(), appenderFileID, int64(len(data)), bytes.NewReader(data))
+}
+
+// AppendFrom 以流的方式向appender文件末尾追加size字节
+func (c *Client) AppendFrom(ctx context.Context, appenderFileID string, size int64, r io.Reader) error {
+	if size < 0 {
+		return fmt.Errorf("invalid append size: %d", size)
 	}
 	
-	storageServer, err := c.GetStorageServer(groupName)
+	groupName, fileName, err := parseFileID(appenderFileID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get storage server: %w", err)
+		return fmt.Errorf("invalid file ID: %w", err)
 	}
 	
-	// 连接到存储服务器
-	storageClient := NewClient(storageServer.IPAddr, storageServer.Port)
-	err = storageClient.Connect()
+	return c.updateOnStorage(ctx, groupName, fileName, func(storageClient *Client) error {
+		return storageClient.appendToStorageFrom(ctx, fileName, size, r)
+	})
+}
+
+// ModifyFile 从offset开始覆盖appender文件的内容
+func (c *Client) ModifyFile(appenderFileID string, offset int64, data []byte) error {
+	return c.ModifyFrom(context.Background(), appenderFileID, offset, int64(len(data)), bytes.NewReader(data))
+}
+
+// ModifyFrom 以流的方式从offset开始覆盖appender文件的size字节
+func (c *Client) ModifyFrom(ctx context.Context, appenderFileID string, offset int64, size int64, r io.Reader) error {
+	if offset < 0 || size < 0 {
+		return fmt.Errorf("invalid modify range: offset %d, size %d", offset, size)
+	}
+	
+	groupName, fileName, err := parseFileID(appenderFileID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to storage server: %w", err)
+		return fmt.Errorf("invalid file ID: %w", err)
 	}
-	defer storageClient.Close()
 	
-	return storageClient.downloadFromStorage(groupName, fileName)
+	return c.updateOnStorage(ctx, groupName, fileName, func(storageClient *Client) error {
+		return storageClient.modifyToStorageFrom(ctx, fileName, offset, size, r)
+	})
 }
 
-// UploadFile 上传文件
-func (c *Client) UploadFile(groupName string, fileName string, data []byte) (string, error) {
-	storageServer, err := c.GetStorageServer(groupName)
-	if err != nil {
-		return "", fmt.Errorf("failed to get storage server: %w", err)
+// TruncateFile 将appender文件截断为truncatedSize字节
+func (c *Client) TruncateFile(appenderFileID string, truncatedSize int64) error {
+	return c.TruncateFileContext(context.Background(), appenderFileID, truncatedSize)
+}
+
+// TruncateFileContext 在ctx的控制下截断appender文件
+func (c *Client) TruncateFileContext(ctx context.Context, appenderFileID string, truncatedSize int64) error {
+	if truncatedSize < 0 {
+		return fmt.Errorf("invalid truncated size: %d", truncatedSize)
 	}
 	
-	// 连接到存储服务器
-	storageClient := NewClient(storageServer.IPAddr, storageServer.Port)
-	err = storageClient.Connect()
+	groupName, fileName, err := parseFileID(appenderFileID)
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to storage server: %w", err)
+		return fmt.Errorf("invalid file ID: %w", err)
 	}
-	defer storageClient.Close()
 	
-	return storageClient.uploadToStorage(groupName, fileName, data)
+	return c.updateOnStorage(ctx, groupName, fileName, func(storageClient *Client) error {
+		return storageClient.truncateOnStorage(ctx, fileName, truncatedSize)
+	})
 }
 
-// DeleteFile 删除文件
-func (c *Client) DeleteFile(fileID string) error {
-	groupName, fileName, err := parseFileID(fileID)
+// RegenerateAppenderFileName 将appender文件转为普通文件并重新生成文件名，返回新的文件ID
+// 需要FastDFS V6.02及以上版本，转换后文件不能再追加内容
+func (c *Client) RegenerateAppenderFileName(appenderFileID string) (string, error) {
+	return c.RegenerateAppenderFileNameContext(context.Background(), appenderFileID)
+}
+
+// RegenerateAppenderFileNameContext 在ctx的控制下重新生成appender文件的文件名
+func (c *Client) RegenerateAppenderFileNameContext(ctx context.Context, appenderFileID string) (string, error) {
+	if c.dialect != nil && !c.dialect.Supports(STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME) {
+		return "", c.dialect.unsupportedError("regenerate appender filename", STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME)
+	}
+	
+	groupName, fileName, err := parseFileID(appenderFileID)
 	if err != nil {
-		return fmt.Errorf("invalid file ID: %w", err)
+		return "", fmt.Errorf("invalid file ID: %w", err)
 	}
 	
-	storageServer, err := c.GetStorageServer(groupName)
+	var fileID string
+	err = c.updateOnStorage(ctx, groupName, fileName, func(storageClient *Client) error {
+		fileID, err = storageClient.regenerateAppenderFileNameOnStorage(fileName)
+		return err
+	})
+	return fileID, err
+}
+
+// uploadFrom 向tracker分配的存储服务器上传文件，command区分普通文件和appender文件
+func (c *Client) uploadFrom(ctx context.Context, command byte, groupName string, extName string, size int64, r io.Reader) (string, error) {
+	storageServer, err := c.GetStorageServerContext(ctx, groupName)
 	if err != nil {
-		return fmt.Errorf("failed to get storage server: %w", err)
+		return "", fmt.Errorf("failed to get storage server: %w", err)
 	}
 	
-	// 连接到存储服务器
-	storageClient := NewClient(storageServer.IPAddr, storageServer.Port)
-	err = storageClient.Connect()
+	var fileID string
+	err = c.onStorage(ctx, storageServer, func(storageClient *Client) error {
+		fileID, err = storageClient.uploadToStorageFrom(ctx, command, storageServer.StorePathIndex, extName, size, r)
+		return err
+	})
+	return fileID, err
+}
+
+// updateOnStorage 在文件的源存储服务器上执行更新操作
+func (c *Client) updateOnStorage(ctx context.Context, groupName string, fileName string, fn func(storageClient *Client) error) error {
+	storageServers, err := c.queryStorageByFile(ctx, TRACKER_PROTO_CMD_SERVICE_QUERY_UPDATE, groupName, fileName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to storage server: %w", err)
+		return fmt.Errorf("failed to get storage server: %w", err)
 	}
-	defer storageClient.Close()
 	
-	return storageClient.deleteFromStorage(groupName, fileName)
+	return c.onStorage(ctx, storageServers[0], fn)
 }
 
-// GetFileInfo 获取文件信息
-func (c *Client) GetFileInfo(fileID string) (*FileInfo, error) {
-	groupName, fileName, err := parseFileID(fileID)
+// readFromReplicas 在持有文件的所有存储服务器上依次执行读操作，直到某一个成功
+func (c *Client) readFromReplicas(ctx context.Context, groupName string, fileName string, fn func(storageClient *Client) error) error {
+	storageServers, err := c.queryStorageByFile(ctx, TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL, groupName, fileName)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file ID: %w", err)
+		return fmt.Errorf("failed to get storage server: %w", err)
 	}
 	
-	storageServer, err := c.GetStorageServer(groupName)
+	var lastErr error
+	for _, storageServer := range storageServers {
+		err := c.onStorage(ctx, storageServer, fn)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+	}
+	
+	return fmt.Errorf("read failed on all %d storage servers: %w", len(storageServers), lastErr)
+}
+
+// onStorage 获取到存储服务器的连接并在ctx的控制下执行fn
+// 客户端属于连接池时复用存储服务器连接，fn返回错误状态码以外的错误时连接不会被复用
+func (c *Client) onStorage(ctx context.Context, storageServer *StorageServer, fn func(storageClient *Client) error) error {
+	var storageClient *Client
+	var err error
+	if c.storagePool != nil {
+		storageClient, err = c.storagePool.Get(ctx, storageServer)
+	} else {
+		storageClient, err = dialStorage(ctx, storageServer)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get storage server: %w", err)
+		return err
 	}
 	
-	// 连接到存储服务器
+	err = storageClient.withContext(ctx, func() error {
+		return fn(storageClient)
+	})
+	
+	if c.storagePool != nil {
+		c.storagePool.Put(storageClient, err)
+	} else {
+		storageClient.Close()
+	}
+	return err
+}
+
+// dialStorage 连接到存储服务器
+func dialStorage(ctx context.Context, storageServer *StorageServer) (*Client, error) {
 	storageClient := NewClient(storageServer.IPAddr, storageServer.Port)
-	err = storageClient.Connect()
-	if err != nil {
+	if err := storageClient.ConnectContext(ctx); err != nil {
 		return nil, fmt.Errorf("failed to connect to storage server: %w", err)
 	}
-	defer storageClient.Close()
+	return storageClient, nil
+}
+
+// withContext 在ctx的控制下执行fn中的网络读写
+// ctx被取消时将连接的deadline设为当前时间以中断阻塞中的读写，此时返回ctx.Err()
+func (c *Client) withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	
-	return storageClient.getFileInfoFromStorage(groupName, fileName)
+	if !c.IsConnected() {
+		return fmt.Errorf("client not connected")
+	}
+	
+	conn := c.conn
+	c.ctx = ctx
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer func() {
+		stop()
+		c.ctx = nil
+		conn.SetDeadline(time.Time{})
+	}()
+	
+	err := fn()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// 连接的deadline可能略早于ctx的计时器到期
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
+	}
+	return err
+}
+
+// refreshDeadline 在每次读写前刷新连接的deadline
+// deadline取超时时间和ctx截止时间中较早的一个，因此超时限制的是单次读写而不是整个传输过程
+func (c *Client) refreshDeadline() {
+	deadline := time.Now().Add(c.timeout)
+	if c.ctx != nil {
+		if ctxDeadline, ok := c.ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+	}
+	c.conn.SetDeadline(deadline)
+	
+	// ctx可能恰好在设置deadline之前被取消，需要重新中断读写
+	if c.ctx != nil && c.ctx.Err() != nil {
+		c.conn.SetDeadline(time.Now())
+	}
 }
 
 // sendHeader 发送协议头
@@ -242,16 +612,18 @@ func (c *Client) sendHeader(header *Header) error {
 	buf[8] = header.Command
 	buf[9] = header.Status
 	
+	c.refreshDeadline()
 	_, err := c.conn.Write(buf)
-	return err
+	return newNetworkError("write", c.addr(), err)
 }
 
 // receiveHeader 接收协议头
 func (c *Client) receiveHeader() (*Header, error) {
 	buf := make([]byte, FDFS_PROTO_PKG_LEN_SIZE+2)
+	c.refreshDeadline()
 	_, err := io.ReadFull(c.conn, buf)
 	if err != nil {
-		return nil, err
+		return nil, newNetworkError("read", c.addr(), err)
 	}
 	
 	header := &Header{
@@ -260,19 +632,85 @@ func (c *Client) receiveHeader() (*Header, error) {
 		Status:  buf[9],
 	}
 	
+	// 错误状态的响应通常不带包体，若带有包体则读完丢弃，使连接可以继续复用
+	if header.Status != 0 && header.Length != 0 {
+		if header.Length < 0 {
+			return nil, fmt.Errorf("invalid response length: %d", header.Length)
+		}
+		c.refreshDeadline()
+		if _, err := io.CopyN(io.Discard, c.conn, header.Length); err != nil {
+			return nil, newNetworkError("read", c.addr(), err)
+		}
+	}
+	
 	return header, nil
 }
 
 // sendData 发送数据
 func (c *Client) sendData(data []byte) error {
+	c.refreshDeadline()
 	_, err := c.conn.Write(data)
-	return err
+	return newNetworkError("write", c.addr(), err)
 }
 
 // receiveData 接收数据
 func (c *Client) receiveData(data []byte) error {
+	c.refreshDeadline()
 	_, err := io.ReadFull(c.conn, data)
-	return err
+	return newNetworkError("read", c.addr(), err)
+}
+
+// sendStream 使用有限大小的缓冲区从r发送size字节
+func (c *Client) sendStream(ctx context.Context, r io.Reader, size int64) error {
+	buf := make([]byte, streamBufferSize)
+	remaining := size
+	for remaining > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		
+		n := int64(len(buf))
+		if remaining < n {
+			n = remaining
+		}
+		
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
+			return fmt.Errorf("failed to read upload source: %w", err)
+		}
+		
+		if err := c.sendData(buf[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// receiveStream 使用有限大小的缓冲区接收size字节并写入w
+func (c *Client) receiveStream(ctx context.Context, w io.Writer, size int64) (int64, error) {
+	buf := make([]byte, streamBufferSize)
+	var written int64
+	for written < size {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		
+		n := int64(len(buf))
+		if size-written < n {
+			n = size - written
+		}
+		
+		if err := c.receiveData(buf[:n]); err != nil {
+			return written, err
+		}
+		
+		m, err := w.Write(buf[:n])
+		written += int64(m)
+		if err != nil {
+			return written, fmt.Errorf("failed to write download target: %w", err)
+		}
+	}
+	return written, nil
 }
 
 // parseFileID 解析文件ID
@@ -285,6 +723,7 @@ func parseFileID(fileID string) (string, string, error) {
 }
 
 // parseStorageServer 解析存储服务器响应
+// 集群启用storage ID时tracker返回的仍是存储服务器的IP，storage ID只出现在文件名和统计信息中
 func parseStorageServer(data []byte) (*StorageServer, error) {
 	if len(data) < TRACKER_QUERY_STORAGE_STORE_BODY_LEN {
 		return nil, fmt.Errorf("invalid storage server response length")
@@ -296,5 +735,10 @@ func parseStorageServer(data []byte) (*StorageServer, error) {
 		Port:      int(binary.BigEndian.Uint64(data[FDFS_GROUP_NAME_MAX_LEN+IP_ADDRESS_SIZE-1:])),
 	}
 	
+	// 存储查询响应的最后一个字节是store_path_index
+	if len(data) > FDFS_GROUP_NAME_MAX_LEN+IP_ADDRESS_SIZE-1+FDFS_PROTO_PKG_LEN_SIZE {
+		server.StorePathIndex = data[FDFS_GROUP_NAME_MAX_LEN+IP_ADDRESS_SIZE-1+FDFS_PROTO_PKG_LEN_SIZE]
+	}
+	
 	return server, nil
 }
\ No newline at end of file
@@ -1,6 +1,13 @@
 package fastdfs
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -211,4 +218,484 @@ func TestHeader(t *testing.T) {
 	if header.Status != FDFS_PROTO_STATUS_SUCCESS {
 		t.Errorf("Expected status %d, got %d", FDFS_PROTO_STATUS_SUCCESS, header.Status)
 	}
-}
\ No newline at end of file
+}
+// newPipeClient 创建一个通过内存管道连接的客户端，返回服务端一侧的连接
+func newPipeClient(t *testing.T) (*Client, net.Conn) {
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+	
+	client := NewClient("127.0.0.1", 23000)
+	client.conn = clientConn
+	return client, serverConn
+}
+
+// readTestHeader 从服务端连接读取协议头
+func readTestHeader(t *testing.T, conn net.Conn) *Header {
+	buf := make([]byte, FDFS_PROTO_PKG_LEN_SIZE+2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Errorf("Failed to read header: %v", err)
+		return nil
+	}
+	return &Header{
+		Length:  int64(binary.BigEndian.Uint64(buf[0:8])),
+		Command: buf[8],
+		Status:  buf[9],
+	}
+}
+
+// writeTestHeader 向服务端连接写入响应头
+func writeTestHeader(conn net.Conn, length int64, status byte) {
+	buf := make([]byte, FDFS_PROTO_PKG_LEN_SIZE+2)
+	binary.BigEndian.PutUint64(buf[0:8], uint64(length))
+	buf[8] = FDFS_PROTO_CMD_RESP
+	buf[9] = status
+	conn.Write(buf)
+}
+
+func TestDownloadFromStorageTo(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	// 文件大小超过单个缓冲区，验证分段接收
+	content := bytes.Repeat([]byte("fastdfs-"), 3*streamBufferSize/8+5)
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		if header.Command != STORAGE_PROTO_CMD_DOWNLOAD_FILE {
+			t.Errorf("Expected download command, got %d", header.Command)
+		}
+		io.CopyN(io.Discard, server, header.Length)
+		
+		writeTestHeader(server, int64(len(content)), 0)
+		server.Write(content)
+	}()
+	
+	var buf bytes.Buffer
+	written, err := client.downloadFromStorageTo(context.Background(), "group1", "M00/00/00/test.jpg", 0, 0, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected download error: %v", err)
+	}
+	
+	if written != int64(len(content)) {
+		t.Errorf("Expected %d bytes written, got %d", len(content), written)
+	}
+	
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Error("Downloaded content does not match")
+	}
+}
+
+func TestDownloadFromStorageTo_Range(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	content := []byte("0123456789")
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		body := make([]byte, header.Length)
+		io.ReadFull(server, body)
+		
+		offset := int64(binary.BigEndian.Uint64(body[0:8]))
+		length := int64(binary.BigEndian.Uint64(body[8:16]))
+		if offset != 3 || length != 4 {
+			t.Errorf("Expected offset 3 length 4, got offset %d length %d", offset, length)
+		}
+		
+		writeTestHeader(server, length, 0)
+		server.Write(content[offset : offset+length])
+	}()
+	
+	var buf bytes.Buffer
+	_, err := client.downloadFromStorageTo(context.Background(), "group1", "M00/00/00/test.jpg", 3, 4, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected download error: %v", err)
+	}
+	
+	if buf.String() != "3456" {
+		t.Errorf("Expected range 3456, got %s", buf.String())
+	}
+}
+
+func TestDownloadRange_InvalidRange(t *testing.T) {
+	client := NewClient("127.0.0.1", 22122)
+	
+	if _, err := client.DownloadRange("group1/M00/00/00/test.jpg", -1, 0); err == nil {
+		t.Error("Expected error for negative offset")
+	}
+	
+	if _, err := client.DownloadRange("group1/M00/00/00/test.jpg", 0, -1); err == nil {
+		t.Error("Expected error for negative length")
+	}
+}
+
+func TestUploadToStorageFrom(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	content := bytes.Repeat([]byte{0xAB}, 2*streamBufferSize+17)
+	received := make(chan []byte, 1)
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		
+		prefix := make([]byte, 1+FDFS_PROTO_PKG_LEN_SIZE+FDFS_FILE_EXT_NAME_MAX_LEN)
+		io.ReadFull(server, prefix)
+		size := int64(binary.BigEndian.Uint64(prefix[1:9]))
+		if header.Length != int64(len(prefix))+size {
+			t.Errorf("Header length %d does not match body size %d", header.Length, size)
+		}
+		if ext := string(bytes.TrimRight(prefix[9:], "\x00")); ext != "jpg" {
+			t.Errorf("Expected ext jpg, got %s", ext)
+		}
+		
+		body := make([]byte, size)
+		io.ReadFull(server, body)
+		received <- body
+		
+		resp := make([]byte, FDFS_GROUP_NAME_MAX_LEN)
+		copy(resp, "group1")
+		resp = append(resp, []byte("M00/00/00/uploaded.jpg")...)
+		writeTestHeader(server, int64(len(resp)), 0)
+		server.Write(resp)
+	}()
+	
+	fileID, err := client.uploadToStorageFrom(context.Background(), STORAGE_PROTO_CMD_UPLOAD_FILE, 0, "jpg", int64(len(content)), bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+	
+	if fileID != "group1/M00/00/00/uploaded.jpg" {
+		t.Errorf("Unexpected file ID %s", fileID)
+	}
+	
+	if body := <-received; !bytes.Equal(body, content) {
+		t.Error("Uploaded content does not match")
+	}
+}
+
+func TestSendStream_ShortReader(t *testing.T) {
+	client, server := newPipeClient(t)
+	go io.Copy(io.Discard, server)
+	
+	err := client.sendStream(context.Background(), bytes.NewReader([]byte("short")), 10)
+	if err == nil {
+		t.Error("Expected error when reader has fewer bytes than declared size")
+	}
+}
+
+func TestReceiveStream_Cancelled(t *testing.T) {
+	client, _ := newPipeClient(t)
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	
+	_, err := client.receiveStream(ctx, io.Discard, 10)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPingContext_CancelInterruptsRead(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	// 服务端读取请求后不响应，只有取消ctx才能结束阻塞中的读取
+	go readTestHeader(t, server)
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	
+	start := time.Now()
+	err := client.PingContext(ctx)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Ping was not interrupted by cancellation, took %v", elapsed)
+	}
+}
+
+func TestPingContext_DeadlineExceeded(t *testing.T) {
+	client, server := newPipeClient(t)
+	go readTestHeader(t, server)
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	
+	err := client.PingContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestPingContext_ClearsDeadline(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	go func() {
+		if readTestHeader(t, server) != nil {
+			writeTestHeader(server, 0, 0)
+		}
+	}()
+	
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	
+	if err := client.PingContext(ctx); err != nil {
+		t.Fatalf("Unexpected ping error: %v", err)
+	}
+	if client.ctx != nil {
+		t.Error("Expected context to be unbound after the operation")
+	}
+}
+
+func TestPackUnpackMetadata(t *testing.T) {
+	metadata := map[string]string{
+		"width":    "1024",
+		"height":   "768",
+		"filename": "photo.jpg",
+	}
+	
+	packed := packMetadata(metadata)
+	if string(packed) != "filename\x02photo.jpg\x01height\x02768\x01width\x021024" {
+		t.Errorf("Unexpected packed metadata: %q", packed)
+	}
+	
+	unpacked := unpackMetadata(packed)
+	if len(unpacked) != len(metadata) {
+		t.Fatalf("Expected %d entries, got %d", len(metadata), len(unpacked))
+	}
+	for name, value := range metadata {
+		if unpacked[name] != value {
+			t.Errorf("Expected %s=%s, got %s", name, value, unpacked[name])
+		}
+	}
+	
+	if len(unpackMetadata(nil)) != 0 {
+		t.Error("Expected empty metadata for empty response")
+	}
+}
+
+func TestSetMetadataToStorage(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		if header.Command != STORAGE_PROTO_CMD_SET_METADATA {
+			t.Errorf("Expected set metadata command, got %d", header.Command)
+		}
+		
+		body := make([]byte, header.Length)
+		io.ReadFull(server, body)
+		
+		fileNameLen := int(binary.BigEndian.Uint64(body[0:8]))
+		metaLen := int(binary.BigEndian.Uint64(body[8:16]))
+		if body[16] != STORAGE_SET_METADATA_FLAG_MERGE {
+			t.Errorf("Expected merge flag, got %c", body[16])
+		}
+		
+		fileName := string(body[17+FDFS_GROUP_NAME_MAX_LEN : 17+FDFS_GROUP_NAME_MAX_LEN+fileNameLen])
+		if fileName != "M00/00/00/test.jpg" {
+			t.Errorf("Unexpected file name %s", fileName)
+		}
+		
+		meta := body[17+FDFS_GROUP_NAME_MAX_LEN+fileNameLen:]
+		if len(meta) != metaLen || string(meta) != "owner\x02alice" {
+			t.Errorf("Unexpected metadata %q", meta)
+		}
+		
+		writeTestHeader(server, 0, 0)
+	}()
+	
+	err := client.setMetadataToStorage("group1", "M00/00/00/test.jpg", map[string]string{"owner": "alice"}, MetadataMerge)
+	if err != nil {
+		t.Fatalf("Unexpected set metadata error: %v", err)
+	}
+}
+
+func TestParseFileInfo(t *testing.T) {
+	data := make([]byte, 3*8+IP_ADDRESS_SIZE)
+	binary.BigEndian.PutUint64(data[0:8], 2048)
+	binary.BigEndian.PutUint64(data[8:16], 1640995200)
+	binary.BigEndian.PutUint64(data[16:24], 0xDEADBEEF)
+	copy(data[24:], "192.168.1.10")
+	
+	info, err := parseFileInfo("group1", "M00/00/00/test.jpg", data)
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	
+	if info.FileSize != 2048 || info.CreateTime != 1640995200 {
+		t.Errorf("Unexpected size/time: %d/%d", info.FileSize, info.CreateTime)
+	}
+	
+	if info.CRC32 != 0xDEADBEEF {
+		t.Errorf("Expected CRC32 0xDEADBEEF, got %#x", info.CRC32)
+	}
+	
+	if info.SourceIPAddr != "192.168.1.10" {
+		t.Errorf("Expected source IP 192.168.1.10, got %s", info.SourceIPAddr)
+	}
+}
+
+func TestAppendToStorageFrom(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		if header.Command != STORAGE_PROTO_CMD_APPEND_FILE {
+			t.Errorf("Expected append command, got %d", header.Command)
+		}
+		
+		body := make([]byte, header.Length)
+		io.ReadFull(server, body)
+		
+		fileNameLen := int(binary.BigEndian.Uint64(body[0:8]))
+		size := int(binary.BigEndian.Uint64(body[8:16]))
+		fileName := string(body[16 : 16+fileNameLen])
+		if fileName != "M00/00/00/app.log" {
+			t.Errorf("Unexpected file name %s", fileName)
+		}
+		if data := string(body[16+fileNameLen:]); len(data) != size || data != "more" {
+			t.Errorf("Unexpected appended data %q", data)
+		}
+		
+		writeTestHeader(server, 0, 0)
+	}()
+	
+	err := client.appendToStorageFrom(context.Background(), "M00/00/00/app.log", 4, bytes.NewReader([]byte("more")))
+	if err != nil {
+		t.Fatalf("Unexpected append error: %v", err)
+	}
+}
+
+func TestTruncateOnStorage_ErrorStatus(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		if header.Command != STORAGE_PROTO_CMD_TRUNCATE_FILE {
+			t.Errorf("Expected truncate command, got %d", header.Command)
+		}
+		io.CopyN(io.Discard, server, header.Length)
+		writeTestHeader(server, 0, 22)
+	}()
+	
+	err := client.truncateOnStorage(context.Background(), "M00/00/00/app.log", 10)
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument for status 22, got %v", err)
+	}
+}
+
+func TestDialStorage_Error(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	
+	_, err = dialStorage(context.Background(), &StorageServer{IPAddr: "127.0.0.1", Port: port})
+	if !IsRetryable(err) {
+		t.Fatalf("Expected retryable network error, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "storage server") || strings.Contains(msg, "tracker") {
+		t.Errorf("Expected storage dial error not to mention the tracker, got %q", msg)
+	}
+}
+
+func TestUploadSlaveToStorageFrom(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		if header.Command != STORAGE_PROTO_CMD_UPLOAD_SLAVE_FILE {
+			t.Errorf("Expected upload slave command, got %d", header.Command)
+		}
+		
+		body := make([]byte, header.Length)
+		io.ReadFull(server, body)
+		
+		masterLen := int(binary.BigEndian.Uint64(body[0:8]))
+		size := int(binary.BigEndian.Uint64(body[8:16]))
+		prefix := string(bytes.TrimRight(body[16:32], "\x00"))
+		ext := string(bytes.TrimRight(body[32:38], "\x00"))
+		master := string(body[38 : 38+masterLen])
+		if prefix != "_150x150" || ext != "jpg" || master != "M00/00/00/master.jpg" {
+			t.Errorf("Unexpected slave request: prefix %s, ext %s, master %s", prefix, ext, master)
+		}
+		if len(body)-38-masterLen != size {
+			t.Errorf("Unexpected slave content length %d", len(body)-38-masterLen)
+		}
+		
+		resp := make([]byte, FDFS_GROUP_NAME_MAX_LEN)
+		copy(resp, "group1")
+		resp = append(resp, []byte("M00/00/00/master_150x150.jpg")...)
+		writeTestHeader(server, int64(len(resp)), 0)
+		server.Write(resp)
+	}()
+	
+	fileID, err := client.uploadSlaveToStorageFrom(context.Background(), "M00/00/00/master.jpg", "_150x150", "jpg", 5, bytes.NewReader([]byte("thumb")))
+	if err != nil {
+		t.Fatalf("Unexpected upload slave error: %v", err)
+	}
+	
+	if fileID != "group1/M00/00/00/master_150x150.jpg" {
+		t.Errorf("Unexpected slave file ID %s", fileID)
+	}
+}
+
+func TestRegenerateAppenderFileNameOnStorage(t *testing.T) {
+	client, server := newPipeClient(t)
+	
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		// 与storage_proto.h中的命令码比较，不使用共享的常量
+		if header.Command != 38 {
+			t.Errorf("Expected regenerate appender filename command 38, got %d", header.Command)
+		}
+		
+		body := make([]byte, header.Length)
+		io.ReadFull(server, body)
+		if string(body) != "M00/00/00/app.log" {
+			t.Errorf("Unexpected appender file name %s", body)
+		}
+		
+		resp := make([]byte, FDFS_GROUP_NAME_MAX_LEN)
+		copy(resp, "group1")
+		resp = append(resp, []byte("M00/00/00/normal.log")...)
+		writeTestHeader(server, int64(len(resp)), 0)
+		server.Write(resp)
+	}()
+	
+	fileID, err := client.regenerateAppenderFileNameOnStorage("M00/00/00/app.log")
+	if err != nil {
+		t.Fatalf("Unexpected regenerate error: %v", err)
+	}
+	if fileID != "group1/M00/00/00/normal.log" {
+		t.Errorf("Unexpected regenerated file ID %s", fileID)
+	}
+}
@@ -36,19 +36,34 @@ func (cm *ClusterManager) AddCluster(cluster *models.Cluster) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 	
+	// 根据集群版本选择协议方言
+	dialect, err := DialectForVersion(cluster.Version)
+	if err != nil {
+		return fmt.Errorf("cluster %s: %w", cluster.Name, err)
+	}
+	
 	// 创建连接池
-	pool := NewConnectionPool(cluster.TrackerAddr, cluster.TrackerPort, 10)
+	config := DefaultPoolConfig()
+	config.Dialect = dialect
+	pool := NewConnectionPoolWithConfig(cluster.GetTrackerEndpoints(), config)
 	
 	// 创建带连接池的客户端
 	client := NewPooledClient(pool)
 	
 	// 测试连接
-	err := client.Ping()
+	err = client.Ping()
 	if err != nil {
 		pool.Close()
 		return fmt.Errorf("failed to connect to cluster %s: %w", cluster.Name, err)
 	}
 	
+	// 确认配置的版本与集群实际运行的版本一致
+	err = client.VerifyVersion()
+	if err != nil {
+		pool.Close()
+		return fmt.Errorf("failed to verify version of cluster %s: %w", cluster.Name, err)
+	}
+	
 	connection := &ClusterConnection{
 		cluster:   cluster,
 		pool:      pool,
@@ -148,6 +163,15 @@ func (cm *ClusterManager) Close() error {
 	return nil
 }
 
+// GetTopology 获取集群拓扑，包括所有组及组内存储服务器的状态
+func (cm *ClusterManager) GetTopology(clusterID string) ([]*GroupInfo, error) {
+	connection, err := cm.GetCluster(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return connection.GetTopology()
+}
+
 // checkHealth 检查集群健康状态
 func (cc *ClusterConnection) checkHealth() error {
 	cc.mu.Lock()
@@ -178,6 +202,15 @@ func (cc *ClusterConnection) GetClient() *PooledClient {
 	return cc.client
 }
 
+// GetTopology 获取集群拓扑
+func (cc *ClusterConnection) GetTopology() ([]*GroupInfo, error) {
+	groups, err := cc.client.GetTopology()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topology of cluster %s: %w", cc.cluster.ID, err)
+	}
+	return groups, nil
+}
+
 // IsHealthy 检查是否健康
 func (cc *ClusterConnection) IsHealthy() bool {
 	cc.mu.RLock()
@@ -195,6 +228,10 @@ func (cc *ClusterConnection) GetConnectionStats() map[string]interface{} {
 		"cluster_name":   cc.cluster.Name,
 		"tracker_addr":   cc.cluster.TrackerAddr,
 		"tracker_port":   cc.cluster.TrackerPort,
+		"version":        cc.cluster.Version,
+		"trackers":       cc.pool.TrackerStats(),
+		"storage_pools":  cc.pool.StorageStats(),
+		"pool":           cc.pool.Stats(),
 		"is_healthy":     cc.isHealthy,
 		"last_check":     cc.lastCheck,
 		"pool_size":      cc.pool.Size(),
@@ -204,39 +241,52 @@ func (cc *ClusterConnection) GetConnectionStats() map[string]interface{} {
 
 // TestConnection 测试集群连接
 func TestConnection(cluster *models.Cluster) error {
-	client := NewClient(cluster.TrackerAddr, cluster.TrackerPort)
-	err := client.Connect()
+	dialect, err := DialectForVersion(cluster.Version)
+	if err != nil {
+		return err
+	}
+	
+	client, err := connectTracker(cluster)
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return err
 	}
 	defer client.Close()
+	client.SetDialect(dialect)
 	
 	err = client.Ping()
 	if err != nil {
 		return fmt.Errorf("ping failed: %w", err)
 	}
 	
-	return nil
+	return client.VerifyVersion()
 }
 
-// GetClusterInfo 获取集群详细信息
-func GetClusterInfo(cluster *models.Cluster) (*GroupInfo, error) {
-	client := NewClient(cluster.TrackerAddr, cluster.TrackerPort)
-	err := client.Connect()
+// GetClusterInfo 获取集群详细信息，返回所有组及组内存储服务器的状态
+func GetClusterInfo(cluster *models.Cluster) ([]*GroupInfo, error) {
+	client, err := connectTracker(cluster)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %w", err)
+		return nil, err
 	}
 	defer client.Close()
 	
-	// 这里可以实现获取集群详细信息的逻辑
-	// 由于FastDFS协议复杂，这里返回一个基本的信息结构
-	return &GroupInfo{
-		GroupName:      "group1", // 默认组名，实际应该从服务器获取
-		TotalMB:        0,
-		FreeMB:         0,
-		StorageCount:   1,
-		StoragePort:    23000,
-		ActiveCount:    1,
-		StorePathCount: 1,
-	}, nil
-}
\ No newline at end of file
+	groups, err := client.GetTopology()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster topology: %w", err)
+	}
+	
+	return groups, nil
+}
+
+// connectTracker 依次尝试集群的各个tracker，返回第一个连接成功的客户端
+func connectTracker(cluster *models.Cluster) (*Client, error) {
+	var lastErr error
+	for _, tracker := range cluster.GetTrackerEndpoints() {
+		client := NewClient(tracker.Addr, tracker.Port)
+		if err := client.Connect(); err != nil {
+			lastErr = err
+			continue
+		}
+		return client, nil
+	}
+	return nil, fmt.Errorf("failed to connect: %w", lastErr)
+}
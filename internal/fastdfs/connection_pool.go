@@ -1,65 +1,158 @@
 package fastdfs
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"sync"
+	"time"
+
+	"fastdfs-migration-system/internal/models"
 )
 
+// PoolConfig 连接池配置
+type PoolConfig struct {
+	MaxOpen          int           // 最大打开连接数（使用中与空闲之和）
+	ValidateAfter    time.Duration // 空闲超过此时间的连接在取出时先发送ACTIVE_TEST校验
+	IdleTimeout      time.Duration // 空闲超过此时间的连接被关闭
+	EvictionInterval time.Duration // 后台清理空闲连接的间隔
+	Dialect          *Dialect      // 新建连接使用的协议方言，为nil时不限制版本相关的命令
+}
+
+// DefaultPoolConfig 获取默认的连接池配置
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxOpen:          10,
+		ValidateAfter:    30 * time.Second,
+		IdleTimeout:      5 * time.Minute,
+		EvictionInterval: time.Minute,
+	}
+}
+
+// PoolStats 连接池统计信息
+type PoolStats struct {
+	MaxOpen            int           `json:"max_open"`
+	InUse              int           `json:"in_use"`
+	Idle               int           `json:"idle"`
+	Hits               int64         `json:"hits"`                // 复用空闲连接的次数
+	Misses             int64         `json:"misses"`              // 新建连接的次数
+	Waits              int64         `json:"waits"`               // 因达到最大连接数而等待的次数
+	WaitDuration       time.Duration `json:"wait_duration"`       // 累计等待时间
+	ValidationFailures int64         `json:"validation_failures"` // 空闲连接校验失败的次数
+	Evicted            int64         `json:"evicted"`             // 因空闲超时被关闭的连接数
+	Discarded          int64         `json:"discarded"`           // 因出错被丢弃的连接数
+}
+
 // connectionPool 连接池实现
+// slots中的令牌数即使用中的连接数，获取连接前必须取得令牌，因此打开的连接总数不超过MaxOpen
 type connectionPool struct {
-	trackerAddr    string
-	trackerPort    int
-	maxConnections int
-	connections    chan *Client
-	mu             sync.RWMutex
-	closed         bool
+	trackers    *trackerSet
+	storagePool *StoragePool
+	config      PoolConfig
+	slots       chan struct{}
+	idle        []*idleClient
+	stats       PoolStats
+	mu          sync.Mutex
+	closed      bool
+	stop        chan struct{}
+	evictorDone chan struct{}
+}
+
+// idleClient 空闲连接及其放回连接池的时间
+type idleClient struct {
+	client *Client
+	since  time.Time
 }
 
 // NewConnectionPool 创建新的连接池
 func NewConnectionPool(trackerAddr string, trackerPort int, maxConnections int) ConnectionPool {
+	return NewMultiTrackerConnectionPool([]models.TrackerEndpoint{{Addr: trackerAddr, Port: trackerPort}}, maxConnections)
+}
+
+// NewMultiTrackerConnectionPool 创建连接到多个tracker的连接池
+// 新连接按轮询顺序分布到各个tracker上，某个tracker拒绝连接或请求失败时自动切换到其他tracker
+func NewMultiTrackerConnectionPool(trackers []models.TrackerEndpoint, maxConnections int) ConnectionPool {
+	config := DefaultPoolConfig()
+	config.MaxOpen = maxConnections
+	return NewConnectionPoolWithConfig(trackers, config)
+}
+
+// NewConnectionPoolWithConfig 按指定配置创建连接池，并启动后台清理空闲连接的goroutine
+func NewConnectionPoolWithConfig(trackers []models.TrackerEndpoint, config PoolConfig) ConnectionPool {
+	defaults := DefaultPoolConfig()
+	if config.MaxOpen <= 0 {
+		config.MaxOpen = defaults.MaxOpen
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaults.IdleTimeout
+	}
+	if config.EvictionInterval <= 0 {
+		config.EvictionInterval = defaults.EvictionInterval
+	}
+
 	pool := &connectionPool{
-		trackerAddr:    trackerAddr,
-		trackerPort:    trackerPort,
-		maxConnections: maxConnections,
-		connections:    make(chan *Client, maxConnections),
-		closed:         false,
-	}
-	
-	// 预创建一些连接
-	for i := 0; i < maxConnections/2; i++ {
-		client := NewClient(trackerAddr, trackerPort)
-		if err := client.Connect(); err == nil {
-			pool.connections <- client
-		}
+		trackers:    newTrackerSet(trackers),
+		storagePool: NewStoragePool(defaultStorageMaxPerHost, defaultStorageIdleTimeout),
+		config:      config,
+		slots:       make(chan struct{}, config.MaxOpen),
+		stop:        make(chan struct{}),
+		evictorDone: make(chan struct{}),
 	}
-	
+	pool.stats.MaxOpen = config.MaxOpen
+
+	go pool.evictLoop()
+
 	return pool
 }
 
 // Get 从连接池获取连接
 func (p *connectionPool) Get() (*Client, error) {
-	p.mu.RLock()
-	if p.closed {
-		p.mu.RUnlock()
-		return nil, fmt.Errorf("connection pool is closed")
+	return p.GetContext(context.Background())
+}
+
+// GetContext 从连接池获取连接，打开的连接数达到上限时阻塞等待，直到有连接归还或ctx被取消
+func (p *connectionPool) GetContext(ctx context.Context) (*Client, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
 	}
-	p.mu.RUnlock()
-	
-	select {
-	case client := <-p.connections:
-		// 检查连接是否有效
-		if client.IsConnected() {
-			if err := client.Ping(); err == nil {
-				return client, nil
-			}
+
+	for {
+		client, idleFor := p.takeIdle()
+		if client == nil {
+			break
 		}
-		// 连接无效，重新创建
+
+		// 空闲时间较短的连接直接复用，空闲较久的连接可能已被tracker关闭，需要先校验
+		var err error
+		if idleFor >= p.config.ValidateAfter {
+			err = client.PingContext(ctx)
+		}
+		if err == nil {
+			p.mu.Lock()
+			p.stats.Hits++
+			p.mu.Unlock()
+			return client, nil
+		}
+
 		client.Close()
-		return p.createNewConnection()
-	default:
-		// 没有可用连接，创建新连接
-		return p.createNewConnection()
+		p.mu.Lock()
+		p.stats.ValidationFailures++
+		p.mu.Unlock()
+		if ctx.Err() != nil {
+			<-p.slots
+			return nil, ctx.Err()
+		}
+		p.trackers.markFailure(client.trackerAddr, client.trackerPort, err)
 	}
+
+	client, err := p.createNewConnection(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return client, nil
 }
 
 // Put 将连接放回连接池
@@ -67,73 +160,227 @@ func (p *connectionPool) Put(client *Client) error {
 	if client == nil {
 		return nil
 	}
-	
-	p.mu.RLock()
-	if p.closed {
-		p.mu.RUnlock()
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || !client.IsConnected() {
 		return client.Close()
 	}
-	p.mu.RUnlock()
-	
-	// 检查连接是否有效
-	if !client.IsConnected() || client.Ping() != nil {
-		client.Close()
-		return nil
+
+	p.idle = append(p.idle, &idleClient{client: client, since: time.Now()})
+	return nil
+}
+
+// Discard 关闭出错的连接并释放其占用的名额
+func (p *connectionPool) Discard(client *Client) {
+	if client == nil {
+		return
 	}
-	
-	select {
-	case p.connections <- client:
-		return nil
-	default:
-		// 连接池已满，关闭连接
-		return client.Close()
+	client.Close()
+
+	p.mu.Lock()
+	p.stats.Discarded++
+	p.mu.Unlock()
+
+	<-p.slots
+}
+
+// DiscardFailed 关闭与tracker通信失败的连接，并记录该tracker失败，之后的新连接优先选择其他tracker
+// 连接到同一tracker的空闲连接很可能也已失效，一并关闭，避免之后的请求复用它们
+func (p *connectionPool) DiscardFailed(client *Client, err error) {
+	if client == nil {
+		return
 	}
+	p.trackers.markFailure(client.trackerAddr, client.trackerPort, err)
+	p.closeIdleTo(client.trackerAddr, client.trackerPort)
+	p.Discard(client)
 }
 
 // Close 关闭连接池
 func (p *connectionPool) Close() error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	
 	if p.closed {
+		p.mu.Unlock()
 		return nil
 	}
-	
 	p.closed = true
-	close(p.connections)
-	
-	// 关闭所有连接
-	for client := range p.connections {
-		client.Close()
+
+	// 关闭所有空闲连接，使用中的连接在归还时关闭
+	for _, idle := range p.idle {
+		idle.client.Close()
 	}
-	
-	return nil
+	p.idle = nil
+	p.mu.Unlock()
+
+	close(p.stop)
+	<-p.evictorDone
+
+	return p.storagePool.Close()
 }
 
 // Size 获取连接池大小
 func (p *connectionPool) Size() int {
-	return p.maxConnections
+	return p.config.MaxOpen
 }
 
 // Available 获取可用连接数
 func (p *connectionPool) Available() int {
-	return len(p.connections)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
 }
 
-// createNewConnection 创建新连接
-func (p *connectionPool) createNewConnection() (*Client, error) {
-	client := NewClient(p.trackerAddr, p.trackerPort)
-	err := client.Connect()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create new connection: %w", err)
+// Stats 获取连接池统计信息
+func (p *connectionPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.InUse = len(p.slots)
+	stats.Idle = len(p.idle)
+	return stats
+}
+
+// StorageStats 获取各存储服务器连接池的统计信息
+func (p *connectionPool) StorageStats() map[string]StorageHostStat {
+	return p.storagePool.Stats()
+}
+
+// TrackerStats 获取各tracker的健康状态
+func (p *connectionPool) TrackerStats() []TrackerStat {
+	return p.trackers.stats()
+}
+
+// acquire 取得一个连接名额，名额用尽时等待并记录等待时间
+func (p *connectionPool) acquire(ctx context.Context) error {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return fmt.Errorf("connection pool is closed")
 	}
-	return client, nil
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	start := time.Now()
+	defer func() {
+		p.mu.Lock()
+		p.stats.Waits++
+		p.stats.WaitDuration += time.Since(start)
+		p.mu.Unlock()
+	}()
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// takeIdle 取出最近归还的空闲连接及其空闲时长，没有空闲连接时记录一次未命中
+func (p *connectionPool) takeIdle() (*Client, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle) == 0 {
+		p.stats.Misses++
+		return nil, 0
+	}
+
+	idle := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return idle.client, time.Since(idle.since)
+}
+
+// evictLoop 定期关闭空闲超时的连接，直到连接池关闭
+func (p *connectionPool) evictLoop() {
+	defer close(p.evictorDone)
+
+	ticker := time.NewTicker(p.config.EvictionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.evictIdle()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// evictIdle 关闭空闲超时的连接
+func (p *connectionPool) evictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kept := p.idle[:0]
+	for _, idle := range p.idle {
+		if time.Since(idle.since) >= p.config.IdleTimeout {
+			idle.client.Close()
+			p.stats.Evicted++
+			continue
+		}
+		kept = append(kept, idle)
+	}
+	p.idle = kept
+}
+
+// closeIdleTo 关闭连接到指定tracker的空闲连接
+func (p *connectionPool) closeIdleTo(addr string, port int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kept := p.idle[:0]
+	for _, idle := range p.idle {
+		if idle.client.trackerAddr == addr && idle.client.trackerPort == port {
+			idle.client.Close()
+			p.stats.Discarded++
+			continue
+		}
+		kept = append(kept, idle)
+	}
+	p.idle = kept
+}
+
+// createNewConnection 创建新连接，依次尝试各个tracker直到连接成功
+func (p *connectionPool) createNewConnection(ctx context.Context) (*Client, error) {
+	var lastErr error
+	candidates := p.trackers.candidates()
+	for _, tracker := range candidates {
+		client := NewClient(tracker.Addr, tracker.Port)
+		err := client.ConnectContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			p.trackers.markFailure(tracker.Addr, tracker.Port, err)
+			lastErr = err
+			continue
+		}
+		p.trackers.markSuccess(tracker.Addr, tracker.Port)
+		client.storagePool = p.storagePool
+		client.dialect = p.config.Dialect
+		return client, nil
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("failed to create new connection: no tracker configured")
+	}
+	return nil, fmt.Errorf("failed to create new connection after trying %d trackers: %w", len(candidates), lastErr)
 }
 
 // PooledClient 带连接池的客户端
+// 每次调用都从连接池中取出独立的连接并在调用结束后归还，因此可以被多个goroutine并发使用
 type PooledClient struct {
-	pool   ConnectionPool
-	client *Client
+	pool ConnectionPool
 }
 
 // NewPooledClient 创建带连接池的客户端
@@ -143,99 +390,460 @@ func NewPooledClient(pool ConnectionPool) *PooledClient {
 	}
 }
 
-// getClient 获取客户端连接
-func (pc *PooledClient) getClient() (*Client, error) {
-	if pc.client == nil {
-		client, err := pc.pool.Get()
+// getClient 在ctx的控制下从连接池取出一个连接
+func (pc *PooledClient) getClient(ctx context.Context) (*Client, error) {
+	return pc.pool.GetContext(ctx)
+}
+
+// releaseClient 归还连接，出现网络错误的连接协议状态未知，直接丢弃而不放回连接池
+// 与tracker通信失败时同时记录该tracker失败，使之后的连接切换到其他tracker；
+// 存储服务器的网络错误发生在另一个连接上，tracker连接仍可复用
+func (pc *PooledClient) releaseClient(client *Client, err error) {
+	if isTrackerFailure(client, err) {
+		pc.pool.DiscardFailed(client, err)
+		return
+	}
+	if isConnectionError(err) && !isStorageFailure(client, err) {
+		pc.pool.Discard(client)
+		return
+	}
+	pc.pool.Put(client)
+}
+
+// withTrackerFailover 从连接池取出连接执行op，与tracker通信失败时换用下一个tracker重试，最多尝试每个tracker一次
+// 客户端的操作都先向tracker查询存储服务器再访问存储服务器，tracker失败时存储服务器上的步骤尚未执行，
+// 因此上传、删除等操作也可以安全地重试；存储服务器上的失败不会重试
+func (pc *PooledClient) withTrackerFailover(ctx context.Context, op func(client *Client) error) error {
+	attempts := max(len(pc.pool.TrackerStats()), 1)
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		var client *Client
+		client, err = pc.getClient(ctx)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		err = op(client)
+		pc.releaseClient(client, err)
+		if !isTrackerFailure(client, err) || ctx.Err() != nil {
+			return err
 		}
-		pc.client = client
 	}
-	return pc.client, nil
+	return err
+}
+
+// isTrackerFailure 判断错误是否由与tracker的网络通信失败引起
+// 存储服务器的网络错误、tracker返回的错误状态码和ctx被取消都不计为tracker失败
+func isTrackerFailure(client *Client, err error) bool {
+	var netErr *NetworkError
+	return errors.As(err, &netErr) && netErr.Addr == client.addr()
 }
 
-// releaseClient 释放客户端连接
-func (pc *PooledClient) releaseClient() {
-	if pc.client != nil {
-		pc.pool.Put(pc.client)
-		pc.client = nil
+// isStorageFailure 判断错误是否由与存储服务器的网络通信失败引起，此时tracker连接本身没有出错
+func isStorageFailure(client *Client, err error) bool {
+	var netErr *NetworkError
+	return errors.As(err, &netErr) && netErr.Addr != client.addr()
+}
+
+// isConnectionError 判断错误是否由连接本身引起（网络错误、连接被关闭或操作被取消）
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
 	}
+	var netErr net.Error
+	var fdfsNetErr *NetworkError
+	return errors.As(err, &netErr) ||
+		errors.As(err, &fdfsNetErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
 }
 
 // Ping 测试连接
 func (pc *PooledClient) Ping() error {
-	client, err := pc.getClient()
-	if err != nil {
-		return err
-	}
-	defer pc.releaseClient()
-	
-	return client.Ping()
+	return pc.withTrackerFailover(context.Background(), func(client *Client) error {
+		return client.Ping()
+	})
+}
+
+// PingContext 在ctx的控制下测试连接
+func (pc *PooledClient) PingContext(ctx context.Context) error {
+	return pc.withTrackerFailover(ctx, func(client *Client) error {
+		return client.PingContext(ctx)
+	})
 }
 
 // GetStorageServer 获取存储服务器信息
 func (pc *PooledClient) GetStorageServer(groupName string) (*StorageServer, error) {
-	client, err := pc.getClient()
-	if err != nil {
-		return nil, err
-	}
-	defer pc.releaseClient()
-	
-	return client.GetStorageServer(groupName)
+	var result *StorageServer
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.GetStorageServer(groupName)
+		return err
+	})
+	return result, err
+}
+
+// GetStorageServerContext 在ctx的控制下获取存储服务器信息
+func (pc *PooledClient) GetStorageServerContext(ctx context.Context, groupName string) (*StorageServer, error) {
+	var result *StorageServer
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.GetStorageServerContext(ctx, groupName)
+		return err
+	})
+	return result, err
+}
+
+// QueryFetchStorage 查询可读取指定文件的存储服务器
+func (pc *PooledClient) QueryFetchStorage(groupName string, fileName string) (*StorageServer, error) {
+	var result *StorageServer
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.QueryFetchStorage(groupName, fileName)
+		return err
+	})
+	return result, err
+}
+
+// QueryFetchAllStorages 查询持有指定文件的所有存储服务器
+func (pc *PooledClient) QueryFetchAllStorages(groupName string, fileName string) ([]*StorageServer, error) {
+	var result []*StorageServer
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.QueryFetchAllStorages(groupName, fileName)
+		return err
+	})
+	return result, err
+}
+
+// QueryUpdateStorage 查询可更新指定文件的源存储服务器
+func (pc *PooledClient) QueryUpdateStorage(groupName string, fileName string) (*StorageServer, error) {
+	var result *StorageServer
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.QueryUpdateStorage(groupName, fileName)
+		return err
+	})
+	return result, err
+}
+
+// ListGroups 列出集群中所有组的统计信息
+func (pc *PooledClient) ListGroups() ([]*GroupInfo, error) {
+	var result []*GroupInfo
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.ListGroups()
+		return err
+	})
+	return result, err
+}
+
+// ListGroup 获取指定组的统计信息
+func (pc *PooledClient) ListGroup(groupName string) (*GroupInfo, error) {
+	var result *GroupInfo
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.ListGroup(groupName)
+		return err
+	})
+	return result, err
+}
+
+// ListStorages 列出指定组内所有存储服务器的状态
+func (pc *PooledClient) ListStorages(groupName string) ([]*StorageInfo, error) {
+	var result []*StorageInfo
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.ListStorages(groupName)
+		return err
+	})
+	return result, err
+}
+
+// GetTopology 获取集群拓扑
+func (pc *PooledClient) GetTopology() ([]*GroupInfo, error) {
+	var result []*GroupInfo
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.GetTopology()
+		return err
+	})
+	return result, err
+}
+
+// ResolveStorageID 将组内的storage ID解析为存储服务器地址
+func (pc *PooledClient) ResolveStorageID(groupName string, storageID string) (*StorageServer, error) {
+	var result *StorageServer
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.ResolveStorageID(groupName, storageID)
+		return err
+	})
+	return result, err
+}
+
+// GetSourceStorage 获取上传文件的源存储服务器地址
+func (pc *PooledClient) GetSourceStorage(fileID string) (*StorageServer, error) {
+	var result *StorageServer
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.GetSourceStorage(fileID)
+		return err
+	})
+	return result, err
 }
 
 // ListFiles 列出文件
 func (pc *PooledClient) ListFiles(groupName string, startFileName string, limit int) ([]*FileInfo, error) {
-	client, err := pc.getClient()
-	if err != nil {
-		return nil, err
-	}
-	defer pc.releaseClient()
-	
-	return client.ListFiles(groupName, startFileName, limit)
+	var result []*FileInfo
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.ListFiles(groupName, startFileName, limit)
+		return err
+	})
+	return result, err
 }
 
 // DownloadFile 下载文件
 func (pc *PooledClient) DownloadFile(fileID string) ([]byte, error) {
-	client, err := pc.getClient()
-	if err != nil {
-		return nil, err
-	}
-	defer pc.releaseClient()
-	
-	return client.DownloadFile(fileID)
+	var result []byte
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.DownloadFile(fileID)
+		return err
+	})
+	return result, err
+}
+
+// DownloadFileContext 在ctx的控制下下载文件
+func (pc *PooledClient) DownloadFileContext(ctx context.Context, fileID string) ([]byte, error) {
+	var result []byte
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.DownloadFileContext(ctx, fileID)
+		return err
+	})
+	return result, err
+}
+
+// DownloadTo 以流的方式下载文件
+func (pc *PooledClient) DownloadTo(ctx context.Context, fileID string, w io.Writer) (int64, error) {
+	var result int64
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.DownloadTo(ctx, fileID, w)
+		return err
+	})
+	return result, err
+}
+
+// DownloadRange 下载文件的指定区间
+func (pc *PooledClient) DownloadRange(fileID string, offset int64, length int64) ([]byte, error) {
+	var result []byte
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.DownloadRange(fileID, offset, length)
+		return err
+	})
+	return result, err
+}
+
+// DownloadRangeTo 以流的方式下载文件的指定区间
+func (pc *PooledClient) DownloadRangeTo(ctx context.Context, fileID string, offset int64, length int64, w io.Writer) (int64, error) {
+	var result int64
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.DownloadRangeTo(ctx, fileID, offset, length, w)
+		return err
+	})
+	return result, err
 }
 
 // UploadFile 上传文件
 func (pc *PooledClient) UploadFile(groupName string, fileName string, data []byte) (string, error) {
-	client, err := pc.getClient()
-	if err != nil {
-		return "", err
-	}
-	defer pc.releaseClient()
-	
-	return client.UploadFile(groupName, fileName, data)
+	var result string
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.UploadFile(groupName, fileName, data)
+		return err
+	})
+	return result, err
+}
+
+// UploadFileContext 在ctx的控制下上传文件
+func (pc *PooledClient) UploadFileContext(ctx context.Context, groupName string, fileName string, data []byte) (string, error) {
+	var result string
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.UploadFileContext(ctx, groupName, fileName, data)
+		return err
+	})
+	return result, err
+}
+
+// UploadFrom 以流的方式上传文件
+func (pc *PooledClient) UploadFrom(ctx context.Context, groupName string, extName string, size int64, r io.Reader) (string, error) {
+	var result string
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.UploadFrom(ctx, groupName, extName, size, r)
+		return err
+	})
+	return result, err
 }
 
 // DeleteFile 删除文件
 func (pc *PooledClient) DeleteFile(fileID string) error {
-	client, err := pc.getClient()
-	if err != nil {
-		return err
-	}
-	defer pc.releaseClient()
-	
-	return client.DeleteFile(fileID)
+	return pc.withTrackerFailover(context.Background(), func(client *Client) error {
+		return client.DeleteFile(fileID)
+	})
+}
+
+// DeleteFileContext 在ctx的控制下删除文件
+func (pc *PooledClient) DeleteFileContext(ctx context.Context, fileID string) error {
+	return pc.withTrackerFailover(ctx, func(client *Client) error {
+		return client.DeleteFileContext(ctx, fileID)
+	})
 }
 
 // GetFileInfo 获取文件信息
 func (pc *PooledClient) GetFileInfo(fileID string) (*FileInfo, error) {
-	client, err := pc.getClient()
-	if err != nil {
-		return nil, err
-	}
-	defer pc.releaseClient()
-	
-	return client.GetFileInfo(fileID)
+	var result *FileInfo
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.GetFileInfo(fileID)
+		return err
+	})
+	return result, err
+}
+
+// GetFileInfoContext 在ctx的控制下获取文件信息
+func (pc *PooledClient) GetFileInfoContext(ctx context.Context, fileID string) (*FileInfo, error) {
+	var result *FileInfo
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.GetFileInfoContext(ctx, fileID)
+		return err
+	})
+	return result, err
+}
+
+// GetMetadata 获取文件元数据
+func (pc *PooledClient) GetMetadata(fileID string) (map[string]string, error) {
+	var result map[string]string
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.GetMetadata(fileID)
+		return err
+	})
+	return result, err
+}
+
+// GetMetadataContext 在ctx的控制下获取文件的元数据
+func (pc *PooledClient) GetMetadataContext(ctx context.Context, fileID string) (map[string]string, error) {
+	var result map[string]string
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.GetMetadataContext(ctx, fileID)
+		return err
+	})
+	return result, err
+}
+
+// SetMetadata 设置文件元数据
+func (pc *PooledClient) SetMetadata(fileID string, metadata map[string]string, flag MetadataFlag) error {
+	return pc.withTrackerFailover(context.Background(), func(client *Client) error {
+		return client.SetMetadata(fileID, metadata, flag)
+	})
+}
+
+// SetMetadataContext 在ctx的控制下设置文件的元数据
+func (pc *PooledClient) SetMetadataContext(ctx context.Context, fileID string, metadata map[string]string, flag MetadataFlag) error {
+	return pc.withTrackerFailover(ctx, func(client *Client) error {
+		return client.SetMetadataContext(ctx, fileID, metadata, flag)
+	})
+}
+
+// UploadAppenderFile 上传appender文件
+func (pc *PooledClient) UploadAppenderFile(groupName string, fileName string, data []byte) (string, error) {
+	var result string
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.UploadAppenderFile(groupName, fileName, data)
+		return err
+	})
+	return result, err
+}
+
+// UploadAppenderFrom 以流的方式上传appender文件
+func (pc *PooledClient) UploadAppenderFrom(ctx context.Context, groupName string, extName string, size int64, r io.Reader) (string, error) {
+	var result string
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.UploadAppenderFrom(ctx, groupName, extName, size, r)
+		return err
+	})
+	return result, err
+}
+
+// AppendFile 向appender文件追加数据
+func (pc *PooledClient) AppendFile(appenderFileID string, data []byte) error {
+	return pc.withTrackerFailover(context.Background(), func(client *Client) error {
+		return client.AppendFile(appenderFileID, data)
+	})
+}
+
+// AppendFrom 以流的方式向appender文件追加数据
+func (pc *PooledClient) AppendFrom(ctx context.Context, appenderFileID string, size int64, r io.Reader) error {
+	return pc.withTrackerFailover(ctx, func(client *Client) error {
+		return client.AppendFrom(ctx, appenderFileID, size, r)
+	})
+}
+
+// ModifyFile 修改appender文件内容
+func (pc *PooledClient) ModifyFile(appenderFileID string, offset int64, data []byte) error {
+	return pc.withTrackerFailover(context.Background(), func(client *Client) error {
+		return client.ModifyFile(appenderFileID, offset, data)
+	})
+}
+
+// ModifyFrom 以流的方式修改appender文件内容
+func (pc *PooledClient) ModifyFrom(ctx context.Context, appenderFileID string, offset int64, size int64, r io.Reader) error {
+	return pc.withTrackerFailover(ctx, func(client *Client) error {
+		return client.ModifyFrom(ctx, appenderFileID, offset, size, r)
+	})
+}
+
+// TruncateFile 截断appender文件
+func (pc *PooledClient) TruncateFile(appenderFileID string, truncatedSize int64) error {
+	return pc.withTrackerFailover(context.Background(), func(client *Client) error {
+		return client.TruncateFile(appenderFileID, truncatedSize)
+	})
+}
+
+// TruncateFileContext 在ctx的控制下截断appender文件
+func (pc *PooledClient) TruncateFileContext(ctx context.Context, appenderFileID string, truncatedSize int64) error {
+	return pc.withTrackerFailover(ctx, func(client *Client) error {
+		return client.TruncateFileContext(ctx, appenderFileID, truncatedSize)
+	})
+}
+
+// RegenerateAppenderFileName 重新生成appender文件的文件名
+func (pc *PooledClient) RegenerateAppenderFileName(appenderFileID string) (string, error) {
+	return pc.RegenerateAppenderFileNameContext(context.Background(), appenderFileID)
+}
+
+// RegenerateAppenderFileNameContext 在ctx的控制下重新生成appender文件的文件名
+func (pc *PooledClient) RegenerateAppenderFileNameContext(ctx context.Context, appenderFileID string) (string, error) {
+	var result string
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.RegenerateAppenderFileNameContext(ctx, appenderFileID)
+		return err
+	})
+	return result, err
+}
+
+// VerifyVersion 检查存储服务器上报的版本是否与连接池的协议方言一致
+func (pc *PooledClient) VerifyVersion() error {
+	return pc.withTrackerFailover(context.Background(), func(client *Client) error {
+		return client.VerifyVersion()
+	})
+}
+
+// UploadSlaveFile 上传slave文件
+func (pc *PooledClient) UploadSlaveFile(masterFileID string, prefix string, extName string, data []byte) (string, error) {
+	var result string
+	err := pc.withTrackerFailover(context.Background(), func(client *Client) (err error) {
+		result, err = client.UploadSlaveFile(masterFileID, prefix, extName, data)
+		return err
+	})
+	return result, err
+}
+
+// UploadSlaveFrom 以流的方式上传slave文件
+func (pc *PooledClient) UploadSlaveFrom(ctx context.Context, masterFileID string, prefix string, extName string, size int64, r io.Reader) (string, error) {
+	var result string
+	err := pc.withTrackerFailover(ctx, func(client *Client) (err error) {
+		result, err = client.UploadSlaveFrom(ctx, masterFileID, prefix, extName, size, r)
+		return err
+	})
+	return result, err
 }
\ No newline at end of file
@@ -0,0 +1,323 @@
+package fastdfs
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fastdfs-migration-system/internal/models"
+)
+
+// startTestTracker 启动一个只响应ACTIVE_TEST的tracker，其他命令返回错误状态
+func startTestTracker(t *testing.T) (string, int) {
+	return startTrackerWith(t, serveTestTracker)
+}
+
+// startTrackerWith 启动一个由serve处理每个连接的tracker
+func startTrackerWith(t *testing.T, serve func(conn net.Conn)) (string, int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	acceptDone := make(chan struct{})
+	go func() {
+		defer close(acceptDone)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		// 等accept循环退出后再Wait，避免wg.Add与wg.Wait并发
+		<-acceptDone
+		wg.Wait()
+	})
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port
+}
+
+// serveTestTracker 依次处理连接上的请求，直到连接关闭
+func serveTestTracker(conn net.Conn) {
+	for {
+		buf := make([]byte, FDFS_PROTO_PKG_LEN_SIZE+2)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		length := int64(binary.BigEndian.Uint64(buf[0:8]))
+		if _, err := io.CopyN(io.Discard, conn, length); err != nil {
+			return
+		}
+
+		var status byte
+		if buf[8] != FDFS_PROTO_CMD_ACTIVE_TEST {
+			status = 22
+		}
+		writeTestHeader(conn, 0, status)
+	}
+}
+
+func TestPooledClient_ConcurrentUse(t *testing.T) {
+	addr, port := startTestTracker(t)
+
+	pool := NewConnectionPool(addr, port, 4)
+	defer pool.Close()
+	client := NewPooledClient(pool)
+
+	const workers = 16
+	const iterations = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*iterations)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				var err error
+				if j%2 == 0 {
+					err = client.Ping()
+				} else {
+					err = client.PingContext(context.Background())
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Unexpected concurrent ping error: %v", err)
+	}
+
+	if available := pool.Available(); available > pool.Size() {
+		t.Errorf("Expected at most %d idle connections, got %d", pool.Size(), available)
+	}
+}
+
+func TestPooledClient_DiscardsBrokenConnection(t *testing.T) {
+	addr, port := startTestTracker(t)
+
+	pool := NewConnectionPool(addr, port, 2)
+	defer pool.Close()
+	client := NewPooledClient(pool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := client.PingContext(ctx); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// 被取消的连接不会放回连接池，后续调用使用新的连接
+	if err := client.Ping(); err != nil {
+		t.Errorf("Unexpected ping error after cancellation: %v", err)
+	}
+}
+
+func TestConnectionPool_MaxOpenBlocks(t *testing.T) {
+	addr, port := startTestTracker(t)
+
+	config := DefaultPoolConfig()
+	config.MaxOpen = 1
+	pool := NewConnectionPoolWithConfig([]models.TrackerEndpoint{{Addr: addr, Port: port}}, config)
+	defer pool.Close()
+
+	client, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded when max open is reached, got %v", err)
+	}
+
+	// 归还连接后阻塞的获取应立即得到该连接
+	done := make(chan *Client)
+	go func() {
+		next, err := pool.GetContext(context.Background())
+		if err != nil {
+			t.Errorf("Unexpected get error: %v", err)
+		}
+		done <- next
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.Put(client)
+
+	select {
+	case next := <-done:
+		if next != client {
+			t.Error("Expected released connection to be reused")
+		}
+		pool.Put(next)
+	case <-time.After(time.Second):
+		t.Fatal("Blocked acquire was not woken by Put")
+	}
+
+	stats := pool.Stats()
+	if stats.Waits != 2 || stats.WaitDuration <= 0 {
+		t.Errorf("Expected 2 waits with non-zero duration, got %+v", stats)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || stats.InUse != 0 || stats.Idle != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestConnectionPool_ValidateAfter(t *testing.T) {
+	addr, port := startTestTracker(t)
+
+	config := DefaultPoolConfig()
+	config.ValidateAfter = time.Hour
+	pool := NewConnectionPoolWithConfig([]models.TrackerEndpoint{{Addr: addr, Port: port}}, config)
+	defer pool.Close()
+
+	client, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	pool.Put(client)
+
+	// 空闲时间未超过ValidateAfter的连接不做校验，即使底层连接已断开也会直接复用
+	client.conn.Close()
+	reused, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	if reused != client {
+		t.Error("Expected idle connection to be reused without validation")
+	}
+	pool.Discard(reused)
+
+	stats := pool.Stats()
+	if stats.ValidationFailures != 0 || stats.Discarded != 1 || stats.InUse != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestConnectionPool_ValidationFailure(t *testing.T) {
+	addr, port := startTestTracker(t)
+
+	config := DefaultPoolConfig()
+	config.ValidateAfter = time.Nanosecond
+	pool := NewConnectionPoolWithConfig([]models.TrackerEndpoint{{Addr: addr, Port: port}}, config)
+	defer pool.Close()
+
+	client, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	pool.Put(client)
+
+	// 关闭底层连接模拟被tracker断开的空闲连接
+	client.conn.Close()
+
+	fresh, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	defer pool.Put(fresh)
+
+	if fresh == client {
+		t.Error("Expected broken idle connection to be replaced")
+	}
+	if stats := pool.Stats(); stats.ValidationFailures != 1 || stats.Hits != 0 || stats.Misses != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestConnectionPool_FailedTrackerClosesIdle(t *testing.T) {
+	var broken atomic.Bool
+	_, failingPort := startTrackerWith(t, func(conn net.Conn) {
+		serveTrackerCommands(conn, func(command byte) bool {
+			if broken.Load() {
+				return false
+			}
+			respondFetch(conn, command)
+			return true
+		})
+	})
+	_, livePort := startTrackerWith(t, serveFetchTracker)
+
+	config := DefaultPoolConfig()
+	config.ValidateAfter = time.Hour
+	pool := NewConnectionPoolWithConfig([]models.TrackerEndpoint{
+		{Addr: "127.0.0.1", Port: failingPort},
+		{Addr: "127.0.0.1", Port: livePort},
+	}, config)
+	defer pool.Close()
+
+	// 轮询建立的三个连接依次连接到两个tracker，最后放回的连接最先被复用
+	var clients []*Client
+	for i := 0; i < 3; i++ {
+		client, err := pool.Get()
+		if err != nil {
+			t.Fatalf("Unexpected get error: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	for _, client := range clients {
+		pool.Put(client)
+	}
+
+	// 复用的空闲连接不做校验，请求失败后该tracker的其余空闲连接也被关闭，重试使用另一个tracker的连接
+	broken.Store(true)
+	client := NewPooledClient(pool)
+	if _, err := client.QueryFetchStorage("group1", "M00/00/00/a.jpg"); err != nil {
+		t.Fatalf("Expected query to be retried on the live tracker, got %v", err)
+	}
+
+	stats := pool.Stats()
+	if stats.Discarded != 2 || stats.Idle != 1 {
+		t.Errorf("Expected idle connections of the failed tracker to be closed, got %+v", stats)
+	}
+	if trackers := pool.TrackerStats(); trackers[0].Healthy || trackers[0].TotalFailures != 1 {
+		t.Errorf("Expected failing tracker to be unhealthy, got %+v", trackers[0])
+	}
+}
+
+func TestConnectionPool_IdleEviction(t *testing.T) {
+	addr, port := startTestTracker(t)
+
+	config := DefaultPoolConfig()
+	config.IdleTimeout = 10 * time.Millisecond
+	config.EvictionInterval = 5 * time.Millisecond
+	pool := NewConnectionPoolWithConfig([]models.TrackerEndpoint{{Addr: addr, Port: port}}, config)
+	defer pool.Close()
+
+	client, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	pool.Put(client)
+
+	deadline := time.Now().Add(time.Second)
+	for pool.Available() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stats := pool.Stats()
+	if stats.Idle != 0 || stats.Evicted != 1 {
+		t.Errorf("Expected idle connection to be evicted, got %+v", stats)
+	}
+}
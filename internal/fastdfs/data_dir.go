@@ -0,0 +1,164 @@
+package fastdfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DataDirConfig 数据目录枚举配置
+type DataDirConfig struct {
+	GroupName      string // 存储服务器所属的组名
+	StorePath      string // 存储路径，即storage.conf中的store_pathN，其下为data/<XX>/<YY>/
+	StorePathIndex int    // 存储路径索引，store_path0对应文件ID中的M00
+}
+
+// DataDirEnumerator 遍历存储服务器的本地数据目录并还原文件ID，实现FileEnumerator
+// 用于binlog损坏但可以读取存储路径的节点；合并存储在trunk文件中的小文件无法从目录还原，会被跳过
+type DataDirEnumerator struct {
+	config  DataDirConfig
+	dataDir string
+	subdirs []string // 尚未遍历的二级子目录，如00/1A
+	files   []string // 当前子目录中尚未返回的文件名
+	current string   // 当前子目录
+	skipped int
+}
+
+// NewDataDirEnumerator 创建数据目录枚举器
+func NewDataDirEnumerator(config DataDirConfig) (*DataDirEnumerator, error) {
+	if config.GroupName == "" {
+		return nil, fmt.Errorf("group name is required")
+	}
+	if config.StorePathIndex < 0 || config.StorePathIndex > 0xFF {
+		return nil, fmt.Errorf("invalid store path index: %d", config.StorePathIndex)
+	}
+
+	dataDir := filepath.Join(config.StorePath, "data")
+	subdirs, err := listSubdirs(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []string
+	for _, subdir1 := range subdirs {
+		children, err := listSubdirs(filepath.Join(dataDir, subdir1))
+		if err != nil {
+			return nil, err
+		}
+		for _, subdir2 := range children {
+			all = append(all, subdir1+"/"+subdir2)
+		}
+	}
+
+	return &DataDirEnumerator{config: config, dataDir: dataDir, subdirs: all}, nil
+}
+
+// Next 获取下一个文件，没有更多文件时返回io.EOF
+// 文件大小取自磁盘，CRC32仅在文件名中的值可靠时设置
+func (e *DataDirEnumerator) Next(ctx context.Context) (*FileInfo, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(e.files) == 0 {
+			if len(e.subdirs) == 0 {
+				return nil, io.EOF
+			}
+			e.current = e.subdirs[0]
+			e.subdirs = e.subdirs[1:]
+
+			entries, err := os.ReadDir(filepath.Join(e.dataDir, filepath.FromSlash(e.current)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read data directory %s: %w", e.current, err)
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					e.files = append(e.files, entry.Name())
+				}
+			}
+			continue
+		}
+
+		name := e.files[0]
+		e.files = e.files[1:]
+
+		fileInfo, ok, err := e.fileInfo(name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			e.skipped++
+			continue
+		}
+		return fileInfo, nil
+	}
+}
+
+// Skipped 获取已跳过的文件数，包括元数据文件、临时文件、trunk文件和未写完的文件
+func (e *DataDirEnumerator) Skipped() int {
+	return e.skipped
+}
+
+// Close 实现FileEnumerator，数据目录枚举不持有打开的文件
+func (e *DataDirEnumerator) Close() error {
+	return nil
+}
+
+// fileInfo 由目录中的文件名还原文件信息，不是FastDFS数据文件时返回false
+func (e *DataDirEnumerator) fileInfo(name string) (*FileInfo, bool, error) {
+	// 元数据文件和隐藏文件不是数据文件
+	if strings.HasSuffix(name, FDFS_STORAGE_META_FILE_EXT) || strings.HasPrefix(name, ".") {
+		return nil, false, nil
+	}
+
+	// trunk文件以序号命名，临时文件使用随机名称，都无法解码为文件ID
+	fileName := fmt.Sprintf("M%02X/%s/%s", e.config.StorePathIndex, e.current, name)
+	info, err := DecodeFileID(e.config.GroupName + "/" + fileName)
+	if err != nil || info.IsTrunk {
+		return nil, false, nil
+	}
+
+	// 链接文件（slave链接）按链接本身计，Stat跟随链接获取目标文件的大小
+	stat, err := os.Stat(filepath.Join(e.dataDir, filepath.FromSlash(e.current), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to stat %s: %w", fileName, err)
+	}
+
+	// 普通文件的最终大小记录在文件名中，大小不一致说明仍在写入
+	if info.HasEmbeddedSize() && stat.Size() != info.FileSize {
+		return nil, false, nil
+	}
+
+	fileInfo := info.ToFileInfo()
+	fileInfo.FileSize = stat.Size()
+	return fileInfo, true, nil
+}
+
+// listSubdirs 列出目录下以两位十六进制命名的子目录，按名称排序
+func listSubdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	var subdirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() || len(entry.Name()) != 2 {
+			continue
+		}
+		if _, err := parseHexField(entry.Name()); err != nil {
+			continue
+		}
+		subdirs = append(subdirs, entry.Name())
+	}
+	sort.Strings(subdirs)
+	return subdirs, nil
+}
@@ -0,0 +1,71 @@
+package fastdfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataDirEnumerator(t *testing.T) {
+	storePath := t.TempDir()
+	write := func(subdir string, name string, size int) {
+		t.Helper()
+		dir := filepath.Join(storePath, "data", subdir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	ip := [4]byte{10, 0, 0, 1}
+	normal := encodeTestFileName(ip, 1640995200, 10, 0x12345678, "jpg")[FDFS_LOGIC_FILE_PATH_LEN:]
+	appender := encodeTestFileName(ip, 1640995200, FDFS_APPENDER_FILE_SIZE, 0, "log")[FDFS_LOGIC_FILE_PATH_LEN:]
+	incomplete := encodeTestFileName(ip, 1640995300, 4096, 0, "bin")[FDFS_LOGIC_FILE_PATH_LEN:]
+
+	write("00/00", normal, 10)
+	write("00/00", normal+FDFS_STORAGE_META_FILE_EXT, 8)
+	write("00/00", ".hidden", 1)
+	write("00/00", "000001", 64)
+	write("0A/FF", appender, 300)
+	write("0A/FF", incomplete, 100)
+	write("sync", "binlog.000", 10)
+
+	enumerator, err := NewDataDirEnumerator(DataDirConfig{GroupName: "group1", StorePath: storePath, StorePathIndex: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer enumerator.Close()
+
+	var files []*FileInfo
+	for {
+		file, err := enumerator.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		files = append(files, file)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d: %+v", len(files), files)
+	}
+	if files[0].GroupName != "group1" || files[0].FileName != "M01/00/00/"+normal || files[0].FileSize != 10 || files[0].CRC32 != 0x12345678 {
+		t.Errorf("Unexpected normal file: %+v", files[0])
+	}
+	if files[1].FileName != "M01/0A/FF/"+appender || files[1].FileSize != 300 || files[1].CRC32 != 0 {
+		t.Errorf("Unexpected appender file: %+v", files[1])
+	}
+	if enumerator.Skipped() != 4 {
+		t.Errorf("Expected 4 skipped files, got %d", enumerator.Skipped())
+	}
+
+	if _, err := NewDataDirEnumerator(DataDirConfig{GroupName: "group1", StorePath: filepath.Join(storePath, "missing")}); err == nil {
+		t.Error("Expected error for missing store path")
+	}
+}
@@ -0,0 +1,114 @@
+package fastdfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version FastDFS版本号，如V5.07的Major为5、Minor为7
+type Version struct {
+	Major int
+	Minor int
+}
+
+// ParseVersion 解析版本号，支持配置中使用的5.0.7格式和存储服务器上报的5.07格式，可以带V前缀
+func ParseVersion(s string) (Version, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "V"), "v")
+	parts := strings.Split(trimmed, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid FastDFS version: %q", s)
+	}
+
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid FastDFS version: %q", s)
+		}
+		numbers[i] = n
+	}
+
+	// 5.0.7和5.07都表示V5.07，三段式的后两段合起来是两位的次版本号
+	version := Version{Major: numbers[0], Minor: numbers[1]}
+	if len(numbers) == 3 {
+		if numbers[2] > 9 {
+			return Version{}, fmt.Errorf("invalid FastDFS version: %q", s)
+		}
+		version.Minor = numbers[1]*10 + numbers[2]
+	}
+	return version, nil
+}
+
+// String 获取FastDFS格式的版本号，如5.07
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%02d", v.Major, v.Minor)
+}
+
+// AtLeast 判断版本是否不低于other
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	return v.Minor >= other.Minor
+}
+
+// commandSince 只有较新版本才支持的命令及其最低版本
+var commandSince = map[byte]Version{
+	STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME: {Major: 6, Minor: 2},
+}
+
+// Dialect 集群的FastDFS版本，用于按版本检查命令是否可用
+// 客户端用到的命令中，5.x与6.x只在commandSince列出的命令上有差异：
+// 存储服务器统计信息的记录布局相同，
+// 文件名中的源存储服务器字段是storage ID还是IP由字段的取值区分，与版本无关
+type Dialect struct {
+	Version Version
+}
+
+// DialectForVersion 根据集群配置的版本选择协议方言，目前支持5.x和6.x
+func DialectForVersion(version string) (*Dialect, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v.Major {
+	case 5, 6:
+		return &Dialect{Version: v}, nil
+	}
+	return nil, fmt.Errorf("unsupported FastDFS version %s: %w", version, ErrUnsupported)
+}
+
+// Supports 判断该版本是否支持命令
+func (d *Dialect) Supports(command byte) bool {
+	since, limited := commandSince[command]
+	return !limited || d.Version.AtLeast(since)
+}
+
+// CheckStorageVersions 检查tracker上报的存储服务器版本是否与方言的主版本一致
+// 尚未上报版本的存储服务器被忽略
+func (d *Dialect) CheckStorageVersions(groups []*GroupInfo) error {
+	for _, group := range groups {
+		for _, storage := range group.Storages {
+			if storage.Version == "" {
+				continue
+			}
+
+			reported, err := ParseVersion(storage.Version)
+			if err != nil {
+				return fmt.Errorf("storage %s in %s reports %w", storage.IPAddr, group.GroupName, err)
+			}
+			if reported.Major != d.Version.Major {
+				return fmt.Errorf("configured version %s, storage %s in %s reports %s: %w",
+					d.Version, storage.IPAddr, group.GroupName, reported, ErrVersionMismatch)
+			}
+		}
+	}
+	return nil
+}
+
+// unsupportedError 创建命令不被集群版本支持的错误
+func (d *Dialect) unsupportedError(op string, command byte) error {
+	return fmt.Errorf("%s requires FastDFS %s or later, cluster version is %s: %w", op, commandSince[command], d.Version, ErrUnsupported)
+}
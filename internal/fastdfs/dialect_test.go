@@ -0,0 +1,90 @@
+package fastdfs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Version
+	}{
+		{"5.0.7", Version{5, 7}},
+		{"5.07", Version{5, 7}},
+		{"V6.06", Version{6, 6}},
+		{"6.0.6", Version{6, 6}},
+		{"5.1.2", Version{5, 12}},
+		{"5.12", Version{5, 12}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "6", "6.x", "5.0.10", "1.2.3.4"} {
+		if _, err := ParseVersion(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+
+	if s := (Version{5, 7}).String(); s != "5.07" {
+		t.Errorf("Expected 5.07, got %s", s)
+	}
+}
+
+func TestDialectForVersion(t *testing.T) {
+	v5, err := DialectForVersion("5.0.7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v5.Supports(STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME) {
+		t.Errorf("Unexpected 5.x dialect: %+v", v5)
+	}
+	if !v5.Supports(STORAGE_PROTO_CMD_UPLOAD_FILE) {
+		t.Error("Expected 5.x dialect to support upload")
+	}
+
+	v6, err := DialectForVersion("6.0.6")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !v6.Supports(STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME) {
+		t.Errorf("Unexpected 6.x dialect: %+v", v6)
+	}
+
+	if v601, _ := DialectForVersion("6.0.1"); v601.Supports(STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME) {
+		t.Error("Expected 6.01 not to support regenerating appender file names")
+	}
+
+	if _, err := DialectForVersion("4.0.8"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for 4.x, got %v", err)
+	}
+}
+
+func TestDialect_CheckStorageVersions(t *testing.T) {
+	dialect, _ := DialectForVersion("5.0.7")
+
+	groups := []*GroupInfo{{
+		GroupName: "group1",
+		Storages: []*StorageInfo{
+			{IPAddr: "10.0.0.1", Version: "5.07"},
+			{IPAddr: "10.0.0.2", Version: ""},
+		},
+	}}
+	if err := dialect.CheckStorageVersions(groups); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	groups[0].Storages = append(groups[0].Storages, &StorageInfo{IPAddr: "10.0.0.3", Version: "6.06"})
+	if err := dialect.CheckStorageVersions(groups); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package fastdfs
+
+import (
+	"context"
+	"io"
+)
+
+// FileEnumerator 待迁移文件的枚举来源，如存储服务器的binlog或数据目录
+// 没有更多文件时Next返回io.EOF
+type FileEnumerator interface {
+	Next(ctx context.Context) (*FileInfo, error)
+	Close() error
+}
+
+// FileIDEnumerator 按给定的文件ID列表枚举文件，实现FileEnumerator
+type FileIDEnumerator struct {
+	fileIDs []string
+}
+
+// NewFileIDEnumerator 创建文件ID列表枚举器
+func NewFileIDEnumerator(fileIDs []string) *FileIDEnumerator {
+	return &FileIDEnumerator{fileIDs: fileIDs}
+}
+
+// Next 获取下一个文件，没有更多文件时返回io.EOF
+// 文件信息由文件ID解码，无法解码的文件ID只设置组名和文件名
+func (e *FileIDEnumerator) Next(ctx context.Context) (*FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(e.fileIDs) == 0 {
+		return nil, io.EOF
+	}
+
+	fileID := e.fileIDs[0]
+	e.fileIDs = e.fileIDs[1:]
+
+	if info, err := DecodeFileID(fileID); err == nil {
+		return info.ToFileInfo(), nil
+	}
+
+	groupName, fileName, err := parseFileID(fileID)
+	if err != nil {
+		return nil, err
+	}
+	return &FileInfo{GroupName: groupName, FileName: fileName}, nil
+}
+
+// Close 实现FileEnumerator
+func (e *FileIDEnumerator) Close() error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package fastdfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// FastDFS服务端以Linux errno作为响应状态码，以下错误可通过errors.Is与具体的失败原因匹配
+var (
+	ErrFileNotFound    = errors.New("file not found")          // ENOENT
+	ErrFileExists      = errors.New("file already exists")     // EEXIST
+	ErrNoSpace         = errors.New("no space left")           // ENOSPC
+	ErrBusy            = errors.New("server busy")             // EBUSY、EAGAIN
+	ErrInvalidArgument = errors.New("invalid argument")        // EINVAL
+	ErrPermission      = errors.New("operation not permitted") // EPERM、EACCES
+)
+
+// 与集群版本相关的错误
+var (
+	ErrUnsupported     = errors.New("not supported by cluster version") // 集群版本不支持请求的命令
+	ErrVersionMismatch = errors.New("cluster version mismatch")         // 配置的版本与存储服务器上报的版本不一致
+)
+
+// FastDFS响应状态码（Linux errno）
+const (
+	statusEPERM  = 1
+	statusENOENT = 2
+	statusEAGAIN = 11
+	statusEACCES = 13
+	statusEBUSY  = 16
+	statusEEXIST = 17
+	statusEINVAL = 22
+	statusENOSPC = 28
+)
+
+// statusErrors 状态码到错误的映射
+var statusErrors = map[byte]error{
+	statusEPERM:  ErrPermission,
+	statusENOENT: ErrFileNotFound,
+	statusEAGAIN: ErrBusy,
+	statusEACCES: ErrPermission,
+	statusEBUSY:  ErrBusy,
+	statusEEXIST: ErrFileExists,
+	statusEINVAL: ErrInvalidArgument,
+	statusENOSPC: ErrNoSpace,
+}
+
+// StatusError 服务端返回非0状态码的错误
+type StatusError struct {
+	Op     string // 失败的操作，如download、upload
+	Status byte   // 响应状态码
+}
+
+// newStatusError 创建状态码错误
+func newStatusError(op string, status byte) error {
+	return &StatusError{Op: op, Status: status}
+}
+
+// Error 实现error接口
+func (e *StatusError) Error() string {
+	if err, ok := statusErrors[e.Status]; ok {
+		return fmt.Sprintf("%s failed with status: %d (%v)", e.Op, e.Status, err)
+	}
+	return fmt.Sprintf("%s failed with status: %d", e.Op, e.Status)
+}
+
+// Unwrap 返回状态码对应的错误，未知状态码返回nil
+func (e *StatusError) Unwrap() error {
+	return statusErrors[e.Status]
+}
+
+// NetworkError 与tracker或存储服务器通信时发生的网络错误
+type NetworkError struct {
+	Op   string // dial、read或write
+	Addr string // 服务器地址
+	Err  error
+}
+
+// newNetworkError 包装网络错误，err为nil时返回nil
+func newNetworkError(op string, addr string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &NetworkError{Op: op, Addr: addr, Err: err}
+}
+
+// Error 实现error接口
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Addr, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable 判断错误是否可以重试
+// 网络错误和服务端繁忙是暂时性的，文件不存在、参数错误等由请求本身决定，重试不会成功；
+// ctx被取消或超时说明调用方已放弃该操作，也不再重试
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrBusy) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return false
+	}
+
+	var netErr *NetworkError
+	var opErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// HTTPStatus 获取错误对应的HTTP状态码，供API返回
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrFileExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNoSpace):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, ErrBusy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case IsRetryable(err):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
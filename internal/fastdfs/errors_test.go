@@ -0,0 +1,89 @@
+package fastdfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status byte
+		want   error
+	}{
+		{2, ErrFileNotFound},
+		{17, ErrFileExists},
+		{28, ErrNoSpace},
+		{16, ErrBusy},
+		{11, ErrBusy},
+		{22, ErrInvalidArgument},
+		{13, ErrPermission},
+	}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("wrapped: %w", newStatusError("download", tt.status))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Expected status %d to match %v, got %v", tt.status, tt.want, err)
+		}
+	}
+
+	err := newStatusError("download", 5)
+	if errors.Unwrap(err) != nil {
+		t.Errorf("Expected unknown status not to map to an error, got %v", errors.Unwrap(err))
+	}
+	if err.Error() != "download failed with status: 5" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"network", newNetworkError("read", "127.0.0.1:23000", io.ErrUnexpectedEOF), true},
+		{"wrapped network", fmt.Errorf("failed to receive download response: %w", newNetworkError("read", "127.0.0.1:23000", io.EOF)), true},
+		{"busy", newStatusError("upload", 16), true},
+		{"not found", newStatusError("download", 2), false},
+		{"no space", newStatusError("upload", 28), false},
+		{"unknown status", newStatusError("upload", 5), false},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"other", errors.New("invalid file ID format"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: expected IsRetryable=%v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{newStatusError("download", 2), http.StatusNotFound},
+		{newStatusError("upload", 17), http.StatusConflict},
+		{newStatusError("upload", 22), http.StatusBadRequest},
+		{newStatusError("upload", 28), http.StatusInsufficientStorage},
+		{newStatusError("upload", 16), http.StatusServiceUnavailable},
+		{newNetworkError("dial", "127.0.0.1:22122", io.EOF), http.StatusBadGateway},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{fmt.Errorf("regenerate: %w", ErrUnsupported), http.StatusNotImplemented},
+		{errors.New("unexpected"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := HTTPStatus(tt.err); got != tt.want {
+			t.Errorf("Expected HTTP status %d for %v, got %d", tt.want, tt.err, got)
+		}
+	}
+}
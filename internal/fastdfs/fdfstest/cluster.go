@@ -0,0 +1,408 @@
+// Package fdfstest 提供进程内模拟的FastDFS集群，供测试在本机端口上运行真实的协议交互
+package fdfstest
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/models"
+)
+
+// storageTotalMB 模拟存储服务器上报的容量
+const storageTotalMB = 10240
+
+// firstStorageID 第一个存储服务器的storage ID，与storage_ids.conf的惯例一致从100001开始
+const firstStorageID = 100001
+
+// defaultVersion 模拟集群默认的FastDFS版本
+const defaultVersion = "6.0.6"
+
+// Cluster 模拟的FastDFS集群，包含一个tracker和若干组存储服务器
+// 同组的存储服务器共享文件，相当于组内同步没有延迟
+type Cluster struct {
+	tracker *server
+	groups  []*group
+	faults  *faultInjector
+	version fastdfs.Version
+	nextID  int         // 下一个分配的storage ID
+	useIDs  atomic.Bool // 是否在文件名中记录storage ID
+	mu      sync.RWMutex
+}
+
+// group 一个组及其文件
+type group struct {
+	name     string
+	port     int
+	storages []*Storage
+	files    map[string]*file
+	next     int // 下一个用于上传的存储服务器
+	mu       sync.Mutex
+}
+
+// file 存储在模拟集群中的文件
+type file struct {
+	data       []byte
+	metadata   map[string]string
+	createTime int64
+	source     *Storage // 上传文件的源存储服务器
+	appender   bool
+}
+
+// Storage 模拟的存储服务器
+type Storage struct {
+	cluster *Cluster
+	group   *group
+	server  *server
+	ipAddr  string
+	id      string
+}
+
+// NewCluster 启动一个模拟集群，每个组包含一个存储服务器；未指定组时创建group1
+// 与httptest.NewServer一样，监听失败时panic
+func NewCluster(groupNames ...string) *Cluster {
+	if len(groupNames) == 0 {
+		groupNames = []string{"group1"}
+	}
+
+	c := &Cluster{faults: &faultInjector{}, nextID: firstStorageID}
+	c.SetVersion(defaultVersion)
+	c.tracker = newServer(TargetTracker, "127.0.0.1:0", c.handleTracker, c.faults)
+	for _, name := range groupNames {
+		g := &group{name: name, files: make(map[string]*file)}
+		c.groups = append(c.groups, g)
+		c.AddStorage(name)
+	}
+	return c
+}
+
+// AddStorage 向组中添加一个存储服务器，组内的存储服务器使用相同端口和不同的回环地址
+func (c *Cluster) AddStorage(groupName string) *Storage {
+	g := c.group(groupName)
+	if g == nil {
+		panic(fmt.Sprintf("fdfstest: group %s not found", groupName))
+	}
+
+	c.mu.Lock()
+	id := strconv.Itoa(c.nextID)
+	c.nextID++
+	c.mu.Unlock()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ipAddr := fmt.Sprintf("127.0.0.%d", len(g.storages)+1)
+	storage := &Storage{cluster: c, group: g, ipAddr: ipAddr, id: id}
+	storage.server = newServer(ipAddr, net.JoinHostPort(ipAddr, strconv.Itoa(g.port)), storage.handle, c.faults)
+	if g.port == 0 {
+		g.port = storage.server.port()
+	}
+	g.storages = append(g.storages, storage)
+	return storage
+}
+
+// TrackerAddr 获取tracker的IP地址
+func (c *Cluster) TrackerAddr() string {
+	return c.tracker.ipAddr()
+}
+
+// TrackerPort 获取tracker的端口
+func (c *Cluster) TrackerPort() int {
+	return c.tracker.port()
+}
+
+// SetVersion 设置模拟的FastDFS版本，如5.0.7，影响存储服务器上报的版本和支持的命令
+// 默认为6.0.6，版本号无效时panic
+func (c *Cluster) SetVersion(version string) {
+	v, err := fastdfs.ParseVersion(version)
+	if err != nil {
+		panic(fmt.Sprintf("fdfstest: %v", err))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = v
+}
+
+// UseStorageIDs 模拟use_storage_id = true且id_type_in_filename = id的集群：
+// 之后上传的文件名中记录源存储服务器的storage ID，tracker上报的ID也从IP改为storage ID
+func (c *Cluster) UseStorageIDs() {
+	c.useIDs.Store(true)
+}
+
+// Version 获取模拟的FastDFS版本
+func (c *Cluster) Version() fastdfs.Version {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Model 获取指向该模拟集群的集群配置，版本号使用配置中的5.0.7格式
+func (c *Cluster) Model(id string) *models.Cluster {
+	version := c.Version()
+	return &models.Cluster{
+		ID:          id,
+		Name:        id,
+		Version:     fmt.Sprintf("%d.%d.%d", version.Major, version.Minor/10, version.Minor%10),
+		TrackerAddr: c.TrackerAddr(),
+		TrackerPort: c.TrackerPort(),
+		Status:      models.ClusterStatusActive,
+	}
+}
+
+// Client 创建一个已连接到tracker的客户端
+func (c *Cluster) Client() (*fastdfs.Client, error) {
+	client := fastdfs.NewClient(c.TrackerAddr(), c.TrackerPort())
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Storages 获取组内的存储服务器
+func (c *Cluster) Storages(groupName string) []*Storage {
+	g := c.group(groupName)
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*Storage(nil), g.storages...)
+}
+
+// PutFile 不经过协议直接向组中写入文件，用于准备源集群的数据
+func (c *Cluster) PutFile(groupName string, extName string, data []byte, metadata map[string]string) (string, error) {
+	g := c.group(groupName)
+	if g == nil {
+		return "", fmt.Errorf("group %s not found", groupName)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.storages) == 0 {
+		return "", fmt.Errorf("group %s has no storage", groupName)
+	}
+	fileName := g.store(g.storages[0], extName, data, false)
+	if len(metadata) > 0 {
+		g.files[fileName].metadata = copyMetadata(metadata)
+	}
+	return groupName + "/" + fileName, nil
+}
+
+// File 获取文件内容，文件不存在时返回false
+func (c *Cluster) File(fileID string) ([]byte, bool) {
+	g, fileName := c.lookup(fileID)
+	if g == nil {
+		return nil, false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, exists := g.files[fileName]
+	if !exists {
+		return nil, false
+	}
+	return append([]byte(nil), f.data...), true
+}
+
+// Metadata 获取文件的元数据，文件不存在时返回nil
+func (c *Cluster) Metadata(fileID string) map[string]string {
+	g, fileName := c.lookup(fileID)
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, exists := g.files[fileName]
+	if !exists {
+		return nil
+	}
+	return copyMetadata(f.metadata)
+}
+
+// FileIDs 获取组内所有文件的ID，按文件名排序
+func (c *Cluster) FileIDs(groupName string) []string {
+	g := c.group(groupName)
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fileIDs := make([]string, 0, len(g.files))
+	for fileName := range g.files {
+		fileIDs = append(fileIDs, groupName+"/"+fileName)
+	}
+	sort.Strings(fileIDs)
+	return fileIDs
+}
+
+// SetScenario 设置故障场景，nil表示不再注入故障
+func (c *Cluster) SetScenario(scenario *Scenario) {
+	c.faults.setScenario(scenario)
+}
+
+// FaultHistory 获取当前场景下已注入的故障，按注入顺序排列
+func (c *Cluster) FaultHistory() []FaultEvent {
+	return c.faults.history()
+}
+
+// Close 关闭tracker和所有存储服务器
+func (c *Cluster) Close() {
+	c.tracker.close()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, g := range c.groups {
+		g.mu.Lock()
+		storages := g.storages
+		g.mu.Unlock()
+		for _, storage := range storages {
+			storage.server.close()
+		}
+	}
+}
+
+// group 按组名查找组
+func (c *Cluster) group(name string) *group {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, g := range c.groups {
+		if g.name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// lookup 按文件ID查找组和组内文件名
+func (c *Cluster) lookup(fileID string) (*group, string) {
+	for i := 0; i < len(fileID); i++ {
+		if fileID[i] == '/' {
+			return c.group(fileID[:i]), fileID[i+1:]
+		}
+	}
+	return nil, ""
+}
+
+// Addr 获取存储服务器的IP地址
+func (s *Storage) Addr() string {
+	return s.ipAddr
+}
+
+// Port 获取存储服务器的端口
+func (s *Storage) Port() int {
+	return s.server.port()
+}
+
+// ID 获取存储服务器的storage ID
+func (s *Storage) ID() string {
+	return s.id
+}
+
+// reportedID 获取tracker上报的存储服务器ID，未启用storage ID时FastDFS以IP作为ID
+func (s *Storage) reportedID() string {
+	if s.cluster.useIDs.Load() {
+		return s.id
+	}
+	return s.ipAddr
+}
+
+// Stop 使存储服务器下线：关闭所有连接并停止监听，文件仍保留在组内
+func (s *Storage) Stop() {
+	s.server.stop()
+}
+
+// Start 在原地址上重新启动已下线的存储服务器
+func (s *Storage) Start() error {
+	return s.server.start()
+}
+
+// store 保存新文件并按FastDFS规则生成文件名，调用方需持有锁
+func (g *group) store(source *Storage, extName string, data []byte, appender bool) string {
+	f := &file{
+		data:       append([]byte(nil), data...),
+		metadata:   map[string]string{},
+		createTime: time.Now().Unix(),
+		source:     source,
+		appender:   appender,
+	}
+
+	// 同一秒内上传的相同内容会得到相同的文件名，此时递增时间戳直到文件名唯一
+	for {
+		fileName := encodeFileName(f, extName, len(g.files))
+		if _, exists := g.files[fileName]; !exists {
+			g.files[fileName] = f
+			return fileName
+		}
+		f.createTime++
+	}
+}
+
+// encodeFileName 生成文件名: M00/<subdir1>/<subdir2>/<base64(source+create_timestamp+file_size+crc32)><随机数字>.<ext>
+// 启用storage ID时source为源存储服务器的ID，否则为其IP
+func encodeFileName(f *file, extName string, seq int) string {
+	info := &fastdfs.FileIDInfo{
+		Padding:      randomPadding(extName),
+		SubDir1:      seq / 256 % 256,
+		SubDir2:      seq % 256,
+		SourceIPAddr: f.source.ipAddr,
+		CreateTime:   f.createTime,
+		FileSize:     int64(len(f.data)),
+		CRC32:        crc32Of(f.data),
+		ExtName:      extName,
+		IsAppender:   f.appender,
+	}
+	if f.source.cluster.useIDs.Load() {
+		info.SourceStorageID = f.source.id
+	}
+
+	fileName, err := info.EncodeFileName()
+	if err != nil {
+		panic(fmt.Sprintf("fdfstest: %v", err))
+	}
+	return fileName
+}
+
+// randomPadding 与storage_format_ext_name一致，生成补在扩展名前的随机数字：
+// 扩展名不足6个字符时补齐到6个，没有扩展名时为7个
+func randomPadding(extName string) string {
+	n := fastdfs.FDFS_FILE_EXT_NAME_MAX_LEN + 1
+	if extName != "" {
+		n = fastdfs.FDFS_FILE_EXT_NAME_MAX_LEN - len(extName)
+	}
+
+	padding := make([]byte, max(n, 0))
+	for i := range padding {
+		padding[i] = byte('0' + rand.Intn(10))
+	}
+	return string(padding)
+}
+
+// crc32Of 计算文件内容的CRC32校验值
+func crc32Of(data []byte) uint32 {
+	return crc32.ChecksumIEEE(data)
+}
+
+// copyMetadata 复制元数据
+func copyMetadata(metadata map[string]string) map[string]string {
+	copied := make(map[string]string, len(metadata))
+	for name, value := range metadata {
+		copied[name] = value
+	}
+	return copied
+}
@@ -0,0 +1,294 @@
+package fdfstest
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+// Action 注入的故障类型
+type Action string
+
+const (
+	// ActionReset 发送部分响应体后以RST中断连接
+	ActionReset Action = "reset"
+	// ActionTruncate 发送部分响应体后正常关闭连接
+	ActionTruncate Action = "truncate"
+	// ActionDelay 延迟一段时间后再处理请求
+	ActionDelay Action = "delay"
+	// ActionStatus 不执行请求，直接返回指定的错误状态码
+	ActionStatus Action = "status"
+	// ActionDown 节点下线：关闭所有连接并停止监听，直到被重新启动
+	ActionDown Action = "down"
+	// ActionCorrupt 翻转请求体的最后一个字节后再执行请求，模拟上传内容在传输中损坏
+	ActionCorrupt Action = "corrupt"
+)
+
+// 故障规则的目标
+const (
+	TargetAny     = "*"       // 所有节点
+	TargetTracker = "tracker" // tracker
+	TargetStorage = "storage" // 所有存储服务器，也可以使用存储服务器的IP指定单个节点
+)
+
+// commandNames 故障规则中使用的命令名称
+var commandNames = map[string]byte{
+	"upload":          fastdfs.STORAGE_PROTO_CMD_UPLOAD_FILE,
+	"upload_appender": fastdfs.STORAGE_PROTO_CMD_UPLOAD_APPENDER_FILE,
+	"upload_slave":    fastdfs.STORAGE_PROTO_CMD_UPLOAD_SLAVE_FILE,
+	"download":        fastdfs.STORAGE_PROTO_CMD_DOWNLOAD_FILE,
+	"delete":          fastdfs.STORAGE_PROTO_CMD_DELETE_FILE,
+	"file_info":       fastdfs.STORAGE_PROTO_CMD_QUERY_FILE_INFO,
+	"set_metadata":    fastdfs.STORAGE_PROTO_CMD_SET_METADATA,
+	"get_metadata":    fastdfs.STORAGE_PROTO_CMD_GET_METADATA,
+	"append":          fastdfs.STORAGE_PROTO_CMD_APPEND_FILE,
+	"modify":          fastdfs.STORAGE_PROTO_CMD_MODIFY_FILE,
+	"truncate":        fastdfs.STORAGE_PROTO_CMD_TRUNCATE_FILE,
+	"query_store":     fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITH_GROUP_ONE,
+	"query_fetch":     fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ONE,
+	"query_fetch_all": fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL,
+	"query_update":    fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_UPDATE,
+	"list_groups":     fastdfs.TRACKER_PROTO_CMD_SERVER_LIST_ALL_GROUPS,
+	"list_group":      fastdfs.TRACKER_PROTO_CMD_SERVER_LIST_ONE_GROUP,
+	"list_storage":    fastdfs.TRACKER_PROTO_CMD_SERVER_LIST_STORAGE,
+	"active_test":     fastdfs.FDFS_PROTO_CMD_ACTIVE_TEST,
+}
+
+// Rule 一条故障规则
+// 规则按顺序匹配，第一条命中的规则生效；同一请求最多注入一个故障
+type Rule struct {
+	Target      string        // 目标节点：*、tracker、storage或存储服务器IP
+	Command     string        // 命令名称，*匹配所有命令
+	Action      Action        // 故障类型
+	After       int64         // reset和truncate在中断前发送的响应体字节数
+	Delay       time.Duration // delay的延迟时间
+	Status      byte          // status返回的状态码
+	Nth         int           // 从第Nth次匹配的请求开始注入，0和1都表示第一次
+	Times       int           // 最多注入的次数，0表示不限
+	Probability float64       // 每次匹配时注入的概率，0表示总是注入
+
+	matched   int
+	triggered int
+}
+
+// Scenario 故障场景，相同的种子和相同的请求顺序总会注入相同的故障
+type Scenario struct {
+	Seed  int64
+	Rules []*Rule
+}
+
+// FaultEvent 一次已注入的故障
+type FaultEvent struct {
+	Target  string
+	Command byte
+	Action  Action
+}
+
+// String 获取故障的描述
+func (e FaultEvent) String() string {
+	return fmt.Sprintf("%s %s %s", e.Target, commandName(e.Command), e.Action)
+}
+
+// ParseScenario 解析故障场景脚本
+// 每行一条规则，格式为"<目标> <命令> <故障> [key=value ...]"，支持的参数为
+// after、delay、status、nth、times和prob；"seed <n>"设置随机种子，#开头的行为注释。例如:
+//
+//	seed 42
+//	storage download reset after=1024 times=1
+//	tracker * delay delay=50ms prob=0.5
+//	127.0.0.2 * down nth=3
+func ParseScenario(script string) (*Scenario, error) {
+	scenario := &Scenario{}
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if fields[0] == "seed" {
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: invalid seed", lineNo)
+			}
+			seed, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid seed: %w", lineNo, err)
+			}
+			scenario.Seed = seed
+			continue
+		}
+
+		rule, err := parseRule(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		scenario.Rules = append(scenario.Rules, rule)
+	}
+
+	return scenario, scanner.Err()
+}
+
+// parseRule 解析一条规则
+func parseRule(fields []string) (*Rule, error) {
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected <target> <command> <action>, got %q", strings.Join(fields, " "))
+	}
+
+	rule := &Rule{Target: fields[0], Command: fields[1], Action: Action(fields[2])}
+	if _, ok := commandNames[rule.Command]; !ok && rule.Command != "*" {
+		return nil, fmt.Errorf("unknown command %q", rule.Command)
+	}
+	switch rule.Action {
+	case ActionReset, ActionTruncate, ActionDelay, ActionStatus, ActionDown, ActionCorrupt:
+	default:
+		return nil, fmt.Errorf("unknown action %q", rule.Action)
+	}
+
+	for _, field := range fields[3:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter %q", field)
+		}
+
+		var err error
+		switch key {
+		case "after":
+			rule.After, err = strconv.ParseInt(value, 10, 64)
+		case "delay":
+			rule.Delay, err = time.ParseDuration(value)
+		case "status":
+			var status uint64
+			status, err = strconv.ParseUint(value, 10, 8)
+			rule.Status = byte(status)
+		case "nth":
+			rule.Nth, err = strconv.Atoi(value)
+		case "times":
+			rule.Times, err = strconv.Atoi(value)
+		case "prob":
+			rule.Probability, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("unknown parameter %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %q: %w", field, err)
+		}
+	}
+
+	if rule.Action == ActionStatus && rule.Status == 0 {
+		return nil, fmt.Errorf("status action requires a non-zero status")
+	}
+	return rule, nil
+}
+
+// faultInjector 按场景为请求选择要注入的故障，被集群内所有节点共享
+type faultInjector struct {
+	scenario *Scenario
+	rand     *rand.Rand
+	events   []FaultEvent
+	mu       sync.Mutex
+}
+
+// setScenario 替换当前场景并重置随机数和计数
+func (f *faultInjector) setScenario(scenario *Scenario) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.scenario = scenario
+	f.events = nil
+	if scenario != nil {
+		f.rand = rand.New(rand.NewSource(scenario.Seed))
+		for _, rule := range scenario.Rules {
+			rule.matched = 0
+			rule.triggered = 0
+		}
+	}
+}
+
+// match 获取请求要注入的故障，不注入时返回nil
+func (f *faultInjector) match(target string, command byte) *Rule {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.scenario == nil {
+		return nil
+	}
+
+	for _, rule := range f.scenario.Rules {
+		if !rule.matches(target, command) {
+			continue
+		}
+
+		rule.matched++
+		if rule.matched < rule.Nth {
+			continue
+		}
+		if rule.Times > 0 && rule.triggered >= rule.Times {
+			continue
+		}
+		if rule.Probability > 0 && f.rand.Float64() >= rule.Probability {
+			continue
+		}
+
+		rule.triggered++
+		f.events = append(f.events, FaultEvent{Target: target, Command: command, Action: rule.Action})
+		return rule
+	}
+	return nil
+}
+
+// history 获取已注入的故障
+func (f *faultInjector) history() []FaultEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FaultEvent(nil), f.events...)
+}
+
+// matches 判断规则是否适用于目标节点上的命令
+func (r *Rule) matches(target string, command byte) bool {
+	switch r.Target {
+	case TargetAny, target:
+	case TargetStorage:
+		if target == TargetTracker {
+			return false
+		}
+	default:
+		return false
+	}
+
+	if r.Command == "*" {
+		return true
+	}
+	if r.Command == "query_store" {
+		// 指定组和不指定组的存储查询都视为query_store
+		return command == fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITH_GROUP_ONE ||
+			command == fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITHOUT_GROUP_ONE
+	}
+	return commandNames[r.Command] == command
+}
+
+// commandName 获取命令的名称
+func commandName(command byte) string {
+	if command == fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITHOUT_GROUP_ONE {
+		return "query_store"
+	}
+	for name, value := range commandNames {
+		if value == command {
+			return name
+		}
+	}
+	return strconv.Itoa(int(command))
+}
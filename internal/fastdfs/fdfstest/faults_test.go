@@ -0,0 +1,245 @@
+package fdfstest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+func TestParseScenario(t *testing.T) {
+	scenario, err := ParseScenario(`
+# 下载中断一次，之后恢复正常
+seed 42
+storage download reset after=1024 times=1
+tracker * delay delay=50ms prob=0.5
+127.0.0.2 upload status status=28 nth=3
+`)
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+
+	if scenario.Seed != 42 || len(scenario.Rules) != 3 {
+		t.Fatalf("Unexpected scenario: %+v", scenario)
+	}
+
+	reset := scenario.Rules[0]
+	if reset.Target != TargetStorage || reset.Command != "download" || reset.Action != ActionReset || reset.After != 1024 || reset.Times != 1 {
+		t.Errorf("Unexpected reset rule: %+v", reset)
+	}
+	if delay := scenario.Rules[1]; delay.Delay != 50*time.Millisecond || delay.Probability != 0.5 {
+		t.Errorf("Unexpected delay rule: %+v", delay)
+	}
+	if status := scenario.Rules[2]; status.Target != "127.0.0.2" || status.Status != 28 || status.Nth != 3 {
+		t.Errorf("Unexpected status rule: %+v", status)
+	}
+
+	invalid := []string{
+		"storage download",
+		"storage unknown reset",
+		"storage download explode",
+		"storage download reset after=abc",
+		"storage download status",
+		"seed abc",
+	}
+	for _, script := range invalid {
+		if _, err := ParseScenario(script); err == nil {
+			t.Errorf("Expected parse error for %q", script)
+		}
+	}
+}
+
+func TestFault_ResetMidDownload(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	fileID, err := cluster.PutFile("group1", "bin", make([]byte, 4096), nil)
+	if err != nil {
+		t.Fatalf("Unexpected put error: %v", err)
+	}
+
+	scenario, _ := ParseScenario("storage download reset after=1000 times=1")
+	cluster.SetScenario(scenario)
+
+	_, err = client.DownloadFile(fileID)
+	if err == nil || !fastdfs.IsRetryable(err) {
+		t.Fatalf("Expected retryable error after reset, got %v", err)
+	}
+
+	data, err := client.DownloadFile(fileID)
+	if err != nil {
+		t.Fatalf("Expected download to succeed once the fault is used up, got %v", err)
+	}
+	if len(data) != 4096 {
+		t.Errorf("Expected 4096 bytes, got %d", len(data))
+	}
+
+	history := cluster.FaultHistory()
+	if len(history) != 1 || history[0].String() != "127.0.0.1 download reset" {
+		t.Errorf("Unexpected fault history: %v", history)
+	}
+}
+
+func TestFault_TruncatedResponse(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	fileID, err := cluster.PutFile("group1", "txt", []byte("truncated response"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected put error: %v", err)
+	}
+
+	cluster.SetScenario(&Scenario{Rules: []*Rule{
+		{Target: TargetStorage, Command: "file_info", Action: ActionTruncate, After: 10},
+	}})
+
+	if _, err := client.GetFileInfo(fileID); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF for truncated response, got %v", err)
+	}
+}
+
+func TestFault_Status(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	cluster.SetScenario(&Scenario{Rules: []*Rule{
+		{Target: TargetTracker, Command: "query_store", Action: ActionStatus, Status: 16, Nth: 2, Times: 1},
+	}})
+
+	for i, wantBusy := range []bool{false, true, false} {
+		_, err := client.UploadFile("group1", "a.txt", []byte("data"))
+		if wantBusy && !errors.Is(err, fastdfs.ErrBusy) {
+			t.Errorf("Upload %d: expected ErrBusy, got %v", i+1, err)
+		}
+		if !wantBusy && err != nil {
+			t.Errorf("Upload %d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	if n := len(cluster.FileIDs("group1")); n != 2 {
+		t.Errorf("Expected 2 uploaded files, got %d", n)
+	}
+}
+
+func TestFault_Corrupt(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	cluster.SetScenario(&Scenario{Rules: []*Rule{
+		{Target: TargetStorage, Command: "upload", Action: ActionCorrupt, Times: 1},
+	}})
+
+	corrupted, err := client.UploadFile("group1", "a.txt", []byte("data"))
+	if err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+	if data, _ := cluster.File(corrupted); len(data) != 4 || string(data) == "data" {
+		t.Errorf("Expected corrupted content of the same size, got %q", data)
+	}
+
+	intact, err := client.UploadFile("group1", "a.txt", []byte("data"))
+	if err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+	if data, _ := cluster.File(intact); string(data) != "data" {
+		t.Errorf("Expected intact content, got %q", data)
+	}
+}
+
+func TestFault_Delay(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	cluster.SetScenario(&Scenario{Rules: []*Rule{
+		{Target: TargetTracker, Command: "active_test", Action: ActionDelay, Delay: 200 * time.Millisecond, Times: 1},
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := client.PingContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded for slow tracker, got %v", err)
+	}
+}
+
+func TestFault_StorageDown(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	replica := cluster.AddStorage("group1")
+	primary := cluster.Storages("group1")[0]
+	client := newTestClient(t, cluster)
+
+	fileID, err := cluster.PutFile("group1", "txt", []byte("still readable"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected put error: %v", err)
+	}
+
+	cluster.SetScenario(&Scenario{Rules: []*Rule{
+		{Target: primary.Addr(), Command: "download", Action: ActionDown, Times: 1},
+	}})
+
+	// 第一个副本下线后从另一个副本读取
+	data, err := client.DownloadFile(fileID)
+	if err != nil {
+		t.Fatalf("Expected download from replica %s, got %v", replica.Addr(), err)
+	}
+	if string(data) != "still readable" {
+		t.Errorf("Unexpected content: %q", data)
+	}
+
+	// 下线的节点拒绝新连接，重新启动后恢复
+	if _, err := client.GetFileInfo(fileID); err != nil {
+		t.Fatalf("Expected file info from replica, got %v", err)
+	}
+	replica.Stop()
+	if _, err := client.GetFileInfo(fileID); err == nil {
+		t.Fatal("Expected error with all storages down")
+	}
+
+	if err := primary.Start(); err != nil {
+		t.Fatalf("Failed to restart storage: %v", err)
+	}
+	if _, err := client.GetFileInfo(fileID); err != nil {
+		t.Errorf("Expected file info after restart, got %v", err)
+	}
+}
+
+func TestScenario_Deterministic(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	run := func(seed int64) []bool {
+		cluster.SetScenario(&Scenario{Seed: seed, Rules: []*Rule{
+			{Target: TargetTracker, Command: "active_test", Action: ActionStatus, Status: 16, Probability: 0.5},
+		}})
+
+		results := make([]bool, 32)
+		for i := range results {
+			results[i] = client.Ping() != nil
+		}
+		return results
+	}
+
+	first := run(7)
+	second := run(7)
+	failures := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected same faults for the same seed, differ at request %d", i)
+		}
+		if first[i] {
+			failures++
+		}
+	}
+	if failures == 0 || failures == len(first) {
+		t.Errorf("Expected some but not all requests to fail, got %d of %d", failures, len(first))
+	}
+}
@@ -0,0 +1,185 @@
+package fdfstest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+func newTestClient(t *testing.T, cluster *Cluster) *fastdfs.Client {
+	client, err := cluster.Client()
+	if err != nil {
+		t.Fatalf("Failed to connect to tracker: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCluster_UploadDownload(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	data := []byte("hello fastdfs")
+	fileID, err := client.UploadFile("group1", "hello.txt", data)
+	if err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+
+	info, err := fastdfs.DecodeFileID(fileID)
+	if err != nil {
+		t.Fatalf("Expected a decodable file ID, got %s: %v", fileID, err)
+	}
+	if info.GroupName != "group1" || info.ExtName != "txt" || info.FileSize != int64(len(data)) {
+		t.Errorf("Unexpected decoded file ID: %+v", info)
+	}
+
+	downloaded, err := client.DownloadFile(fileID)
+	if err != nil {
+		t.Fatalf("Unexpected download error: %v", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Errorf("Expected %q, got %q", data, downloaded)
+	}
+
+	part, err := client.DownloadRange(fileID, 6, 4)
+	if err != nil {
+		t.Fatalf("Unexpected download range error: %v", err)
+	}
+	if string(part) != "fast" {
+		t.Errorf("Expected range %q, got %q", "fast", part)
+	}
+
+	fileInfo, err := client.GetFileInfo(fileID)
+	if err != nil {
+		t.Fatalf("Unexpected file info error: %v", err)
+	}
+	if fileInfo.FileSize != int64(len(data)) || fileInfo.CRC32 != info.CRC32 || fileInfo.SourceIPAddr != "127.0.0.1" {
+		t.Errorf("Unexpected file info: %+v", fileInfo)
+	}
+}
+
+func TestCluster_MetadataAndDelete(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	fileID, err := cluster.PutFile("group1", "jpg", []byte("image"), map[string]string{"width": "100"})
+	if err != nil {
+		t.Fatalf("Unexpected put error: %v", err)
+	}
+
+	if err := client.SetMetadata(fileID, map[string]string{"height": "200"}, fastdfs.MetadataMerge); err != nil {
+		t.Fatalf("Unexpected set metadata error: %v", err)
+	}
+
+	metadata, err := client.GetMetadata(fileID)
+	if err != nil {
+		t.Fatalf("Unexpected get metadata error: %v", err)
+	}
+	if len(metadata) != 2 || metadata["width"] != "100" || metadata["height"] != "200" {
+		t.Errorf("Unexpected metadata: %v", metadata)
+	}
+
+	if err := client.DeleteFile(fileID); err != nil {
+		t.Fatalf("Unexpected delete error: %v", err)
+	}
+	if _, exists := cluster.File(fileID); exists {
+		t.Error("Expected file to be deleted")
+	}
+
+	if _, err := client.DownloadFile(fileID); !errors.Is(err, fastdfs.ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound after delete, got %v", err)
+	}
+}
+
+func TestCluster_AppenderAndSlave(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	appenderID, err := client.UploadAppenderFile("group1", "app.log", []byte("line1\n"))
+	if err != nil {
+		t.Fatalf("Unexpected upload appender error: %v", err)
+	}
+	if !fastdfs.IsAppenderFile(appenderID) {
+		t.Errorf("Expected appender file ID, got %s", appenderID)
+	}
+
+	if err := client.AppendFile(appenderID, []byte("line2\n")); err != nil {
+		t.Fatalf("Unexpected append error: %v", err)
+	}
+	if err := client.TruncateFile(appenderID, 8); err != nil {
+		t.Fatalf("Unexpected truncate error: %v", err)
+	}
+	if data, _ := cluster.File(appenderID); string(data) != "line1\nli" {
+		t.Errorf("Unexpected appender content: %q", data)
+	}
+
+	masterID, err := client.UploadFile("group1", "photo.jpg", []byte("master"))
+	if err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+	slaveID, err := client.UploadSlaveFile(masterID, "_150x150", "jpg", []byte("thumb"))
+	if err != nil {
+		t.Fatalf("Unexpected upload slave error: %v", err)
+	}
+
+	masterKey, prefix, err := fastdfs.SplitSlaveFileID(slaveID)
+	if err != nil || prefix != "_150x150" {
+		t.Errorf("Expected slave file ID with prefix _150x150, got %s (%v)", slaveID, err)
+	}
+	if wantKey, _, _ := fastdfs.SplitSlaveFileID(masterID); masterKey != wantKey {
+		t.Errorf("Expected slave to belong to %s, got %s", wantKey, masterKey)
+	}
+
+	// 与真实存储服务器一样，文件名在扩展名前有随机数字，master文件名中的大小和CRC32可以直接使用
+	master, err := fastdfs.DecodeFileID(masterID)
+	if err != nil || master.IsSlave || len(master.Padding) != 3 || !master.HasEmbeddedSize() || master.FileSize != 6 {
+		t.Errorf("Expected padded master file name, got %s: %+v (%v)", masterID, master, err)
+	}
+
+	// slave文件的扩展名与master不同时仍能按master文件名分组
+	pngSlaveID, err := client.UploadSlaveFile(masterID, "_small", "png", []byte("small"))
+	if err != nil {
+		t.Fatalf("Unexpected upload slave error: %v", err)
+	}
+	groups := fastdfs.GroupMasterSlaveFiles([]string{pngSlaveID, slaveID, masterID})
+	if len(groups) != 1 || groups[0].MasterFileID != masterID || len(groups[0].SlaveFileIDs) != 2 {
+		t.Errorf("Expected one master/slave group, got %+v", groups)
+	}
+	if prefix, err := fastdfs.SlavePrefixOf(masterID, pngSlaveID); err != nil || prefix != "_small" {
+		t.Errorf("Expected prefix _small, got %q (%v)", prefix, err)
+	}
+}
+
+func TestCluster_Replicas(t *testing.T) {
+	cluster := NewCluster("group1")
+	defer cluster.Close()
+	replica := cluster.AddStorage("group1")
+	client := newTestClient(t, cluster)
+
+	fileID, err := cluster.PutFile("group1", "txt", []byte("replicated"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected put error: %v", err)
+	}
+
+	groupName, fileName := fileID[:len("group1")], fileID[len("group1")+1:]
+	servers, err := client.QueryFetchAllStorages(groupName, fileName)
+	if err != nil {
+		t.Fatalf("Unexpected query error: %v", err)
+	}
+	if len(servers) != 2 || servers[1].IPAddr != replica.Addr() || servers[1].Port != replica.Port() {
+		t.Errorf("Expected both storages, got %+v", servers)
+	}
+
+	groups, err := client.GetTopology()
+	if err != nil {
+		t.Fatalf("Unexpected topology error: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0].Storages) != 2 || !groups[0].Storages[1].IsActive() {
+		t.Errorf("Unexpected topology: %+v", groups)
+	}
+}
@@ -0,0 +1,260 @@
+package fdfstest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+// 模拟服务器返回的错误状态码（Linux errno）
+const (
+	statusENOENT = 2
+	statusEEXIST = 17
+	statusEINVAL = 22
+)
+
+// handler 处理一个请求，返回响应状态码和响应体
+type handler func(command byte, body []byte) (byte, []byte)
+
+// server 按FastDFS协议收发数据包的TCP服务器
+// 服务器可以被停止后在原地址上重新启动，用于模拟节点下线和恢复
+type server struct {
+	name     string // 故障规则匹配的目标名称，tracker为"tracker"，存储服务器为其IP
+	addr     string
+	listener net.Listener
+	handle   handler
+	faults   *faultInjector
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// newServer 在addr上监听并开始处理连接，监听失败时panic
+func newServer(name string, addr string, handle handler, faults *faultInjector) *server {
+	s := &server{
+		name:   name,
+		handle: handle,
+		faults: faults,
+		conns:  make(map[net.Conn]struct{}),
+		closed: true,
+	}
+	if err := s.listen(addr); err != nil {
+		panic("fdfstest: " + err.Error())
+	}
+	return s
+}
+
+// listen 在addr上监听并启动接受连接的goroutine
+func (s *server) listen(addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s.listener = listener
+	s.addr = listener.Addr().String()
+	s.closed = false
+
+	s.wg.Add(1)
+	go s.acceptLoop(listener)
+	return nil
+}
+
+// ipAddr 获取监听的IP地址
+func (s *server) ipAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host, _, _ := net.SplitHostPort(s.addr)
+	return host
+}
+
+// port 获取监听的端口
+func (s *server) port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, port, _ := net.SplitHostPort(s.addr)
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// start 在原地址上重新启动已停止的服务器
+func (s *server) start() error {
+	s.mu.Lock()
+	addr := s.addr
+	s.mu.Unlock()
+
+	return s.listen(addr)
+}
+
+// stop 停止监听并关闭所有连接，不等待处理中的请求结束
+func (s *server) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// close 停止服务器并等待所有连接处理结束
+func (s *server) close() {
+	s.stop()
+	s.wg.Wait()
+}
+
+// acceptLoop 接受连接直到监听被关闭
+func (s *server) acceptLoop(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// serve 依次处理连接上的请求，直到连接关闭或收到QUIT
+func (s *server) serve(conn net.Conn) {
+	for {
+		header := make([]byte, fastdfs.FDFS_PROTO_PKG_LEN_SIZE+2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+
+		length := int64(binary.BigEndian.Uint64(header[0:8]))
+		command := header[8]
+		if length < 0 {
+			return
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		if command == fastdfs.FDFS_PROTO_CMD_QUIT {
+			return
+		}
+
+		// 在执行请求前注入的故障：节点下线、延迟响应、错误状态码和损坏的请求
+		rule := s.faults.match(s.name, command)
+		if rule != nil {
+			switch rule.Action {
+			case ActionDown:
+				s.stop()
+				return
+			case ActionDelay:
+				time.Sleep(rule.Delay)
+			case ActionStatus:
+				if err := writeResponse(conn, rule.Status, nil); err != nil {
+					return
+				}
+				continue
+			case ActionCorrupt:
+				if len(body) > 0 {
+					body[len(body)-1] ^= 0xFF
+				}
+			}
+		}
+
+		var status byte
+		var resp []byte
+		if command != fastdfs.FDFS_PROTO_CMD_ACTIVE_TEST {
+			status, resp = s.handle(command, body)
+		}
+
+		// 请求执行后注入的故障：响应体只发送一部分
+		if rule != nil && (rule.Action == ActionReset || rule.Action == ActionTruncate) {
+			writePartialResponse(conn, status, resp, rule.After, rule.Action == ActionReset)
+			return
+		}
+
+		if err := writeResponse(conn, status, resp); err != nil {
+			return
+		}
+	}
+}
+
+// writeResponse 发送响应头和响应体
+func writeResponse(w io.Writer, status byte, body []byte) error {
+	buf := make([]byte, fastdfs.FDFS_PROTO_PKG_LEN_SIZE+2, fastdfs.FDFS_PROTO_PKG_LEN_SIZE+2+len(body))
+	binary.BigEndian.PutUint64(buf[0:8], uint64(len(body)))
+	buf[8] = fastdfs.FDFS_PROTO_CMD_RESP
+	buf[9] = status
+	_, err := w.Write(append(buf, body...))
+	return err
+}
+
+// writePartialResponse 发送完整长度的响应头和前after字节的响应体后断开连接
+// reset为true时以RST中断连接，否则正常关闭，客户端读到的响应被截断
+func writePartialResponse(conn net.Conn, status byte, body []byte, after int64, reset bool) {
+	if after > int64(len(body)) {
+		after = int64(len(body))
+	}
+
+	header := make([]byte, fastdfs.FDFS_PROTO_PKG_LEN_SIZE+2)
+	binary.BigEndian.PutUint64(header[0:8], uint64(len(body)))
+	header[8] = fastdfs.FDFS_PROTO_CMD_RESP
+	header[9] = status
+	conn.Write(append(header, body[:after]...))
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok && reset {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// putString 将s写入定长字段，超出部分被截断
+func putString(buf []byte, s string) {
+	copy(buf, s)
+}
+
+// getString 读取定长字段并去掉末尾的填充
+func getString(buf []byte) string {
+	end := len(buf)
+	for end > 0 && buf[end-1] == 0 {
+		end--
+	}
+	return string(buf[:end])
+}
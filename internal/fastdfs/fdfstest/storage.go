@@ -0,0 +1,370 @@
+package fdfstest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+// handle 处理存储服务器请求
+func (s *Storage) handle(command byte, body []byte) (byte, []byte) {
+	g := s.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch command {
+	case fastdfs.STORAGE_PROTO_CMD_UPLOAD_FILE, fastdfs.STORAGE_PROTO_CMD_UPLOAD_APPENDER_FILE:
+		return s.upload(command == fastdfs.STORAGE_PROTO_CMD_UPLOAD_APPENDER_FILE, body)
+	case fastdfs.STORAGE_PROTO_CMD_UPLOAD_SLAVE_FILE:
+		return s.uploadSlave(body)
+	case fastdfs.STORAGE_PROTO_CMD_DOWNLOAD_FILE:
+		return s.download(body)
+	case fastdfs.STORAGE_PROTO_CMD_DELETE_FILE:
+		return s.delete(body)
+	case fastdfs.STORAGE_PROTO_CMD_QUERY_FILE_INFO:
+		return s.queryFileInfo(body)
+	case fastdfs.STORAGE_PROTO_CMD_SET_METADATA:
+		return s.setMetadata(body)
+	case fastdfs.STORAGE_PROTO_CMD_GET_METADATA:
+		return s.getMetadata(body)
+	case fastdfs.STORAGE_PROTO_CMD_APPEND_FILE:
+		return s.append(body)
+	case fastdfs.STORAGE_PROTO_CMD_MODIFY_FILE:
+		return s.modify(body)
+	case fastdfs.STORAGE_PROTO_CMD_TRUNCATE_FILE:
+		return s.truncate(body)
+	case fastdfs.STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME:
+		// V6.02之前的存储服务器不认识该命令
+		if !s.cluster.Version().AtLeast(fastdfs.Version{Major: 6, Minor: 2}) {
+			return statusEINVAL, nil
+		}
+		return s.regenerateAppenderFileName(body)
+	}
+
+	return statusEINVAL, nil
+}
+
+// upload 处理上传请求: store_path_index(1) + file_size(8) + ext_name(6) + file_content
+func (s *Storage) upload(appender bool, body []byte) (byte, []byte) {
+	const headerLen = 1 + fastdfs.FDFS_PROTO_PKG_LEN_SIZE + fastdfs.FDFS_FILE_EXT_NAME_MAX_LEN
+	if len(body) < headerLen {
+		return statusEINVAL, nil
+	}
+
+	size := int64(binary.BigEndian.Uint64(body[1:9]))
+	extName := getString(body[9:headerLen])
+	data := body[headerLen:]
+	if size != int64(len(data)) {
+		return statusEINVAL, nil
+	}
+
+	fileName := s.group.store(s, extName, data, appender)
+	return 0, uploadResponse(s.group.name, fileName)
+}
+
+// uploadSlave 处理slave文件上传请求
+// master_filename_len(8) + file_size(8) + prefix_name(16) + ext_name(6) + master_filename + file_content
+func (s *Storage) uploadSlave(body []byte) (byte, []byte) {
+	const headerLen = 2*fastdfs.FDFS_PROTO_PKG_LEN_SIZE + fastdfs.FDFS_FILE_PREFIX_MAX_LEN + fastdfs.FDFS_FILE_EXT_NAME_MAX_LEN
+	if len(body) < headerLen {
+		return statusEINVAL, nil
+	}
+
+	nameLen := int64(binary.BigEndian.Uint64(body[0:8]))
+	size := int64(binary.BigEndian.Uint64(body[8:16]))
+	prefix := getString(body[16 : 16+fastdfs.FDFS_FILE_PREFIX_MAX_LEN])
+	extName := getString(body[16+fastdfs.FDFS_FILE_PREFIX_MAX_LEN : headerLen])
+	if nameLen <= 0 || size < 0 || int64(len(body)-headerLen) != nameLen+size || prefix == "" {
+		return statusEINVAL, nil
+	}
+
+	masterFileName := string(body[headerLen : headerLen+int(nameLen)])
+	master, exists := s.group.files[masterFileName]
+	if !exists {
+		return statusENOENT, nil
+	}
+
+	// slave文件名由master文件名去掉扩展名后加上前缀和slave文件的扩展名构成
+	fileName := masterFileName
+	if dot := strings.LastIndexByte(fileName, '.'); dot > fastdfs.FDFS_LOGIC_FILE_PATH_LEN {
+		fileName = fileName[:dot]
+	}
+	fileName += prefix
+	if extName != "" {
+		fileName += "." + extName
+	}
+	if _, exists := s.group.files[fileName]; exists {
+		return statusEEXIST, nil
+	}
+
+	s.group.files[fileName] = &file{
+		data:       append([]byte(nil), body[headerLen+int(nameLen):]...),
+		metadata:   map[string]string{},
+		createTime: master.createTime,
+		source:     s,
+	}
+	return 0, uploadResponse(s.group.name, fileName)
+}
+
+// download 处理下载请求: file_offset(8) + download_bytes(8) + group_name(16) + file_name
+func (s *Storage) download(body []byte) (byte, []byte) {
+	const headerLen = 2*fastdfs.FDFS_PROTO_PKG_LEN_SIZE + fastdfs.FDFS_GROUP_NAME_MAX_LEN
+	if len(body) <= headerLen {
+		return statusEINVAL, nil
+	}
+
+	offset := int64(binary.BigEndian.Uint64(body[0:8]))
+	length := int64(binary.BigEndian.Uint64(body[8:16]))
+	f, status := s.file(body[16:headerLen], body[headerLen:])
+	if f == nil {
+		return status, nil
+	}
+
+	size := int64(len(f.data))
+	if offset < 0 || offset > size || length < 0 {
+		return statusEINVAL, nil
+	}
+	end := size
+	if length > 0 && offset+length < size {
+		end = offset + length
+	}
+	return 0, append([]byte(nil), f.data[offset:end]...)
+}
+
+// delete 处理删除请求: group_name(16) + file_name
+func (s *Storage) delete(body []byte) (byte, []byte) {
+	if len(body) <= fastdfs.FDFS_GROUP_NAME_MAX_LEN {
+		return statusEINVAL, nil
+	}
+
+	f, status := s.file(body[:fastdfs.FDFS_GROUP_NAME_MAX_LEN], body[fastdfs.FDFS_GROUP_NAME_MAX_LEN:])
+	if f == nil {
+		return status, nil
+	}
+	delete(s.group.files, string(body[fastdfs.FDFS_GROUP_NAME_MAX_LEN:]))
+	return 0, nil
+}
+
+// queryFileInfo 处理文件信息查询，响应格式: file_size(8) + create_timestamp(8) + crc32(8) + source_ip_addr(16)
+func (s *Storage) queryFileInfo(body []byte) (byte, []byte) {
+	if len(body) <= fastdfs.FDFS_GROUP_NAME_MAX_LEN {
+		return statusEINVAL, nil
+	}
+
+	f, status := s.file(body[:fastdfs.FDFS_GROUP_NAME_MAX_LEN], body[fastdfs.FDFS_GROUP_NAME_MAX_LEN:])
+	if f == nil {
+		return status, nil
+	}
+
+	resp := make([]byte, 3*fastdfs.FDFS_PROTO_PKG_LEN_SIZE+fastdfs.IP_ADDRESS_SIZE)
+	binary.BigEndian.PutUint64(resp[0:8], uint64(len(f.data)))
+	binary.BigEndian.PutUint64(resp[8:16], uint64(f.createTime))
+	binary.BigEndian.PutUint64(resp[16:24], uint64(crc32Of(f.data)))
+	putString(resp[24:], f.source.ipAddr)
+	return 0, resp
+}
+
+// setMetadata 处理设置元数据请求
+// filename_len(8) + meta_len(8) + op_flag(1) + group_name(16) + file_name + meta_data
+func (s *Storage) setMetadata(body []byte) (byte, []byte) {
+	const headerLen = 2*fastdfs.FDFS_PROTO_PKG_LEN_SIZE + 1 + fastdfs.FDFS_GROUP_NAME_MAX_LEN
+	if len(body) < headerLen {
+		return statusEINVAL, nil
+	}
+
+	nameLen := int64(binary.BigEndian.Uint64(body[0:8]))
+	metaLen := int64(binary.BigEndian.Uint64(body[8:16]))
+	flag := body[16]
+	if nameLen <= 0 || metaLen < 0 || int64(len(body)-headerLen) != nameLen+metaLen {
+		return statusEINVAL, nil
+	}
+	if flag != fastdfs.STORAGE_SET_METADATA_FLAG_OVERWRITE && flag != fastdfs.STORAGE_SET_METADATA_FLAG_MERGE {
+		return statusEINVAL, nil
+	}
+
+	f, status := s.file(body[17:headerLen], body[headerLen:headerLen+int(nameLen)])
+	if f == nil {
+		return status, nil
+	}
+
+	metadata := unpackMetadata(body[headerLen+int(nameLen):])
+	if flag == fastdfs.STORAGE_SET_METADATA_FLAG_OVERWRITE {
+		f.metadata = metadata
+	} else {
+		for name, value := range metadata {
+			f.metadata[name] = value
+		}
+	}
+	return 0, nil
+}
+
+// getMetadata 处理获取元数据请求: group_name(16) + file_name
+func (s *Storage) getMetadata(body []byte) (byte, []byte) {
+	if len(body) <= fastdfs.FDFS_GROUP_NAME_MAX_LEN {
+		return statusEINVAL, nil
+	}
+
+	f, status := s.file(body[:fastdfs.FDFS_GROUP_NAME_MAX_LEN], body[fastdfs.FDFS_GROUP_NAME_MAX_LEN:])
+	if f == nil {
+		return status, nil
+	}
+	return 0, packMetadata(f.metadata)
+}
+
+// append 处理追加请求: filename_len(8) + file_size(8) + file_name + file_content
+func (s *Storage) append(body []byte) (byte, []byte) {
+	if len(body) < 2*fastdfs.FDFS_PROTO_PKG_LEN_SIZE {
+		return statusEINVAL, nil
+	}
+
+	nameLen := int64(binary.BigEndian.Uint64(body[0:8]))
+	size := int64(binary.BigEndian.Uint64(body[8:16]))
+	if nameLen <= 0 || size < 0 || int64(len(body)-16) != nameLen+size {
+		return statusEINVAL, nil
+	}
+
+	f, status := s.appenderFile(string(body[16 : 16+nameLen]))
+	if f == nil {
+		return status, nil
+	}
+	f.data = append(f.data, body[16+nameLen:]...)
+	return 0, nil
+}
+
+// modify 处理修改请求: filename_len(8) + file_offset(8) + modify_size(8) + file_name + file_content
+func (s *Storage) modify(body []byte) (byte, []byte) {
+	if len(body) < 3*fastdfs.FDFS_PROTO_PKG_LEN_SIZE {
+		return statusEINVAL, nil
+	}
+
+	nameLen := int64(binary.BigEndian.Uint64(body[0:8]))
+	offset := int64(binary.BigEndian.Uint64(body[8:16]))
+	size := int64(binary.BigEndian.Uint64(body[16:24]))
+	if nameLen <= 0 || size < 0 || int64(len(body)-24) != nameLen+size {
+		return statusEINVAL, nil
+	}
+
+	f, status := s.appenderFile(string(body[24 : 24+nameLen]))
+	if f == nil {
+		return status, nil
+	}
+	if offset < 0 || offset > int64(len(f.data)) {
+		return statusEINVAL, nil
+	}
+
+	content := body[24+nameLen:]
+	if end := offset + size; end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	copy(f.data[offset:], content)
+	return 0, nil
+}
+
+// truncate 处理截断请求: filename_len(8) + truncated_file_size(8) + file_name
+func (s *Storage) truncate(body []byte) (byte, []byte) {
+	if len(body) < 2*fastdfs.FDFS_PROTO_PKG_LEN_SIZE {
+		return statusEINVAL, nil
+	}
+
+	nameLen := int64(binary.BigEndian.Uint64(body[0:8]))
+	size := int64(binary.BigEndian.Uint64(body[8:16]))
+	if nameLen <= 0 || int64(len(body)-16) != nameLen {
+		return statusEINVAL, nil
+	}
+
+	f, status := s.appenderFile(string(body[16:]))
+	if f == nil {
+		return status, nil
+	}
+	if size < 0 || size > int64(len(f.data)) {
+		return statusEINVAL, nil
+	}
+	f.data = f.data[:size]
+	return 0, nil
+}
+
+// regenerateAppenderFileName 将appender文件转为普通文件并重新生成文件名，请求体为appender文件名
+func (s *Storage) regenerateAppenderFileName(body []byte) (byte, []byte) {
+	appenderFileName := string(body)
+	f, status := s.appenderFile(appenderFileName)
+	if f == nil {
+		return status, nil
+	}
+
+	extName := ""
+	if dot := strings.LastIndexByte(appenderFileName, '.'); dot > fastdfs.FDFS_LOGIC_FILE_PATH_LEN {
+		extName = appenderFileName[dot+1:]
+	}
+
+	fileName := s.group.store(f.source, extName, f.data, false)
+	s.group.files[fileName].metadata = f.metadata
+	delete(s.group.files, appenderFileName)
+	return 0, uploadResponse(s.group.name, fileName)
+}
+
+// file 按请求中的组名和文件名查找文件，调用方需持有组的锁
+func (s *Storage) file(groupName []byte, fileName []byte) (*file, byte) {
+	if getString(groupName) != s.group.name {
+		return nil, statusEINVAL
+	}
+	f, exists := s.group.files[string(fileName)]
+	if !exists {
+		return nil, statusENOENT
+	}
+	return f, 0
+}
+
+// appenderFile 查找appender文件，普通文件不允许追加、修改和截断
+func (s *Storage) appenderFile(fileName string) (*file, byte) {
+	f, exists := s.group.files[fileName]
+	if !exists {
+		return nil, statusENOENT
+	}
+	if !f.appender {
+		return nil, statusEINVAL
+	}
+	return f, 0
+}
+
+// uploadResponse 生成上传响应: group_name(16) + file_name
+func uploadResponse(groupName string, fileName string) []byte {
+	resp := make([]byte, fastdfs.FDFS_GROUP_NAME_MAX_LEN+len(fileName))
+	putString(resp[0:fastdfs.FDFS_GROUP_NAME_MAX_LEN], groupName)
+	copy(resp[fastdfs.FDFS_GROUP_NAME_MAX_LEN:], fileName)
+	return resp
+}
+
+// packMetadata 将元数据编码为name\x02value\x01name\x02value格式
+func packMetadata(metadata map[string]string) []byte {
+	var buf bytes.Buffer
+	first := true
+	for name, value := range metadata {
+		if !first {
+			buf.WriteByte(fastdfs.FDFS_RECORD_SEPERATOR)
+		}
+		buf.WriteString(name)
+		buf.WriteByte(fastdfs.FDFS_FIELD_SEPERATOR)
+		buf.WriteString(value)
+		first = false
+	}
+	return buf.Bytes()
+}
+
+// unpackMetadata 解析name\x02value\x01name\x02value格式的元数据
+func unpackMetadata(data []byte) map[string]string {
+	metadata := make(map[string]string)
+	for _, record := range bytes.Split(data, []byte{fastdfs.FDFS_RECORD_SEPERATOR}) {
+		if len(record) == 0 {
+			continue
+		}
+		fields := bytes.SplitN(record, []byte{fastdfs.FDFS_FIELD_SEPERATOR}, 2)
+		if len(fields) == 2 {
+			metadata[string(fields[0])] = string(fields[1])
+		} else {
+			metadata[string(fields[0])] = ""
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,72 @@
+package fdfstest
+
+import (
+	"testing"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+func TestCluster_StorageIDMode(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	cluster.UseStorageIDs()
+	replica := cluster.AddStorage("group1")
+	client := newTestClient(t, cluster)
+
+	// 轮询使第二次上传落在第二个存储服务器上
+	if _, err := client.UploadFile("group1", "a.txt", []byte("first")); err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+	fileID, err := client.UploadFile("group1", "b.txt", []byte("second"))
+	if err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+
+	info, err := fastdfs.DecodeFileID(fileID)
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+	if info.SourceStorageID != replica.ID() || info.SourceIPAddr != "" {
+		t.Fatalf("Expected file name to record storage ID %s, got %+v", replica.ID(), info)
+	}
+
+	source, err := client.GetSourceStorage(fileID)
+	if err != nil {
+		t.Fatalf("Unexpected source storage error: %v", err)
+	}
+	if source.IPAddr != replica.Addr() || source.Port != replica.Port() {
+		t.Errorf("Expected source storage %s:%d, got %+v", replica.Addr(), replica.Port(), source)
+	}
+
+	resolved, err := client.ResolveStorageID("group1", replica.ID())
+	if err != nil || resolved.IPAddr != replica.Addr() {
+		t.Errorf("Expected storage ID %s to resolve to %s, got %+v (%v)", replica.ID(), replica.Addr(), resolved, err)
+	}
+	if _, err := client.ResolveStorageID("group1", "999999"); err == nil {
+		t.Error("Expected error for unknown storage ID")
+	}
+
+	// 更新操作由tracker按文件名中的storage ID路由到源存储服务器，用不延迟的delay规则记录请求到达的节点
+	cluster.SetScenario(&Scenario{Rules: []*Rule{
+		{Target: TargetStorage, Command: "set_metadata", Action: ActionDelay},
+	}})
+	if err := client.SetMetadata(fileID, map[string]string{"k": "v"}, fastdfs.MetadataOverwrite); err != nil {
+		t.Fatalf("Unexpected set metadata error: %v", err)
+	}
+	if history := cluster.FaultHistory(); len(history) != 1 || history[0].Target != replica.Addr() {
+		t.Errorf("Expected set metadata on %s, got %v", replica.Addr(), history)
+	}
+
+	groups, err := client.GetTopology()
+	if err != nil {
+		t.Fatalf("Unexpected topology error: %v", err)
+	}
+	if id := groups[0].Storages[1].ID; id != replica.ID() {
+		t.Errorf("Expected tracker to report storage ID %s, got %s", replica.ID(), id)
+	}
+
+	data, err := client.DownloadFile(fileID)
+	if err != nil || string(data) != "second" {
+		t.Errorf("Unexpected download result %q (%v)", data, err)
+	}
+}
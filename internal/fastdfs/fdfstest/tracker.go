@@ -0,0 +1,208 @@
+package fdfstest
+
+import (
+	"encoding/binary"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+// handleTracker 处理tracker请求
+func (c *Cluster) handleTracker(command byte, body []byte) (byte, []byte) {
+	switch command {
+	case fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITHOUT_GROUP_ONE:
+		c.mu.RLock()
+		groups := c.groups
+		c.mu.RUnlock()
+		if len(groups) == 0 {
+			return statusENOENT, nil
+		}
+		return queryStore(groups[0])
+
+	case fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITH_GROUP_ONE:
+		if len(body) < fastdfs.FDFS_GROUP_NAME_MAX_LEN {
+			return statusEINVAL, nil
+		}
+		g := c.group(getString(body[:fastdfs.FDFS_GROUP_NAME_MAX_LEN]))
+		if g == nil {
+			return statusENOENT, nil
+		}
+		return queryStore(g)
+
+	case fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ONE,
+		fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_UPDATE,
+		fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL:
+		if len(body) <= fastdfs.FDFS_GROUP_NAME_MAX_LEN {
+			return statusEINVAL, nil
+		}
+		g := c.group(getString(body[:fastdfs.FDFS_GROUP_NAME_MAX_LEN]))
+		if g == nil {
+			return statusENOENT, nil
+		}
+		return queryFetch(g, command, string(body[fastdfs.FDFS_GROUP_NAME_MAX_LEN:]))
+
+	case fastdfs.TRACKER_PROTO_CMD_SERVER_LIST_ALL_GROUPS:
+		c.mu.RLock()
+		groups := c.groups
+		c.mu.RUnlock()
+		var resp []byte
+		for _, g := range groups {
+			resp = append(resp, g.stat()...)
+		}
+		return 0, resp
+
+	case fastdfs.TRACKER_PROTO_CMD_SERVER_LIST_ONE_GROUP:
+		if len(body) < fastdfs.FDFS_GROUP_NAME_MAX_LEN {
+			return statusEINVAL, nil
+		}
+		g := c.group(getString(body[:fastdfs.FDFS_GROUP_NAME_MAX_LEN]))
+		if g == nil {
+			return statusENOENT, nil
+		}
+		return 0, g.stat()
+
+	case fastdfs.TRACKER_PROTO_CMD_SERVER_LIST_STORAGE:
+		if len(body) < fastdfs.FDFS_GROUP_NAME_MAX_LEN {
+			return statusEINVAL, nil
+		}
+		g := c.group(getString(body[:fastdfs.FDFS_GROUP_NAME_MAX_LEN]))
+		if g == nil {
+			return statusENOENT, nil
+		}
+		return 0, g.storageStats(c.Version())
+	}
+
+	return statusEINVAL, nil
+}
+
+// queryStore 按轮询顺序选择组内用于上传的存储服务器
+// 响应格式: group_name(16) + ip_addr(15) + port(8) + store_path_index(1)
+func queryStore(g *group) (byte, []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.storages) == 0 {
+		return statusENOENT, nil
+	}
+	storage := g.storages[g.next%len(g.storages)]
+	g.next++
+
+	resp := make([]byte, fastdfs.TRACKER_QUERY_STORAGE_STORE_BODY_LEN)
+	putStorageAddr(resp, g.name, storage.ipAddr, g.port)
+	return 0, resp
+}
+
+// queryFetch 查询可以读取或更新文件的存储服务器
+// 更新操作返回文件的源存储服务器，FETCH_ALL在响应末尾附加组内其余存储服务器的IP
+func queryFetch(g *group, command byte, fileName string) (byte, []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.storages) == 0 {
+		return statusENOENT, nil
+	}
+
+	first := g.storages[0]
+	if command == fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_UPDATE {
+		if info, err := fastdfs.DecodeFileID(g.name + "/" + fileName); err == nil {
+			for _, storage := range g.storages {
+				if storage.ipAddr == info.SourceIPAddr || storage.id == info.SourceStorageID {
+					first = storage
+					break
+				}
+			}
+		}
+	}
+
+	resp := make([]byte, fastdfs.TRACKER_QUERY_STORAGE_FETCH_BODY_LEN)
+	putStorageAddr(resp, g.name, first.ipAddr, g.port)
+
+	if command == fastdfs.TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL {
+		for _, storage := range g.storages {
+			if storage == first {
+				continue
+			}
+			ipAddr := make([]byte, fastdfs.IP_ADDRESS_SIZE-1)
+			putString(ipAddr, storage.ipAddr)
+			resp = append(resp, ipAddr...)
+		}
+	}
+	return 0, resp
+}
+
+// putStorageAddr 写入group_name(16) + ip_addr(15) + port(8)
+func putStorageAddr(buf []byte, groupName string, ipAddr string, port int) {
+	putString(buf[0:fastdfs.FDFS_GROUP_NAME_MAX_LEN], groupName)
+	offset := fastdfs.FDFS_GROUP_NAME_MAX_LEN
+	putString(buf[offset:offset+fastdfs.IP_ADDRESS_SIZE-1], ipAddr)
+	offset += fastdfs.IP_ADDRESS_SIZE - 1
+	binary.BigEndian.PutUint64(buf[offset:offset+fastdfs.FDFS_PROTO_PKG_LEN_SIZE], uint64(port))
+}
+
+// stat 生成组统计信息记录
+func (g *group) stat() []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	freeMB := storageTotalMB - g.usedMB()
+	fields := []int64{
+		storageTotalMB,         // total_mb
+		freeMB,                 // free_mb
+		0,                      // trunk_free_mb
+		int64(len(g.storages)), // storage_count
+		int64(g.port),          // storage_port
+		0,                      // storage_http_port
+		int64(len(g.storages)), // active_count
+		0,                      // current_write_server
+		1,                      // store_path_count
+		256,                    // subdir_count_per_path
+		0,                      // current_trunk_file_id
+	}
+
+	data := make([]byte, fastdfs.TRACKER_GROUP_STAT_LEN)
+	putString(data[0:fastdfs.FDFS_GROUP_NAME_MAX_LEN+1], g.name)
+	offset := fastdfs.FDFS_GROUP_NAME_MAX_LEN + 1
+	for _, field := range fields {
+		binary.BigEndian.PutUint64(data[offset:offset+fastdfs.FDFS_PROTO_PKG_LEN_SIZE], uint64(field))
+		offset += fastdfs.FDFS_PROTO_PKG_LEN_SIZE
+	}
+	return data
+}
+
+// storageStats 生成组内所有存储服务器的统计信息记录，version为上报的存储服务器版本
+func (g *group) storageStats(version fastdfs.Version) []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	freeMB := storageTotalMB - g.usedMB()
+	var resp []byte
+	for _, storage := range g.storages {
+		data := make([]byte, fastdfs.TRACKER_STORAGE_STAT_LEN)
+		data[0] = fastdfs.FDFS_STORAGE_STATUS_ACTIVE
+		offset := 1
+		putString(data[offset:offset+fastdfs.FDFS_STORAGE_ID_MAX_SIZE], storage.reportedID())
+		offset += fastdfs.FDFS_STORAGE_ID_MAX_SIZE
+		putString(data[offset:offset+fastdfs.IP_ADDRESS_SIZE], storage.ipAddr)
+		offset += fastdfs.IP_ADDRESS_SIZE + fastdfs.FDFS_DOMAIN_NAME_MAX_SIZE + fastdfs.FDFS_STORAGE_ID_MAX_SIZE
+		putString(data[offset:offset+fastdfs.FDFS_VERSION_SIZE], version.String())
+		offset += fastdfs.FDFS_VERSION_SIZE
+
+		// join_time, up_time, total_mb, free_mb, upload_priority, store_path_count,
+		// subdir_count_per_path, current_write_path, storage_port, storage_http_port，其后的统计计数器和if_trunk_server保持为0
+		fields := []int64{0, 0, storageTotalMB, freeMB, 0, 1, 256, 0, int64(g.port), 0}
+		for _, field := range fields {
+			binary.BigEndian.PutUint64(data[offset:offset+fastdfs.FDFS_PROTO_PKG_LEN_SIZE], uint64(field))
+			offset += fastdfs.FDFS_PROTO_PKG_LEN_SIZE
+		}
+		resp = append(resp, data...)
+	}
+	return resp
+}
+
+// usedMB 获取组内文件占用的容量，调用方需持有锁
+func (g *group) usedMB() int64 {
+	var used int64
+	for _, f := range g.files {
+		used += int64(len(f.data))
+	}
+	return used / (1024 * 1024)
+}
@@ -0,0 +1,101 @@
+package fdfstest
+
+import (
+	"errors"
+	"testing"
+
+	"fastdfs-migration-system/internal/fastdfs"
+)
+
+func TestCluster_RegenerateAppenderFileName(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	client := newTestClient(t, cluster)
+
+	appenderID, err := client.UploadAppenderFile("group1", "app.log", []byte("appended"))
+	if err != nil {
+		t.Fatalf("Unexpected upload appender error: %v", err)
+	}
+	if err := client.SetMetadata(appenderID, map[string]string{"kind": "log"}, fastdfs.MetadataOverwrite); err != nil {
+		t.Fatalf("Unexpected set metadata error: %v", err)
+	}
+
+	fileID, err := client.RegenerateAppenderFileName(appenderID)
+	if err != nil {
+		t.Fatalf("Unexpected regenerate error: %v", err)
+	}
+	if fileID == appenderID || fastdfs.IsAppenderFile(fileID) {
+		t.Errorf("Expected a normal file ID, got %s", fileID)
+	}
+	if data, _ := cluster.File(fileID); string(data) != "appended" {
+		t.Errorf("Unexpected content after regenerate: %q", data)
+	}
+	if metadata := cluster.Metadata(fileID); metadata["kind"] != "log" {
+		t.Errorf("Expected metadata to follow the file, got %v", metadata)
+	}
+	if _, exists := cluster.File(appenderID); exists {
+		t.Error("Expected appender file name to be gone")
+	}
+}
+
+func TestCluster_Version5(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+	cluster.SetVersion("5.0.7")
+	client := newTestClient(t, cluster)
+
+	appenderID, err := client.UploadAppenderFile("group1", "app.log", []byte("appended"))
+	if err != nil {
+		t.Fatalf("Unexpected upload appender error: %v", err)
+	}
+
+	// 未指定方言时请求发到存储服务器，由5.x存储服务器拒绝
+	if _, err := client.RegenerateAppenderFileName(appenderID); !errors.Is(err, fastdfs.ErrInvalidArgument) {
+		t.Errorf("Expected 5.x storage to reject the command, got %v", err)
+	}
+
+	// 指定方言后在客户端拒绝
+	dialect, _ := fastdfs.DialectForVersion("5.0.7")
+	client.SetDialect(dialect)
+	if _, err := client.RegenerateAppenderFileName(appenderID); !errors.Is(err, fastdfs.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+
+	groups, err := client.GetTopology()
+	if err != nil {
+		t.Fatalf("Unexpected topology error: %v", err)
+	}
+	if version := groups[0].Storages[0].Version; version != "5.07" {
+		t.Errorf("Expected storage to report 5.07, got %s", version)
+	}
+	if model := cluster.Model("source"); model.Version != "5.0.7" {
+		t.Errorf("Expected model version 5.0.7, got %s", model.Version)
+	}
+}
+
+func TestClusterManager_VerifiesVersion(t *testing.T) {
+	cluster := NewCluster()
+	defer cluster.Close()
+
+	manager := fastdfs.NewClusterManager()
+	defer manager.Close()
+
+	if err := manager.AddCluster(cluster.Model("matching")); err != nil {
+		t.Fatalf("Unexpected error for matching version: %v", err)
+	}
+
+	mismatched := cluster.Model("mismatched")
+	mismatched.Version = "5.0.7"
+	if err := manager.AddCluster(mismatched); !errors.Is(err, fastdfs.ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	if err := fastdfs.TestConnection(mismatched); !errors.Is(err, fastdfs.ErrVersionMismatch) {
+		t.Errorf("Expected TestConnection to report ErrVersionMismatch, got %v", err)
+	}
+
+	unsupported := cluster.Model("unsupported")
+	unsupported.Version = "4.0.8"
+	if err := manager.AddCluster(unsupported); !errors.Is(err, fastdfs.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
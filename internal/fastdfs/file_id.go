@@ -0,0 +1,420 @@
+package fastdfs
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// fdfsBase64 FastDFS文件名使用的base64编码，字符集与URL安全编码一致且不带填充
+var fdfsBase64 = base64.RawURLEncoding
+
+// FileIDInfo 从文件ID中解码出的信息
+// 文件名格式: M<store_path_index>/<subdir1>/<subdir2>/<base64字段>[trunk信息]<随机数字>[slave前缀].<扩展名>
+// 随机数字由存储服务器补在扩展名前，长度为6减去扩展名长度，没有扩展名时为7；slave文件沿用master文件的随机数字
+type FileIDInfo struct {
+	GroupName       string // 组名
+	FileName        string // 组内文件名
+	StorePathIndex  int    // 存储路径索引，如M00对应0
+	SubDir1         int    // 一级子目录
+	SubDir2         int    // 二级子目录
+	SourceIPAddr    string // 上传时的源存储服务器IP，文件名记录storage ID时为空
+	SourceStorageID string // 上传时的源存储服务器ID，仅在storage ID模式（id_type_in_filename = id）下有效
+	CreateTime      int64  // 创建时间戳
+	FileSize        int64  // 文件大小，仅在HasEmbeddedSize返回true时有效
+	CRC32           uint32 // CRC32校验值，仅在HasEmbeddedSize返回true时有效
+	ExtName         string // 扩展名
+	Padding         string // 扩展名前的随机数字，slave文件为其master文件名中的随机数字
+	SlavePrefix     string // slave文件前缀，非slave文件为空
+	IsAppender      bool   // 是否为appender文件
+	IsTrunk         bool   // 是否存储在trunk文件中
+	IsSlave         bool   // 是否为slave文件
+}
+
+// DecodeFileID 解码文件ID中嵌入的源存储服务器、创建时间、文件大小和CRC32等字段
+func DecodeFileID(fileID string) (*FileIDInfo, error) {
+	groupName, fileName, err := parseFileID(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fileName) < FDFS_LOGIC_FILE_PATH_LEN+FDFS_FILENAME_BASE64_LENGTH {
+		return nil, fmt.Errorf("file name too short: %s", fileName)
+	}
+
+	if fileName[0] != 'M' || fileName[3] != '/' || fileName[6] != '/' || fileName[9] != '/' {
+		return nil, fmt.Errorf("invalid file path: %s", fileName)
+	}
+
+	info := &FileIDInfo{
+		GroupName: groupName,
+		FileName:  fileName,
+	}
+
+	if info.StorePathIndex, err = parseHexField(fileName[1:3]); err != nil {
+		return nil, fmt.Errorf("invalid store path index: %s", fileName)
+	}
+	if info.SubDir1, err = parseHexField(fileName[4:6]); err != nil {
+		return nil, fmt.Errorf("invalid sub directory: %s", fileName)
+	}
+	if info.SubDir2, err = parseHexField(fileName[7:9]); err != nil {
+		return nil, fmt.Errorf("invalid sub directory: %s", fileName)
+	}
+
+	fields, err := decodeFileNameFields(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 字段格式: source(4) + create_timestamp(4) + file_size(8) + crc32(4)，source为IP或storage ID
+	info.SourceIPAddr, info.SourceStorageID = decodeSource(fields[0:4])
+	info.CreateTime = int64(binary.BigEndian.Uint32(fields[4:8]))
+	rawSize := int64(binary.BigEndian.Uint64(fields[8:16]))
+	info.CRC32 = binary.BigEndian.Uint32(fields[16:20])
+
+	info.IsAppender = rawSize&FDFS_APPENDER_FILE_SIZE != 0
+	info.IsTrunk = rawSize&FDFS_TRUNK_FILE_MARK_SIZE != 0
+
+	// 普通文件的高32位是随机数，trunk文件的高位是trunk标记，真实大小都在低32位
+	info.FileSize = rawSize
+	if rawSize < 0 || info.IsTrunk {
+		info.FileSize = rawSize & 0xFFFFFFFF
+	}
+
+	if dot := strings.LastIndexByte(fileName, '.'); dot >= FDFS_LOGIC_FILE_PATH_LEN+FDFS_FILENAME_BASE64_LENGTH {
+		info.ExtName = fileName[dot+1:]
+	}
+	stem := info.stem()
+	if len(stem) < info.baseLen() {
+		return nil, fmt.Errorf("invalid file name length: %s", fileName)
+	}
+
+	// 随机数字之后还有内容时为slave文件。slave的扩展名可以与master不同，
+	// 此时无法确定master随机数字的长度，SlavePrefix只是推测，已知master文件时应使用SlavePrefixOf
+	rest := stem[info.baseLen():]
+	info.Padding = rest
+	if rest != "" && (len(rest) != paddingLen(info.ExtName) || !isDigits(rest)) {
+		n := slavePaddingLen(rest, info.ExtName)
+		info.IsSlave = true
+		info.Padding = rest[:n]
+		info.SlavePrefix = rest[n:]
+	}
+
+	return info, nil
+}
+
+// EncodeFileName 按字段生成组内文件名，是DecodeFileID的逆过程
+// 设置了SourceStorageID时按storage ID模式编码源存储服务器，否则编码SourceIPAddr；
+// Padding为空时按扩展名长度补0，存储服务器生成的是随机数字；不支持trunk文件
+func (i *FileIDInfo) EncodeFileName() (string, error) {
+	if i.IsTrunk {
+		return "", fmt.Errorf("encoding trunk file names is not supported")
+	}
+
+	padding := i.Padding
+	if padding == "" {
+		padding = strings.Repeat("0", paddingLen(i.ExtName))
+	}
+	if !isDigits(padding) || len(padding) > FDFS_FILE_EXT_NAME_MAX_LEN+1 {
+		return "", fmt.Errorf("invalid file name padding: %q", padding)
+	}
+
+	source, err := encodeSource(i.SourceIPAddr, i.SourceStorageID)
+	if err != nil {
+		return "", err
+	}
+
+	size := i.FileSize
+	if i.IsAppender {
+		size |= FDFS_APPENDER_FILE_SIZE
+	}
+
+	fields := make([]byte, 20)
+	copy(fields[0:4], source)
+	binary.BigEndian.PutUint32(fields[4:8], uint32(i.CreateTime))
+	binary.BigEndian.PutUint64(fields[8:16], uint64(size))
+	binary.BigEndian.PutUint32(fields[16:20], i.CRC32)
+
+	fileName := fmt.Sprintf("M%02X/%02X/%02X/%s%s%s", i.StorePathIndex, i.SubDir1, i.SubDir2, fdfsBase64.EncodeToString(fields), padding, i.SlavePrefix)
+	if i.ExtName != "" {
+		fileName += "." + i.ExtName
+	}
+	return fileName, nil
+}
+
+// FindSourceStorage 在组内存储服务器中查找文件的源存储服务器，找不到时返回nil
+// 文件名记录storage ID时按ID匹配，否则按IP匹配
+func (i *FileIDInfo) FindSourceStorage(storages []*StorageInfo) *StorageInfo {
+	for _, storage := range storages {
+		if i.SourceStorageID != "" && storage.ID == i.SourceStorageID {
+			return storage
+		}
+		if i.SourceStorageID == "" && storage.IPAddr == i.SourceIPAddr {
+			return storage
+		}
+	}
+	return nil
+}
+
+// Source 获取源存储服务器的标识，storage ID模式下为ID，否则为IP
+func (i *FileIDInfo) Source() string {
+	if i.SourceStorageID != "" {
+		return i.SourceStorageID
+	}
+	return i.SourceIPAddr
+}
+
+// HasEmbeddedSize 文件名中的大小和CRC32是否为文件本身的真实值
+// appender文件的内容会变化，slave文件名中的字段属于其master文件，这两种情况都需要向存储服务器查询
+func (i *FileIDInfo) HasEmbeddedSize() bool {
+	return !i.IsAppender && !i.IsSlave
+}
+
+// MasterKey 返回master文件的键（组名加不含扩展名的master文件名），master和其slave文件的键相同
+func (i *FileIDInfo) MasterKey() string {
+	stem := i.stem()
+	return i.GroupName + "/" + stem[:len(stem)-len(i.SlavePrefix)]
+}
+
+// masterKeyCandidates 返回slave文件所有可能的master键，第一个为MasterKey
+// master随机数字的长度为0到7，对应slave文件名中base64字段之后的0到7个数字
+func (i *FileIDInfo) masterKeyCandidates() []string {
+	stem := i.stem()
+	rest := stem[i.baseLen():]
+
+	keys := []string{i.MasterKey()}
+	for n := 0; n < len(rest) && n <= FDFS_FILE_EXT_NAME_MAX_LEN+1; n++ {
+		if n > 0 && !isDigits(rest[n-1:n]) {
+			break
+		}
+		if key := i.GroupName + "/" + stem[:i.baseLen()+n]; key != keys[0] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// stem 返回不含扩展名的文件名
+func (i *FileIDInfo) stem() string {
+	if i.ExtName == "" {
+		return i.FileName
+	}
+	return i.FileName[:len(i.FileName)-len(i.ExtName)-1]
+}
+
+// baseLen 返回文件名中随机数字之前部分的长度，trunk文件在base64字段后还有trunk信息
+func (i *FileIDInfo) baseLen() int {
+	if i.IsTrunk {
+		return FDFS_LOGIC_FILE_PATH_LEN + FDFS_FILENAME_BASE64_LENGTH + FDFS_TRUNK_FILE_INFO_LEN
+	}
+	return FDFS_LOGIC_FILE_PATH_LEN + FDFS_FILENAME_BASE64_LENGTH
+}
+
+// GetFileID 获取文件ID
+func (i *FileIDInfo) GetFileID() string {
+	return fmt.Sprintf("%s/%s", i.GroupName, i.FileName)
+}
+
+// ToFileInfo 转换为FileInfo，文件名中没有可靠大小时FileSize和CRC32为0
+func (i *FileIDInfo) ToFileInfo() *FileInfo {
+	fileInfo := &FileInfo{
+		GroupName:    i.GroupName,
+		FileName:     i.FileName,
+		CreateTime:   i.CreateTime,
+		SourceIPAddr: i.SourceIPAddr,
+	}
+	if i.HasEmbeddedSize() {
+		fileInfo.FileSize = i.FileSize
+		fileInfo.CRC32 = i.CRC32
+	}
+	return fileInfo
+}
+
+// decodeFileNameFields 解码文件名中base64编码的20字节字段
+func decodeFileNameFields(fileName string) ([]byte, error) {
+	if len(fileName) < FDFS_LOGIC_FILE_PATH_LEN+FDFS_FILENAME_BASE64_LENGTH {
+		return nil, fmt.Errorf("file name too short: %s", fileName)
+	}
+
+	encoded := fileName[FDFS_LOGIC_FILE_PATH_LEN : FDFS_LOGIC_FILE_PATH_LEN+FDFS_FILENAME_BASE64_LENGTH]
+	fields, err := fdfsBase64.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file name encoding: %w", err)
+	}
+	return fields, nil
+}
+
+// decodeSource 解码文件名中的源存储服务器字段
+// FastDFS写入storage ID时先htonl再按大端序写入，在小端机器上字节序与IP相反，
+// 因此按小端序读出的值不超过FDFS_MAX_SERVER_ID时为storage ID，否则按网络字节序解释为IP
+func decodeSource(field []byte) (ipAddr string, storageID string) {
+	if id := binary.LittleEndian.Uint32(field); id > 0 && id <= FDFS_MAX_SERVER_ID {
+		return "", strconv.FormatUint(uint64(id), 10)
+	}
+	return net.IP(field).String(), ""
+}
+
+// encodeSource 编码文件名中的源存储服务器字段，storageID不为空时优先使用
+func encodeSource(ipAddr string, storageID string) ([]byte, error) {
+	field := make([]byte, 4)
+	if storageID != "" {
+		id, err := strconv.ParseUint(storageID, 10, 32)
+		if err != nil || id == 0 || id > FDFS_MAX_SERVER_ID {
+			return nil, fmt.Errorf("storage ID %q cannot be stored in a file name, expected 1 to %d", storageID, FDFS_MAX_SERVER_ID)
+		}
+		binary.LittleEndian.PutUint32(field, uint32(id))
+		return field, nil
+	}
+
+	ip := net.ParseIP(ipAddr).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid source IP address: %q", ipAddr)
+	}
+	copy(field, ip)
+	return field, nil
+}
+
+// paddingLen 返回存储服务器在扩展名前补充的随机数字个数，与storage_format_ext_name一致
+func paddingLen(extName string) int {
+	if extName == "" {
+		return FDFS_FILE_EXT_NAME_MAX_LEN + 1
+	}
+	if len(extName) > FDFS_FILE_EXT_NAME_MAX_LEN {
+		return 0
+	}
+	return FDFS_FILE_EXT_NAME_MAX_LEN - len(extName)
+}
+
+// slavePaddingLen 推测slave文件名rest（base64字段之后、扩展名之前的部分）中master随机数字的长度
+// slave与master扩展名相同时长度由扩展名决定；否则取开头连续的数字，前缀以数字开头时可能多取
+func slavePaddingLen(rest string, extName string) int {
+	if n := paddingLen(extName); n < len(rest) && isDigits(rest[:n]) {
+		return n
+	}
+
+	n := 0
+	for n < len(rest)-1 && n <= FDFS_FILE_EXT_NAME_MAX_LEN && isDigits(rest[n:n+1]) {
+		n++
+	}
+	return n
+}
+
+// isDigits 检查s是否只包含十进制数字
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHexField 解析文件路径中两位十六进制的目录字段
+func parseHexField(field string) (int, error) {
+	value, err := strconv.ParseUint(field, 16, 8)
+	if err != nil {
+		return 0, err
+	}
+	return int(value), nil
+}
+
+// IsAppenderFile 根据文件ID判断是否为appender文件
+func IsAppenderFile(fileID string) bool {
+	info, err := DecodeFileID(fileID)
+	return err == nil && info.IsAppender
+}
+
+// MasterSlaveGroup 一个master文件及其所有slave文件
+type MasterSlaveGroup struct {
+	MasterFileID string   // master文件ID，找不到master时为空
+	SlaveFileIDs []string // slave文件ID列表
+}
+
+// SplitSlaveFileID 拆分文件ID，返回其master键（组名加不含扩展名的master文件名）和slave前缀
+// 对master文件或普通文件，返回的prefix为空；slave与master扩展名不同时结果是推测的，见DecodeFileID
+func SplitSlaveFileID(fileID string) (masterKey string, prefix string, err error) {
+	info, err := DecodeFileID(fileID)
+	if err != nil {
+		return "", "", err
+	}
+	return info.MasterKey(), info.SlavePrefix, nil
+}
+
+// SlavePrefixOf 获取slave文件相对于其master文件的前缀
+// 与fdfs_gen_slave_filename一致，slave文件名是master文件名去掉扩展名后加上前缀和slave自己的扩展名
+func SlavePrefixOf(masterFileID string, slaveFileID string) (string, error) {
+	master, err := DecodeFileID(masterFileID)
+	if err != nil {
+		return "", err
+	}
+	slave, err := DecodeFileID(slaveFileID)
+	if err != nil {
+		return "", err
+	}
+
+	prefix, ok := strings.CutPrefix(slave.GroupName+"/"+slave.stem(), master.GroupName+"/"+master.stem())
+	if !ok || prefix == "" {
+		return "", fmt.Errorf("%s is not a slave file of %s", slaveFileID, masterFileID)
+	}
+	return prefix, nil
+}
+
+// IsSlaveFile 根据文件ID判断是否为slave文件
+func IsSlaveFile(fileID string) bool {
+	info, err := DecodeFileID(fileID)
+	return err == nil && info.IsSlave
+}
+
+// GroupMasterSlaveFiles 按master/slave关系对文件ID分组，分组顺序与master首次出现的顺序一致
+// slave文件优先归入fileIDs中文件名与其匹配的master文件；无法解析的文件ID作为没有slave的master文件单独成组
+func GroupMasterSlaveFiles(fileIDs []string) []*MasterSlaveGroup {
+	infos := make([]*FileIDInfo, len(fileIDs))
+	masters := make(map[string]bool)
+	for i, fileID := range fileIDs {
+		if info, err := DecodeFileID(fileID); err == nil {
+			infos[i] = info
+			if !info.IsSlave {
+				masters[info.MasterKey()] = true
+			}
+		}
+	}
+
+	var groups []*MasterSlaveGroup
+	byMaster := make(map[string]*MasterSlaveGroup)
+
+	for i, fileID := range fileIDs {
+		info := infos[i]
+		if info == nil {
+			groups = append(groups, &MasterSlaveGroup{MasterFileID: fileID})
+			continue
+		}
+
+		masterKey := info.MasterKey()
+		if info.IsSlave {
+			for _, key := range info.masterKeyCandidates() {
+				if masters[key] {
+					masterKey = key
+					break
+				}
+			}
+		}
+
+		group, exists := byMaster[masterKey]
+		if !exists {
+			group = &MasterSlaveGroup{}
+			byMaster[masterKey] = group
+			groups = append(groups, group)
+		}
+
+		if info.IsSlave {
+			group.SlaveFileIDs = append(group.SlaveFileIDs, fileID)
+		} else {
+			group.MasterFileID = fileID
+		}
+	}
+
+	return groups
+}
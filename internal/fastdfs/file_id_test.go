@@ -0,0 +1,335 @@
+package fastdfs
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// encodeTestFileName 按FastDFS规则生成测试用文件名，扩展名前补充存储服务器生成的随机数字
+func encodeTestFileName(ip [4]byte, timestamp uint32, fileSize int64, crc32 uint32, ext string) string {
+	buf := make([]byte, 20)
+	copy(buf[0:4], ip[:])
+	binary.BigEndian.PutUint32(buf[4:8], timestamp)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(fileSize))
+	binary.BigEndian.PutUint32(buf[16:20], crc32)
+	return "M00/00/00/" + fdfsBase64.EncodeToString(buf) + "8502417"[:paddingLen(ext)] + "." + ext
+}
+
+// withTrunkInfo 在文件名的base64字段后插入trunk信息
+func withTrunkInfo(fileName string) string {
+	baseLen := FDFS_LOGIC_FILE_PATH_LEN + FDFS_FILENAME_BASE64_LENGTH
+	return fileName[:baseLen] + "AAAAAAAAAAAAAAAA" + fileName[baseLen:]
+}
+
+func TestIsAppenderFile(t *testing.T) {
+	ip := [4]byte{192, 168, 1, 10}
+	normal := encodeTestFileName(ip, 1640995200, 1024, 0x12345678, "jpg")
+	appender := encodeTestFileName(ip, 1640995200, FDFS_APPENDER_FILE_SIZE, 0, "log")
+
+	if len(normal) != FDFS_LOGIC_FILE_PATH_LEN+FDFS_FILENAME_BASE64_LENGTH+FDFS_FILE_EXT_NAME_MAX_LEN+1 {
+		t.Fatalf("Unexpected encoded file name length: %s", normal)
+	}
+
+	if IsAppenderFile("group1/" + normal) {
+		t.Errorf("Normal file %s should not be an appender file", normal)
+	}
+
+	if !IsAppenderFile("group1/" + appender) {
+		t.Errorf("File %s should be an appender file", appender)
+	}
+
+	for _, fileID := range []string{"invalid", "group1/M00/00/00/short.jpg", "group1/M00/00/00/!!!!!!!!!!!!!!!!!!!!!!!!!!!.jpg"} {
+		if IsAppenderFile(fileID) {
+			t.Errorf("Invalid file ID %s should not be an appender file", fileID)
+		}
+	}
+}
+
+func TestSplitSlaveFileID(t *testing.T) {
+	ip := [4]byte{10, 0, 0, 1}
+	master := encodeTestFileName(ip, 1640995200, 2048, 0xCAFEBABE, "jpg")
+	base := master[:len(master)-len(".jpg")]
+	slave := base + "_150x150.png"
+
+	masterKey, prefix, err := SplitSlaveFileID("group1/" + master)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if masterKey != "group1/"+base || prefix != "" {
+		t.Errorf("Unexpected master split: %s, %q", masterKey, prefix)
+	}
+
+	slaveKey, prefix, err := SplitSlaveFileID("group1/" + slave)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if slaveKey != masterKey || prefix != "_150x150" {
+		t.Errorf("Unexpected slave split: %s, %q", slaveKey, prefix)
+	}
+
+	if IsSlaveFile("group1/" + master) {
+		t.Error("Master file should not be a slave file")
+	}
+	if !IsSlaveFile("group1/" + slave) {
+		t.Error("Expected slave file")
+	}
+
+	// trunk文件名更长，但不是slave文件
+	trunk := encodeTestFileName(ip, 1640995200, FDFS_TRUNK_FILE_MARK_SIZE|2048, 0, "jpg")
+	if IsSlaveFile("group1/" + withTrunkInfo(trunk)) {
+		t.Error("Trunk file should not be a slave file")
+	}
+}
+
+func TestGroupMasterSlaveFiles(t *testing.T) {
+	ip := [4]byte{10, 0, 0, 1}
+	master1 := "group1/" + encodeTestFileName(ip, 1, 100, 1, "jpg")
+	master2 := "group1/" + encodeTestFileName(ip, 2, 200, 2, "jpg")
+	slave1 := master1[:len(master1)-4] + "_small.jpg"
+	slave2 := master1[:len(master1)-4] + "_big.jpg"
+	orphan := master2[:len(master2)-4] + "_x.jpg"
+	orphan = "group2/" + orphan[len("group1/"):]
+
+	groups := GroupMasterSlaveFiles([]string{slave1, master1, master2, slave2, orphan, "invalid"})
+	if len(groups) != 4 {
+		t.Fatalf("Expected 4 groups, got %d", len(groups))
+	}
+
+	if groups[0].MasterFileID != master1 || len(groups[0].SlaveFileIDs) != 2 {
+		t.Errorf("Unexpected first group: %+v", groups[0])
+	}
+	if groups[1].MasterFileID != master2 || len(groups[1].SlaveFileIDs) != 0 {
+		t.Errorf("Unexpected second group: %+v", groups[1])
+	}
+	if groups[2].MasterFileID != "" || len(groups[2].SlaveFileIDs) != 1 {
+		t.Errorf("Expected orphan slave group, got %+v", groups[2])
+	}
+	if groups[3].MasterFileID != "invalid" {
+		t.Errorf("Expected invalid file ID in its own group, got %+v", groups[3])
+	}
+}
+
+// realMasterFileID 存储服务器生成的文件ID，扩展名前的850是补齐文件名长度的随机数字
+const realMasterFileID = "group1/M00/00/00/wKgBs1Zb8WyAe7M0AAAbfz4tOdo850.png"
+
+func TestGroupMasterSlaveFiles_PaddedNames(t *testing.T) {
+	stem := realMasterFileID[:len(realMasterFileID)-len(".png")]
+	sameExt := stem + "_150x150.png"
+	otherExt := stem + "_big.jpeg"
+	noExt := stem + "-m"
+	other := "group1/M00/00/01/wKgBs1Zb8WyAe7M0AAAbfz4tOdo31.jpeg"
+
+	if IsSlaveFile(realMasterFileID) || IsSlaveFile(other) {
+		t.Error("Files with padding digits should not be slave files")
+	}
+	for _, slave := range []string{sameExt, otherExt, noExt} {
+		if !IsSlaveFile(slave) {
+			t.Errorf("Expected %s to be a slave file", slave)
+		}
+	}
+
+	groups := GroupMasterSlaveFiles([]string{otherExt, sameExt, realMasterFileID, other, noExt})
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d: %+v", len(groups), groups)
+	}
+	if groups[0].MasterFileID != realMasterFileID || len(groups[0].SlaveFileIDs) != 3 {
+		t.Errorf("Expected all slaves to belong to the master, got %+v", groups[0])
+	}
+	if groups[1].MasterFileID != other || len(groups[1].SlaveFileIDs) != 0 {
+		t.Errorf("Unexpected second group: %+v", groups[1])
+	}
+
+	for slave, want := range map[string]string{sameExt: "_150x150", otherExt: "_big", noExt: "-m"} {
+		prefix, err := SlavePrefixOf(realMasterFileID, slave)
+		if err != nil || prefix != want {
+			t.Errorf("Expected prefix %q of %s, got %q (%v)", want, slave, prefix, err)
+		}
+	}
+	if _, err := SlavePrefixOf(other, sameExt); err == nil {
+		t.Error("Expected error for a slave of another master")
+	}
+	if _, err := SlavePrefixOf(realMasterFileID, realMasterFileID); err == nil {
+		t.Error("Expected error for the master itself")
+	}
+}
+
+func TestDecodeFileID(t *testing.T) {
+	// 普通文件的文件大小字段高32位为随机数
+	high := uint64(0x80001234)
+	rawSize := int64(high<<32 | 2048)
+	fileName := encodeTestFileName([4]byte{192, 168, 1, 10}, 1640995200, rawSize, 0xCAFEBABE, "jpg")
+	fileName = "M01/3A/FF/" + fileName[FDFS_LOGIC_FILE_PATH_LEN:]
+
+	info, err := DecodeFileID("group2/" + fileName)
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+
+	if info.GroupName != "group2" || info.FileName != fileName {
+		t.Errorf("Unexpected group/file: %s/%s", info.GroupName, info.FileName)
+	}
+	if info.StorePathIndex != 1 || info.SubDir1 != 0x3A || info.SubDir2 != 0xFF {
+		t.Errorf("Unexpected path fields: %d/%d/%d", info.StorePathIndex, info.SubDir1, info.SubDir2)
+	}
+	if info.SourceIPAddr != "192.168.1.10" {
+		t.Errorf("Expected source IP 192.168.1.10, got %s", info.SourceIPAddr)
+	}
+	if info.CreateTime != 1640995200 {
+		t.Errorf("Expected create time 1640995200, got %d", info.CreateTime)
+	}
+	if info.FileSize != 2048 || info.CRC32 != 0xCAFEBABE {
+		t.Errorf("Unexpected size/crc: %d/%#x", info.FileSize, info.CRC32)
+	}
+	if info.ExtName != "jpg" || info.IsAppender || info.IsTrunk || info.IsSlave {
+		t.Errorf("Unexpected flags: %+v", info)
+	}
+	if !info.HasEmbeddedSize() {
+		t.Error("Normal file should have embedded size")
+	}
+
+	fileInfo := info.ToFileInfo()
+	if fileInfo.GetFileID() != "group2/"+fileName || fileInfo.FileSize != 2048 || fileInfo.CRC32 != 0xCAFEBABE {
+		t.Errorf("Unexpected file info: %+v", fileInfo)
+	}
+}
+
+func TestDecodeFileID_RealName(t *testing.T) {
+	info, err := DecodeFileID(realMasterFileID)
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+
+	if info.IsSlave || info.SlavePrefix != "" || info.Padding != "850" || info.ExtName != "png" {
+		t.Errorf("Expected a master file with padding 850, got %+v", info)
+	}
+	if info.SourceIPAddr != "192.168.1.179" || info.CreateTime != 1448866156 {
+		t.Errorf("Unexpected source/create time: %s/%d", info.SourceIPAddr, info.CreateTime)
+	}
+	if !info.HasEmbeddedSize() || info.FileSize != 7039 || info.CRC32 != 0x3E2D39DA {
+		t.Errorf("Expected embedded size 7039 and CRC32 3e2d39da, got %d/%#x", info.FileSize, info.CRC32)
+	}
+
+	fileInfo := info.ToFileInfo()
+	if fileInfo.GetFileID() != realMasterFileID || fileInfo.FileSize != 7039 || fileInfo.CRC32 != 0x3E2D39DA {
+		t.Errorf("Unexpected file info: %+v", fileInfo)
+	}
+
+	// 随机数字和高32位的随机数不影响重新编码
+	fileName, err := info.EncodeFileName()
+	if err != nil {
+		t.Fatalf("Unexpected encode error: %v", err)
+	}
+	if decoded, err := DecodeFileID("group1/" + fileName); err != nil || decoded.FileSize != 7039 || decoded.Padding != "850" || decoded.IsSlave {
+		t.Errorf("Unexpected re-encoded file name %s: %+v (%v)", fileName, decoded, err)
+	}
+}
+
+func TestDecodeFileID_Flags(t *testing.T) {
+	ip := [4]byte{10, 0, 0, 1}
+
+	appender, err := DecodeFileID("group1/" + encodeTestFileName(ip, 1, FDFS_APPENDER_FILE_SIZE, 0, "log"))
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+	if !appender.IsAppender || appender.HasEmbeddedSize() || appender.ToFileInfo().FileSize != 0 {
+		t.Errorf("Unexpected appender info: %+v", appender)
+	}
+
+	trunkName := withTrunkInfo(encodeTestFileName(ip, 1, FDFS_TRUNK_FILE_MARK_SIZE|4096, 0, "jpg"))
+	trunk, err := DecodeFileID("group1/" + trunkName)
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+	if !trunk.IsTrunk || trunk.IsSlave || trunk.FileSize != 4096 {
+		t.Errorf("Unexpected trunk info: %+v", trunk)
+	}
+
+	slaveName := encodeTestFileName(ip, 1, 100, 0, "jpg")
+	slaveName = slaveName[:len(slaveName)-4] + "_150x150.png"
+	slave, err := DecodeFileID("group1/" + slaveName)
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+	if !slave.IsSlave || slave.SlavePrefix != "_150x150" || slave.ExtName != "png" || slave.HasEmbeddedSize() {
+		t.Errorf("Unexpected slave info: %+v", slave)
+	}
+
+	for _, fileID := range []string{"group1", "group1/M00/00/00/short.jpg", "group1/X00/00/00/" + slaveName[FDFS_LOGIC_FILE_PATH_LEN:], "group1/MZZ/00/00/" + slaveName[FDFS_LOGIC_FILE_PATH_LEN:]} {
+		if _, err := DecodeFileID(fileID); err == nil {
+			t.Errorf("Expected decode error for %s", fileID)
+		}
+	}
+}
+
+func TestDecodeFileID_StorageID(t *testing.T) {
+	// storage ID 100001 (0x000186A1) 在文件名中按小端序保存
+	fileName := encodeTestFileName([4]byte{0xA1, 0x86, 0x01, 0x00}, 1640995200, 1024, 0x12345678, "jpg")
+
+	info, err := DecodeFileID("group1/" + fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.SourceStorageID != "100001" || info.SourceIPAddr != "" || info.Source() != "100001" {
+		t.Errorf("Expected storage ID 100001, got %+v", info)
+	}
+
+	// 末段为0以外的IP不会被误认为storage ID
+	info, err = DecodeFileID("group1/" + encodeTestFileName([4]byte{10, 0, 0, 1}, 1640995200, 1024, 0, "jpg"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.SourceIPAddr != "10.0.0.1" || info.SourceStorageID != "" {
+		t.Errorf("Expected source IP 10.0.0.1, got %+v", info)
+	}
+
+	storages := []*StorageInfo{
+		{ID: "100001", IPAddr: "10.0.0.2"},
+		{ID: "100002", IPAddr: "10.0.0.1"},
+	}
+	if storage := info.FindSourceStorage(storages); storage == nil || storage.ID != "100002" {
+		t.Errorf("Expected to find storage by IP, got %+v", storage)
+	}
+}
+
+func TestEncodeFileName(t *testing.T) {
+	tests := []*FileIDInfo{
+		{GroupName: "group1", SourceIPAddr: "192.168.1.10", CreateTime: 1640995200, FileSize: 1024, CRC32: 0xCAFEBABE, ExtName: "jpg", Padding: "850"},
+		{GroupName: "group1", SourceStorageID: "100003", CreateTime: 1640995200, FileSize: 2048, CRC32: 1, ExtName: "png", SubDir1: 0x0A, SubDir2: 0xFF},
+		{GroupName: "group2", StorePathIndex: 1, SourceStorageID: "16777215", CreateTime: 1640995200, FileSize: FDFS_APPENDER_FILE_SIZE, IsAppender: true},
+		{GroupName: "group1", SourceIPAddr: "10.0.0.1", CreateTime: 1640995200, FileSize: 10, ExtName: "jpeg", Padding: "31", SlavePrefix: "_small", IsSlave: true},
+	}
+
+	for _, want := range tests {
+		fileName, err := want.EncodeFileName()
+		if err != nil {
+			t.Errorf("Unexpected encode error for %+v: %v", want, err)
+			continue
+		}
+
+		got, err := DecodeFileID(want.GroupName + "/" + fileName)
+		if err != nil {
+			t.Errorf("Unexpected decode error for %s: %v", fileName, err)
+			continue
+		}
+		want.FileName = fileName
+		if want.Padding == "" {
+			want.Padding = strings.Repeat("0", paddingLen(want.ExtName))
+		}
+		if *got != *want {
+			t.Errorf("Round trip mismatch:\n got  %+v\n want %+v", got, want)
+		}
+	}
+
+	for _, invalid := range []*FileIDInfo{
+		{SourceStorageID: "16777216"},
+		{SourceStorageID: "storage-1"},
+		{SourceIPAddr: "::1"},
+		{SourceIPAddr: "10.0.0.1", IsTrunk: true},
+		{SourceIPAddr: "10.0.0.1", Padding: "12a"},
+	} {
+		if _, err := invalid.EncodeFileName(); err == nil {
+			t.Errorf("Expected encode error for %+v", invalid)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package fastdfs
 
+import "context"
+
 // FastDFS协议常量
 const (
 	// 协议头长度
@@ -17,8 +19,55 @@ const (
 	// 文件扩展名最大长度
 	FDFS_FILE_EXT_NAME_MAX_LEN = 6
 	
+	// slave文件前缀最大长度
+	FDFS_FILE_PREFIX_MAX_LEN = 16
+	
 	// Tracker查询存储服务器响应体长度
 	TRACKER_QUERY_STORAGE_STORE_BODY_LEN = FDFS_GROUP_NAME_MAX_LEN + IP_ADDRESS_SIZE + FDFS_PROTO_PKG_LEN_SIZE
+	
+	// 存储服务器ID最大长度
+	FDFS_STORAGE_ID_MAX_SIZE = 16
+	
+	// 文件名中可以记录的最大storage ID，源存储服务器字段不超过此值时为storage ID，否则为IP
+	FDFS_MAX_SERVER_ID = (1 << 24) - 1
+	
+	// 域名最大长度
+	FDFS_DOMAIN_NAME_MAX_SIZE = 128
+	
+	// 版本号长度
+	FDFS_VERSION_SIZE = 6
+	
+	// 组统计信息长度: group_name(17) + 11个8字节字段
+	TRACKER_GROUP_STAT_LEN = FDFS_GROUP_NAME_MAX_LEN + 1 + 11*FDFS_PROTO_PKG_LEN_SIZE
+	
+	// 存储服务器统计计数器长度: 3个4字节的连接数 + 42个8字节的计数器和时间戳
+	FDFS_STORAGE_STAT_BUFF_LEN = 3*4 + 42*FDFS_PROTO_PKG_LEN_SIZE
+	
+	// 存储服务器统计信息长度: 固定字段 + 统计计数器 + if_trunk_server(1)
+	TRACKER_STORAGE_STAT_LEN = 1 + FDFS_STORAGE_ID_MAX_SIZE + IP_ADDRESS_SIZE + FDFS_DOMAIN_NAME_MAX_SIZE +
+		FDFS_STORAGE_ID_MAX_SIZE + FDFS_VERSION_SIZE + 10*FDFS_PROTO_PKG_LEN_SIZE + FDFS_STORAGE_STAT_BUFF_LEN + 1
+	
+	// Tracker查询可读/可更新存储服务器响应体最小长度
+	TRACKER_QUERY_STORAGE_FETCH_BODY_LEN = FDFS_GROUP_NAME_MAX_LEN + IP_ADDRESS_SIZE - 1 + FDFS_PROTO_PKG_LEN_SIZE
+)
+
+// 文件名编码相关常量
+const (
+	// 逻辑路径前缀长度，如 M00/00/00/
+	FDFS_LOGIC_FILE_PATH_LEN = 10
+	
+	// 文件名中base64编码部分的长度
+	FDFS_FILENAME_BASE64_LENGTH = 27
+	
+	// trunk文件名中附加的trunk信息长度
+	FDFS_TRUNK_FILE_INFO_LEN = 16
+	
+	// 存储服务器保存元数据的文件后缀，元数据文件与数据文件位于同一目录
+	FDFS_STORAGE_META_FILE_EXT = "-m"
+	
+	// 文件名中文件大小字段的标记位
+	FDFS_APPENDER_FILE_SIZE   int64 = 1 << 58
+	FDFS_TRUNK_FILE_MARK_SIZE int64 = 1 << 59
 )
 
 // 协议命令
@@ -31,21 +80,25 @@ const (
 	TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL               = 105
 	TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITHOUT_GROUP_ALL = 106
 	TRACKER_PROTO_CMD_SERVICE_QUERY_STORE_WITH_GROUP_ALL    = 107
+	TRACKER_PROTO_CMD_SERVER_LIST_ONE_GROUP                 = 90
+	TRACKER_PROTO_CMD_SERVER_LIST_ALL_GROUPS                = 91
+	TRACKER_PROTO_CMD_SERVER_LIST_STORAGE                   = 92
 	
 	// Storage协议命令
-	STORAGE_PROTO_CMD_UPLOAD_FILE                = 11
-	STORAGE_PROTO_CMD_DELETE_FILE                = 12
-	STORAGE_PROTO_CMD_SET_METADATA               = 13
-	STORAGE_PROTO_CMD_DOWNLOAD_FILE              = 14
-	STORAGE_PROTO_CMD_GET_METADATA               = 15
-	STORAGE_PROTO_CMD_UPLOAD_SLAVE_FILE          = 21
-	STORAGE_PROTO_CMD_QUERY_FILE_INFO            = 22
-	STORAGE_PROTO_CMD_UPLOAD_APPENDER_FILE       = 23
-	STORAGE_PROTO_CMD_APPEND_FILE                = 24
-	STORAGE_PROTO_CMD_MODIFY_FILE                = 34
-	STORAGE_PROTO_CMD_TRUNCATE_FILE              = 36
-	STORAGE_PROTO_CMD_LIST_ONE_GROUP             = 40
-	STORAGE_PROTO_CMD_LIST_ALL_GROUPS            = 41
+	STORAGE_PROTO_CMD_UPLOAD_FILE                  = 11
+	STORAGE_PROTO_CMD_DELETE_FILE                  = 12
+	STORAGE_PROTO_CMD_SET_METADATA                 = 13
+	STORAGE_PROTO_CMD_DOWNLOAD_FILE                = 14
+	STORAGE_PROTO_CMD_GET_METADATA                 = 15
+	STORAGE_PROTO_CMD_UPLOAD_SLAVE_FILE            = 21
+	STORAGE_PROTO_CMD_QUERY_FILE_INFO              = 22
+	STORAGE_PROTO_CMD_UPLOAD_APPENDER_FILE         = 23
+	STORAGE_PROTO_CMD_APPEND_FILE                  = 24
+	STORAGE_PROTO_CMD_MODIFY_FILE                  = 34
+	STORAGE_PROTO_CMD_TRUNCATE_FILE                = 36
+	STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME = 38 // V6.02起支持
+	STORAGE_PROTO_CMD_LIST_ONE_GROUP               = 40
+	STORAGE_PROTO_CMD_LIST_ALL_GROUPS              = 41
 	
 	// 通用协议命令
 	FDFS_PROTO_CMD_QUIT        = 82
@@ -53,6 +106,35 @@ const (
 	FDFS_PROTO_CMD_RESP        = 100
 )
 
+// 元数据相关常量
+const (
+	// 元数据记录分隔符和字段分隔符
+	FDFS_RECORD_SEPERATOR = '\x01'
+	FDFS_FIELD_SEPERATOR  = '\x02'
+	
+	// 元数据名称和值的最大长度
+	FDFS_MAX_META_NAME_LEN  = 64
+	FDFS_MAX_META_VALUE_LEN = 256
+	
+	// 设置元数据的操作方式
+	STORAGE_SET_METADATA_FLAG_OVERWRITE = 'O'
+	STORAGE_SET_METADATA_FLAG_MERGE     = 'M'
+)
+
+// 存储服务器状态
+const (
+	FDFS_STORAGE_STATUS_INIT       = 0
+	FDFS_STORAGE_STATUS_WAIT_SYNC  = 1
+	FDFS_STORAGE_STATUS_SYNCING    = 2
+	FDFS_STORAGE_STATUS_IP_CHANGED = 3
+	FDFS_STORAGE_STATUS_DELETED    = 4
+	FDFS_STORAGE_STATUS_OFFLINE    = 5
+	FDFS_STORAGE_STATUS_ONLINE     = 6
+	FDFS_STORAGE_STATUS_ACTIVE     = 7
+	FDFS_STORAGE_STATUS_RECOVERY   = 9
+	FDFS_STORAGE_STATUS_NONE       = 99
+)
+
 // 协议状态码
 const (
 	FDFS_PROTO_STATUS_SUCCESS = 0
@@ -84,6 +166,16 @@ type FileInfo struct {
 	SourceIPAddr string    // 源IP地址
 }
 
+// MetadataFlag 设置元数据的方式
+type MetadataFlag byte
+
+const (
+	// MetadataOverwrite 覆盖文件原有的全部元数据
+	MetadataOverwrite MetadataFlag = STORAGE_SET_METADATA_FLAG_OVERWRITE
+	// MetadataMerge 与文件原有的元数据合并
+	MetadataMerge MetadataFlag = STORAGE_SET_METADATA_FLAG_MERGE
+)
+
 // UploadResponse 上传响应
 type UploadResponse struct {
 	GroupName string // 组名
@@ -104,13 +196,74 @@ type GroupInfo struct {
 	StorePathCount int    // 存储路径数量
 	SubdirCountPerPath int // 每个路径的子目录数量
 	CurrentTrunkFileID int // 当前Trunk文件ID
+	Storages       []*StorageInfo // 组内存储服务器列表
+}
+
+// StorageInfo 存储服务器状态信息
+type StorageInfo struct {
+	Status             byte   // 状态
+	ID                 string // 存储服务器ID
+	IPAddr             string // IP地址
+	DomainName         string // 域名
+	SrcID              string // 同步源存储服务器ID
+	Version            string // FastDFS版本
+	JoinTime           int64  // 加入集群时间
+	UpTime             int64  // 启动时间
+	TotalMB            int64  // 总容量(MB)
+	FreeMB             int64  // 剩余容量(MB)
+	UploadPriority     int    // 上传优先级
+	StorePathCount     int    // 存储路径数量
+	SubdirCountPerPath int    // 每个路径的子目录数量
+	StoragePort        int    // 存储服务端口
+	StorageHTTPPort    int    // HTTP端口
+	CurrentWritePath   int    // 当前写入路径索引
+	IsTrunkServer      bool   // 是否为trunk服务器
+}
+
+// IsActive 检查存储服务器是否处于活跃状态
+func (s *StorageInfo) IsActive() bool {
+	return s.Status == FDFS_STORAGE_STATUS_ACTIVE
+}
+
+// StatusName 获取存储服务器状态名称
+func (s *StorageInfo) StatusName() string {
+	switch s.Status {
+	case FDFS_STORAGE_STATUS_INIT:
+		return "INIT"
+	case FDFS_STORAGE_STATUS_WAIT_SYNC:
+		return "WAIT_SYNC"
+	case FDFS_STORAGE_STATUS_SYNCING:
+		return "SYNCING"
+	case FDFS_STORAGE_STATUS_IP_CHANGED:
+		return "IP_CHANGED"
+	case FDFS_STORAGE_STATUS_DELETED:
+		return "DELETED"
+	case FDFS_STORAGE_STATUS_OFFLINE:
+		return "OFFLINE"
+	case FDFS_STORAGE_STATUS_ONLINE:
+		return "ONLINE"
+	case FDFS_STORAGE_STATUS_ACTIVE:
+		return "ACTIVE"
+	case FDFS_STORAGE_STATUS_RECOVERY:
+		return "RECOVERY"
+	case FDFS_STORAGE_STATUS_NONE:
+		return "NONE"
+	default:
+		return "UNKNOWN"
+	}
 }
 
 // ConnectionPool 连接池接口
 type ConnectionPool interface {
 	Get() (*Client, error)
+	GetContext(ctx context.Context) (*Client, error)
 	Put(*Client) error
+	Discard(*Client)
+	DiscardFailed(*Client, error)
 	Close() error
 	Size() int
 	Available() int
+	Stats() PoolStats
+	TrackerStats() []TrackerStat
+	StorageStats() map[string]StorageHostStat
 }
\ No newline at end of file
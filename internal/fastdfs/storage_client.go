@@ -1,8 +1,12 @@
 package fastdfs
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"time"
 )
@@ -38,7 +42,7 @@ func (c *Client) listFilesFromStorage(groupName string, startFileName string, li
 	}
 	
 	if respHeader.Status != 0 {
-		return nil, fmt.Errorf("list files failed with status: %d", respHeader.Status)
+		return nil, newStatusError("list files", respHeader.Status)
 	}
 	
 	if respHeader.Length == 0 {
@@ -54,12 +58,13 @@ func (c *Client) listFilesFromStorage(groupName string, startFileName string, li
 	return parseFileList(respData)
 }
 
-// downloadFromStorage 从存储服务器下载文件
-func (c *Client) downloadFromStorage(groupName string, fileName string) ([]byte, error) {
+// downloadFromStorageTo 从存储服务器下载文件的指定区间，并以流的方式写入w
+// length为0表示从offset下载到文件末尾
+func (c *Client) downloadFromStorageTo(ctx context.Context, groupName string, fileName string, offset int64, length int64, w io.Writer) (int64, error) {
 	// 构建请求数据
 	data := make([]byte, 16+FDFS_GROUP_NAME_MAX_LEN+len(fileName))
-	binary.BigEndian.PutUint64(data[0:8], 0)  // 文件偏移量
-	binary.BigEndian.PutUint64(data[8:16], 0) // 下载字节数，0表示下载整个文件
+	binary.BigEndian.PutUint64(data[0:8], uint64(offset)) // 文件偏移量
+	binary.BigEndian.PutUint64(data[8:16], uint64(length)) // 下载字节数，0表示下载到文件末尾
 	copy(data[16:16+FDFS_GROUP_NAME_MAX_LEN], []byte(groupName))
 	copy(data[16+FDFS_GROUP_NAME_MAX_LEN:], []byte(fileName))
 	
@@ -71,49 +76,47 @@ func (c *Client) downloadFromStorage(groupName string, fileName string) ([]byte,
 	
 	err := c.sendHeader(header)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send download request: %w", err)
+		return 0, fmt.Errorf("failed to send download request: %w", err)
 	}
 	
 	err = c.sendData(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send download data: %w", err)
+		return 0, fmt.Errorf("failed to send download data: %w", err)
 	}
 	
 	// 接收响应
 	respHeader, err := c.receiveHeader()
 	if err != nil {
-		return nil, fmt.Errorf("failed to receive download response: %w", err)
+		return 0, fmt.Errorf("failed to receive download response: %w", err)
 	}
 	
 	if respHeader.Status != 0 {
-		return nil, fmt.Errorf("download failed with status: %d", respHeader.Status)
+		return 0, newStatusError("download", respHeader.Status)
 	}
 	
-	if respHeader.Length == 0 {
-		return []byte{}, nil
-	}
-	
-	fileData := make([]byte, respHeader.Length)
-	err = c.receiveData(fileData)
+	written, err := c.receiveStream(ctx, w, respHeader.Length)
 	if err != nil {
-		return nil, fmt.Errorf("failed to receive file data: %w", err)
+		return written, fmt.Errorf("failed to receive file data: %w", err)
 	}
 	
-	return fileData, nil
+	return written, nil
 }
 
-// uploadToStorage 上传文件到存储服务器
-func (c *Client) uploadToStorage(groupName string, fileName string, data []byte) (string, error) {
-	// 构建请求数据
-	extName := getFileExtension(fileName)
-	requestData := make([]byte, 1+FDFS_FILE_EXT_NAME_MAX_LEN+len(data))
-	requestData[0] = 0 // store_path_index
-	copy(requestData[1:1+FDFS_FILE_EXT_NAME_MAX_LEN], []byte(extName))
-	copy(requestData[1+FDFS_FILE_EXT_NAME_MAX_LEN:], data)
+// uploadToStorageFrom 以流的方式上传文件到存储服务器
+// command为STORAGE_PROTO_CMD_UPLOAD_FILE或STORAGE_PROTO_CMD_UPLOAD_APPENDER_FILE
+func (c *Client) uploadToStorageFrom(ctx context.Context, command byte, storePathIndex byte, extName string, size int64, r io.Reader) (string, error) {
+	// 构建请求头部: store_path_index(1) + file_size(8) + ext_name(6)，文件内容随后以流的方式发送
+	if len(extName) > FDFS_FILE_EXT_NAME_MAX_LEN {
+		extName = extName[:FDFS_FILE_EXT_NAME_MAX_LEN]
+	}
+	requestData := make([]byte, 1+FDFS_PROTO_PKG_LEN_SIZE+FDFS_FILE_EXT_NAME_MAX_LEN)
+	requestData[0] = storePathIndex
+	binary.BigEndian.PutUint64(requestData[1:1+FDFS_PROTO_PKG_LEN_SIZE], uint64(size))
+	copy(requestData[1+FDFS_PROTO_PKG_LEN_SIZE:], []byte(extName))
 	
 	header := &Header{
-		Length:  int64(len(requestData)),
-		Command: STORAGE_PROTO_CMD_UPLOAD_FILE,
+		Length:  int64(len(requestData)) + size,
+		Command: command,
 		Status:  0,
 	}
 	
@@ -127,6 +130,11 @@ func (c *Client) uploadToStorage(groupName string, fileName string, data []byte)
 		return "", fmt.Errorf("failed to send upload data: %w", err)
 	}
 	
+	err = c.sendStream(ctx, r, size)
+	if err != nil {
+		return "", fmt.Errorf("failed to send upload data: %w", err)
+	}
+	
 	// 接收响应
 	respHeader, err := c.receiveHeader()
 	if err != nil {
@@ -134,7 +142,7 @@ func (c *Client) uploadToStorage(groupName string, fileName string, data []byte)
 	}
 	
 	if respHeader.Status != 0 {
-		return "", fmt.Errorf("upload failed with status: %d", respHeader.Status)
+		return "", newStatusError("upload", respHeader.Status)
 	}
 	
 	if respHeader.Length < FDFS_GROUP_NAME_MAX_LEN {
@@ -151,6 +159,180 @@ func (c *Client) uploadToStorage(groupName string, fileName string, data []byte)
 	return fmt.Sprintf("%s/%s", uploadResp.GroupName, uploadResp.FileName), nil
 }
 
+// uploadSlaveToStorageFrom 以流的方式上传master文件的slave文件
+func (c *Client) uploadSlaveToStorageFrom(ctx context.Context, masterFileName string, prefixName string, extName string, size int64, r io.Reader) (string, error) {
+	if len(extName) > FDFS_FILE_EXT_NAME_MAX_LEN {
+		extName = extName[:FDFS_FILE_EXT_NAME_MAX_LEN]
+	}
+	
+	// 构建请求头部: master_filename_len(8) + file_size(8) + prefix_name(16) + ext_name(6) + master_filename
+	requestData := make([]byte, 2*FDFS_PROTO_PKG_LEN_SIZE+FDFS_FILE_PREFIX_MAX_LEN+FDFS_FILE_EXT_NAME_MAX_LEN+len(masterFileName))
+	binary.BigEndian.PutUint64(requestData[0:8], uint64(len(masterFileName)))
+	binary.BigEndian.PutUint64(requestData[8:16], uint64(size))
+	copy(requestData[16:16+FDFS_FILE_PREFIX_MAX_LEN], []byte(prefixName))
+	copy(requestData[16+FDFS_FILE_PREFIX_MAX_LEN:16+FDFS_FILE_PREFIX_MAX_LEN+FDFS_FILE_EXT_NAME_MAX_LEN], []byte(extName))
+	copy(requestData[16+FDFS_FILE_PREFIX_MAX_LEN+FDFS_FILE_EXT_NAME_MAX_LEN:], []byte(masterFileName))
+	
+	header := &Header{
+		Length:  int64(len(requestData)) + size,
+		Command: STORAGE_PROTO_CMD_UPLOAD_SLAVE_FILE,
+		Status:  0,
+	}
+	
+	err := c.sendHeader(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to send upload slave request: %w", err)
+	}
+	
+	err = c.sendData(requestData)
+	if err != nil {
+		return "", fmt.Errorf("failed to send upload slave data: %w", err)
+	}
+	
+	err = c.sendStream(ctx, r, size)
+	if err != nil {
+		return "", fmt.Errorf("failed to send upload slave data: %w", err)
+	}
+	
+	// 接收响应
+	respHeader, err := c.receiveHeader()
+	if err != nil {
+		return "", fmt.Errorf("failed to receive upload slave response: %w", err)
+	}
+	
+	if respHeader.Status != 0 {
+		return "", newStatusError("upload slave", respHeader.Status)
+	}
+	
+	if respHeader.Length < FDFS_GROUP_NAME_MAX_LEN {
+		return "", fmt.Errorf("invalid upload slave response length: %d", respHeader.Length)
+	}
+	
+	respData := make([]byte, respHeader.Length)
+	err = c.receiveData(respData)
+	if err != nil {
+		return "", fmt.Errorf("failed to receive upload slave response data: %w", err)
+	}
+	
+	uploadResp := parseUploadResponse(respData)
+	return fmt.Sprintf("%s/%s", uploadResp.GroupName, uploadResp.FileName), nil
+}
+
+// appendToStorageFrom 以流的方式向appender文件追加数据
+func (c *Client) appendToStorageFrom(ctx context.Context, fileName string, size int64, r io.Reader) error {
+	// 构建请求头部: filename_len(8) + file_size(8) + file_name，追加内容随后以流的方式发送
+	requestData := make([]byte, 2*FDFS_PROTO_PKG_LEN_SIZE+len(fileName))
+	binary.BigEndian.PutUint64(requestData[0:8], uint64(len(fileName)))
+	binary.BigEndian.PutUint64(requestData[8:16], uint64(size))
+	copy(requestData[16:], []byte(fileName))
+	
+	return c.sendUpdateRequest(ctx, STORAGE_PROTO_CMD_APPEND_FILE, "append", requestData, size, r)
+}
+
+// modifyToStorageFrom 以流的方式修改appender文件从offset开始的内容
+func (c *Client) modifyToStorageFrom(ctx context.Context, fileName string, offset int64, size int64, r io.Reader) error {
+	// 构建请求头部: filename_len(8) + file_offset(8) + modify_size(8) + file_name
+	requestData := make([]byte, 3*FDFS_PROTO_PKG_LEN_SIZE+len(fileName))
+	binary.BigEndian.PutUint64(requestData[0:8], uint64(len(fileName)))
+	binary.BigEndian.PutUint64(requestData[8:16], uint64(offset))
+	binary.BigEndian.PutUint64(requestData[16:24], uint64(size))
+	copy(requestData[24:], []byte(fileName))
+	
+	return c.sendUpdateRequest(ctx, STORAGE_PROTO_CMD_MODIFY_FILE, "modify", requestData, size, r)
+}
+
+// truncateOnStorage 截断appender文件
+func (c *Client) truncateOnStorage(ctx context.Context, fileName string, truncatedSize int64) error {
+	// 构建请求数据: filename_len(8) + truncated_file_size(8) + file_name
+	requestData := make([]byte, 2*FDFS_PROTO_PKG_LEN_SIZE+len(fileName))
+	binary.BigEndian.PutUint64(requestData[0:8], uint64(len(fileName)))
+	binary.BigEndian.PutUint64(requestData[8:16], uint64(truncatedSize))
+	copy(requestData[16:], []byte(fileName))
+	
+	return c.sendUpdateRequest(ctx, STORAGE_PROTO_CMD_TRUNCATE_FILE, "truncate", requestData, 0, nil)
+}
+
+// regenerateAppenderFileNameOnStorage 为appender文件重新生成普通文件的文件名，返回新的文件ID
+func (c *Client) regenerateAppenderFileNameOnStorage(appenderFileName string) (string, error) {
+	// 请求数据只包含appender文件名
+	header := &Header{
+		Length:  int64(len(appenderFileName)),
+		Command: STORAGE_PROTO_CMD_REGENERATE_APPENDER_FILENAME,
+		Status:  0,
+	}
+
+	err := c.sendHeader(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to send regenerate filename request: %w", err)
+	}
+
+	err = c.sendData([]byte(appenderFileName))
+	if err != nil {
+		return "", fmt.Errorf("failed to send regenerate filename data: %w", err)
+	}
+
+	// 接收响应，格式与上传响应相同: group_name(16) + file_name
+	respHeader, err := c.receiveHeader()
+	if err != nil {
+		return "", fmt.Errorf("failed to receive regenerate filename response: %w", err)
+	}
+
+	if respHeader.Status != 0 {
+		return "", newStatusError("regenerate filename", respHeader.Status)
+	}
+
+	if respHeader.Length < FDFS_GROUP_NAME_MAX_LEN {
+		return "", fmt.Errorf("invalid regenerate filename response length: %d", respHeader.Length)
+	}
+
+	respData := make([]byte, respHeader.Length)
+	err = c.receiveData(respData)
+	if err != nil {
+		return "", fmt.Errorf("failed to receive regenerate filename response data: %w", err)
+	}
+
+	resp := parseUploadResponse(respData)
+	return fmt.Sprintf("%s/%s", resp.GroupName, resp.FileName), nil
+}
+
+// sendUpdateRequest 发送appender文件的更新请求，请求数据后跟随size字节的文件内容
+func (c *Client) sendUpdateRequest(ctx context.Context, command byte, op string, requestData []byte, size int64, r io.Reader) error {
+	header := &Header{
+		Length:  int64(len(requestData)) + size,
+		Command: command,
+		Status:  0,
+	}
+	
+	err := c.sendHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request: %w", op, err)
+	}
+	
+	err = c.sendData(requestData)
+	if err != nil {
+		return fmt.Errorf("failed to send %s data: %w", op, err)
+	}
+	
+	if size > 0 {
+		err = c.sendStream(ctx, r, size)
+		if err != nil {
+			return fmt.Errorf("failed to send %s data: %w", op, err)
+		}
+	}
+	
+	// 接收响应
+	respHeader, err := c.receiveHeader()
+	if err != nil {
+		return fmt.Errorf("failed to receive %s response: %w", op, err)
+	}
+	
+	if respHeader.Status != 0 {
+		return newStatusError(op, respHeader.Status)
+	}
+	
+	return nil
+}
+
 // deleteFromStorage 从存储服务器删除文件
 func (c *Client) deleteFromStorage(groupName string, fileName string) error {
 	// 构建请求数据
@@ -181,7 +363,7 @@ func (c *Client) deleteFromStorage(groupName string, fileName string) error {
 	}
 	
 	if respHeader.Status != 0 {
-		return fmt.Errorf("delete failed with status: %d", respHeader.Status)
+		return newStatusError("delete", respHeader.Status)
 	}
 	
 	return nil
@@ -217,7 +399,7 @@ func (c *Client) getFileInfoFromStorage(groupName string, fileName string) (*Fil
 	}
 	
 	if respHeader.Status != 0 {
-		return nil, fmt.Errorf("get file info failed with status: %d", respHeader.Status)
+		return nil, newStatusError("get file info", respHeader.Status)
 	}
 	
 	if respHeader.Length < 3*8+IP_ADDRESS_SIZE {
@@ -233,6 +415,139 @@ func (c *Client) getFileInfoFromStorage(groupName string, fileName string) (*Fil
 	return parseFileInfo(groupName, fileName, respData)
 }
 
+// setMetadataToStorage 设置存储服务器上文件的元数据
+func (c *Client) setMetadataToStorage(groupName string, fileName string, metadata map[string]string, flag MetadataFlag) error {
+	metaData := packMetadata(metadata)
+	
+	// 构建请求数据: filename_len(8) + meta_len(8) + op_flag(1) + group_name(16) + file_name + meta_data
+	data := make([]byte, 2*FDFS_PROTO_PKG_LEN_SIZE+1+FDFS_GROUP_NAME_MAX_LEN+len(fileName)+len(metaData))
+	binary.BigEndian.PutUint64(data[0:8], uint64(len(fileName)))
+	binary.BigEndian.PutUint64(data[8:16], uint64(len(metaData)))
+	data[16] = byte(flag)
+	copy(data[17:17+FDFS_GROUP_NAME_MAX_LEN], []byte(groupName))
+	copy(data[17+FDFS_GROUP_NAME_MAX_LEN:], []byte(fileName))
+	copy(data[17+FDFS_GROUP_NAME_MAX_LEN+len(fileName):], metaData)
+	
+	header := &Header{
+		Length:  int64(len(data)),
+		Command: STORAGE_PROTO_CMD_SET_METADATA,
+		Status:  0,
+	}
+	
+	err := c.sendHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to send set metadata request: %w", err)
+	}
+	
+	err = c.sendData(data)
+	if err != nil {
+		return fmt.Errorf("failed to send set metadata data: %w", err)
+	}
+	
+	// 接收响应
+	respHeader, err := c.receiveHeader()
+	if err != nil {
+		return fmt.Errorf("failed to receive set metadata response: %w", err)
+	}
+	
+	if respHeader.Status != 0 {
+		return newStatusError("set metadata", respHeader.Status)
+	}
+	
+	return nil
+}
+
+// getMetadataFromStorage 从存储服务器获取文件的元数据
+func (c *Client) getMetadataFromStorage(groupName string, fileName string) (map[string]string, error) {
+	// 构建请求数据
+	data := make([]byte, FDFS_GROUP_NAME_MAX_LEN+len(fileName))
+	copy(data[0:FDFS_GROUP_NAME_MAX_LEN], []byte(groupName))
+	copy(data[FDFS_GROUP_NAME_MAX_LEN:], []byte(fileName))
+	
+	header := &Header{
+		Length:  int64(len(data)),
+		Command: STORAGE_PROTO_CMD_GET_METADATA,
+		Status:  0,
+	}
+	
+	err := c.sendHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send get metadata request: %w", err)
+	}
+	
+	err = c.sendData(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send get metadata data: %w", err)
+	}
+	
+	// 接收响应
+	respHeader, err := c.receiveHeader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive get metadata response: %w", err)
+	}
+	
+	if respHeader.Status != 0 {
+		return nil, newStatusError("get metadata", respHeader.Status)
+	}
+	
+	if respHeader.Length == 0 {
+		return map[string]string{}, nil
+	}
+	
+	respData := make([]byte, respHeader.Length)
+	err = c.receiveData(respData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive metadata: %w", err)
+	}
+	
+	return unpackMetadata(respData), nil
+}
+
+// packMetadata 将元数据编码为name\x02value\x01name\x02value格式
+func packMetadata(metadata map[string]string) []byte {
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	var buf bytes.Buffer
+	for i, name := range names {
+		value := metadata[name]
+		if len(name) > FDFS_MAX_META_NAME_LEN {
+			name = name[:FDFS_MAX_META_NAME_LEN]
+		}
+		if len(value) > FDFS_MAX_META_VALUE_LEN {
+			value = value[:FDFS_MAX_META_VALUE_LEN]
+		}
+		
+		if i > 0 {
+			buf.WriteByte(FDFS_RECORD_SEPERATOR)
+		}
+		buf.WriteString(name)
+		buf.WriteByte(FDFS_FIELD_SEPERATOR)
+		buf.WriteString(value)
+	}
+	return buf.Bytes()
+}
+
+// unpackMetadata 解析元数据响应
+func unpackMetadata(data []byte) map[string]string {
+	metadata := make(map[string]string)
+	for _, record := range strings.Split(string(data), string(rune(FDFS_RECORD_SEPERATOR))) {
+		if record == "" {
+			continue
+		}
+		fields := strings.SplitN(record, string(rune(FDFS_FIELD_SEPERATOR)), 2)
+		if len(fields) == 2 {
+			metadata[fields[0]] = fields[1]
+		} else {
+			metadata[fields[0]] = ""
+		}
+	}
+	return metadata
+}
+
 // parseFileList 解析文件列表响应
 func parseFileList(data []byte) ([]*FileInfo, error) {
 	var files []*FileInfo
@@ -271,7 +586,8 @@ func parseUploadResponse(data []byte) *UploadResponse {
 
 // parseFileInfo 解析文件信息响应
 func parseFileInfo(groupName, fileName string, data []byte) (*FileInfo, error) {
-	if len(data) < 3*8+4+IP_ADDRESS_SIZE {
+	// 响应格式: file_size(8) + create_timestamp(8) + crc32(8) + source_ip_addr(16)
+	if len(data) < 3*8+IP_ADDRESS_SIZE {
 		return nil, fmt.Errorf("invalid file info data length")
 	}
 	
@@ -280,8 +596,8 @@ func parseFileInfo(groupName, fileName string, data []byte) (*FileInfo, error) {
 		FileName:     fileName,
 		FileSize:     int64(binary.BigEndian.Uint64(data[0:8])),
 		CreateTime:   int64(binary.BigEndian.Uint64(data[8:16])),
-		CRC32:        binary.BigEndian.Uint32(data[24:28]),
-		SourceIPAddr: strings.TrimRight(string(data[28:28+IP_ADDRESS_SIZE]), "\x00"),
+		CRC32:        uint32(binary.BigEndian.Uint64(data[16:24])),
+		SourceIPAddr: strings.TrimRight(string(data[24:24+IP_ADDRESS_SIZE]), "\x00"),
 	}, nil
 }
 
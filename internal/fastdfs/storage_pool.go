@@ -0,0 +1,269 @@
+package fastdfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 存储服务器连接池默认配置
+const (
+	defaultStorageMaxPerHost    = 10
+	defaultStorageIdleTimeout   = 30 * time.Second
+	defaultStorageValidateAfter = 5 * time.Second
+)
+
+// StorageHostStat 单个存储服务器连接池的统计信息
+type StorageHostStat struct {
+	Active             int   `json:"active"`              // 正在使用的连接数
+	Idle               int   `json:"idle"`                // 空闲连接数
+	Hits               int64 `json:"hits"`                // 复用空闲连接的次数
+	Misses             int64 `json:"misses"`              // 新建连接的次数
+	Waits              int64 `json:"waits"`               // 因达到单机连接上限而等待的次数
+	Discarded          int64 `json:"discarded"`           // 因出错、校验失败或空闲超时而关闭的连接数
+	ValidationFailures int64 `json:"validation_failures"` // 空闲连接校验失败的次数
+}
+
+// StoragePool 按存储服务器地址（IP:端口）划分的连接池
+// 每个存储服务器的连接数（使用中与空闲之和）不超过maxPerHost，空闲超过idleTimeout的连接会被关闭
+// 空闲超过validateAfter的连接在取出时先发送ACTIVE_TEST校验
+type StoragePool struct {
+	maxPerHost    int
+	idleTimeout   time.Duration
+	validateAfter time.Duration
+	hosts         map[string]*storageHost
+	mu            sync.Mutex
+	closed        bool
+}
+
+// storageHost 单个存储服务器的连接
+type storageHost struct {
+	slots chan struct{} // 连接数令牌，获取连接前必须取得令牌
+	idle  []*idleStorageConn
+	stat  StorageHostStat
+}
+
+// idleStorageConn 空闲连接及其放回连接池的时间
+type idleStorageConn struct {
+	client *Client
+	since  time.Time
+}
+
+// NewStoragePool 创建存储服务器连接池
+func NewStoragePool(maxPerHost int, idleTimeout time.Duration) *StoragePool {
+	if maxPerHost <= 0 {
+		maxPerHost = defaultStorageMaxPerHost
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = defaultStorageIdleTimeout
+	}
+	return &StoragePool{
+		maxPerHost:    maxPerHost,
+		idleTimeout:   idleTimeout,
+		validateAfter: defaultStorageValidateAfter,
+		hosts:         make(map[string]*storageHost),
+	}
+}
+
+// Get 获取到指定存储服务器的连接，优先复用空闲连接
+// 达到单机连接上限时阻塞等待其他连接归还，直到ctx被取消
+func (sp *StoragePool) Get(ctx context.Context, storageServer *StorageServer) (*Client, error) {
+	key := storageKey(storageServer.IPAddr, storageServer.Port)
+
+	sp.mu.Lock()
+	if sp.closed {
+		sp.mu.Unlock()
+		return nil, fmt.Errorf("storage pool is closed")
+	}
+	host := sp.host(key)
+	sp.mu.Unlock()
+
+	select {
+	case host.slots <- struct{}{}:
+	default:
+		sp.mu.Lock()
+		host.stat.Waits++
+		sp.mu.Unlock()
+
+		select {
+		case host.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	sp.mu.Lock()
+	host.stat.Active++
+	sp.mu.Unlock()
+
+	for {
+		sp.mu.Lock()
+		client, idleFor := sp.takeIdle(host)
+		sp.mu.Unlock()
+		if client == nil {
+			break
+		}
+
+		// 空闲时间较短的连接直接复用，空闲较久的连接可能已被存储服务器关闭，需要先校验
+		var err error
+		if idleFor >= sp.validateAfter {
+			err = client.PingContext(ctx)
+		}
+		if err == nil {
+			sp.mu.Lock()
+			host.stat.Hits++
+			sp.mu.Unlock()
+			return client, nil
+		}
+
+		client.Close()
+		sp.mu.Lock()
+		host.stat.ValidationFailures++
+		host.stat.Discarded++
+		sp.mu.Unlock()
+		if ctx.Err() != nil {
+			sp.release(host, nil, false)
+			return nil, ctx.Err()
+		}
+	}
+
+	sp.mu.Lock()
+	host.stat.Misses++
+	sp.mu.Unlock()
+
+	client, err := dialStorage(ctx, storageServer)
+	if err != nil {
+		sp.release(host, nil, false)
+		return nil, err
+	}
+	return client, nil
+}
+
+// Put 归还连接
+// opErr为存储服务器返回的错误状态码时响应已被完整读取，连接仍可复用；其他错误时连接的协议状态未知，直接关闭
+func (sp *StoragePool) Put(client *Client, opErr error) {
+	if client == nil {
+		return
+	}
+
+	key := storageKey(client.trackerAddr, client.trackerPort)
+
+	sp.mu.Lock()
+	host, exists := sp.hosts[key]
+	sp.mu.Unlock()
+
+	if !exists {
+		client.Close()
+		return
+	}
+
+	if (opErr != nil && !isStatusError(opErr)) || !client.IsConnected() {
+		client.Close()
+		sp.release(host, nil, true)
+		return
+	}
+	sp.release(host, client, false)
+}
+
+// Stats 获取各存储服务器连接池的统计信息，键为IP:端口
+func (sp *StoragePool) Stats() map[string]StorageHostStat {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	stats := make(map[string]StorageHostStat, len(sp.hosts))
+	for key, host := range sp.hosts {
+		sp.evictIdle(host)
+		stat := host.stat
+		stat.Idle = len(host.idle)
+		stats[key] = stat
+	}
+	return stats
+}
+
+// Close 关闭连接池及所有空闲连接，使用中的连接在归还时关闭
+func (sp *StoragePool) Close() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.closed {
+		return nil
+	}
+	sp.closed = true
+
+	for _, host := range sp.hosts {
+		for _, conn := range host.idle {
+			conn.client.Close()
+		}
+		host.idle = nil
+	}
+	return nil
+}
+
+// host 获取存储服务器对应的连接，调用方需持有锁
+func (sp *StoragePool) host(key string) *storageHost {
+	host, exists := sp.hosts[key]
+	if !exists {
+		host = &storageHost{slots: make(chan struct{}, sp.maxPerHost)}
+		sp.hosts[key] = host
+	}
+	return host
+}
+
+// takeIdle 取出最近归还的空闲连接及其空闲时长，调用方需持有锁
+func (sp *StoragePool) takeIdle(host *storageHost) (*Client, time.Duration) {
+	sp.evictIdle(host)
+	if len(host.idle) == 0 {
+		return nil, 0
+	}
+	conn := host.idle[len(host.idle)-1]
+	host.idle = host.idle[:len(host.idle)-1]
+	return conn.client, time.Since(conn.since)
+}
+
+// evictIdle 关闭空闲超时的连接，调用方需持有锁
+func (sp *StoragePool) evictIdle(host *storageHost) {
+	kept := host.idle[:0]
+	for _, conn := range host.idle {
+		if time.Since(conn.since) >= sp.idleTimeout {
+			conn.client.Close()
+			host.stat.Discarded++
+			continue
+		}
+		kept = append(kept, conn)
+	}
+	host.idle = kept
+}
+
+// release 归还连接令牌，client不为nil时放回空闲列表，discarded表示连接因出错已被关闭
+func (sp *StoragePool) release(host *storageHost, client *Client, discarded bool) {
+	sp.mu.Lock()
+	host.stat.Active--
+	if discarded {
+		host.stat.Discarded++
+	}
+	if client != nil {
+		if sp.closed {
+			client.Close()
+		} else {
+			host.idle = append(host.idle, &idleStorageConn{client: client, since: time.Now()})
+		}
+	}
+	sp.mu.Unlock()
+
+	<-host.slots
+}
+
+// isStatusError 判断err是否为服务端返回的错误状态码
+func isStatusError(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr)
+}
+
+// storageKey 获取存储服务器的连接池键
+func storageKey(ipAddr string, port int) string {
+	return net.JoinHostPort(ipAddr, strconv.Itoa(port))
+}
@@ -0,0 +1,222 @@
+package fastdfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startTestStorageListener 启动一个只接受连接的存储服务器，返回其地址和已接受的连接数
+func startTestStorageListener(t *testing.T) (*StorageServer, *int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	var accepted int32
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return &StorageServer{IPAddr: "127.0.0.1", Port: addr.Port}, &accepted
+}
+
+func TestStoragePool_Reuse(t *testing.T) {
+	server, accepted := startTestStorageListener(t)
+	pool := NewStoragePool(2, time.Minute)
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	pool.Put(first, nil)
+
+	second, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	if second != first {
+		t.Error("Expected idle connection to be reused")
+	}
+	pool.Put(second, nil)
+
+	stat := pool.Stats()[storageKey(server.IPAddr, server.Port)]
+	if stat.Hits != 1 || stat.Misses != 1 || stat.Idle != 1 || stat.Active != 0 {
+		t.Errorf("Unexpected stats: %+v", stat)
+	}
+	if n := atomic.LoadInt32(accepted); n != 1 {
+		t.Errorf("Expected 1 connection to storage, got %d", n)
+	}
+}
+
+func TestStoragePool_DiscardOnError(t *testing.T) {
+	server, _ := startTestStorageListener(t)
+	pool := NewStoragePool(2, time.Minute)
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	pool.Put(first, errors.New("protocol error"))
+
+	second, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	defer pool.Put(second, nil)
+
+	if second == first {
+		t.Error("Expected failed connection not to be reused")
+	}
+
+	stat := pool.Stats()[storageKey(server.IPAddr, server.Port)]
+	if stat.Discarded != 1 || stat.Misses != 2 {
+		t.Errorf("Unexpected stats: %+v", stat)
+	}
+}
+
+func TestStoragePool_IdleTimeout(t *testing.T) {
+	server, _ := startTestStorageListener(t)
+	pool := NewStoragePool(2, 10*time.Millisecond)
+	defer pool.Close()
+
+	client, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	pool.Put(client, nil)
+
+	time.Sleep(20 * time.Millisecond)
+
+	stat := pool.Stats()[storageKey(server.IPAddr, server.Port)]
+	if stat.Idle != 0 || stat.Discarded != 1 {
+		t.Errorf("Expected idle connection to be evicted, got %+v", stat)
+	}
+}
+
+func TestStoragePool_MaxPerHost(t *testing.T) {
+	server, _ := startTestStorageListener(t)
+	pool := NewStoragePool(1, time.Minute)
+	defer pool.Close()
+
+	client, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx, server); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded when host limit is reached, got %v", err)
+	}
+
+	pool.Put(client, nil)
+
+	client, err = pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Expected connection after release, got %v", err)
+	}
+	pool.Put(client, nil)
+
+	stat := pool.Stats()[storageKey(server.IPAddr, server.Port)]
+	if stat.Waits != 1 {
+		t.Errorf("Expected 1 wait, got %+v", stat)
+	}
+}
+
+func TestStoragePool_KeepOnStatusError(t *testing.T) {
+	server, accepted := startTestStorageListener(t)
+	pool := NewStoragePool(2, time.Minute)
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	// 错误状态码的响应已被完整读取，连接可以继续复用
+	pool.Put(first, fmt.Errorf("failed to download: %w", newStatusError("download", 2)))
+
+	second, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	defer pool.Put(second, nil)
+
+	if second != first {
+		t.Error("Expected connection to be reused after a status error")
+	}
+	if n := atomic.LoadInt32(accepted); n != 1 {
+		t.Errorf("Expected 1 connection to storage, got %d", n)
+	}
+}
+
+func TestStoragePool_ValidateAfter(t *testing.T) {
+	// 存储服务器接受连接后立即关闭，模拟空闲连接被服务端关闭
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	server := &StorageServer{IPAddr: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+
+	pool := NewStoragePool(2, time.Minute)
+	pool.validateAfter = time.Nanosecond
+	defer pool.Close()
+
+	first, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	pool.Put(first, nil)
+
+	second, err := pool.Get(context.Background(), server)
+	if err != nil {
+		t.Fatalf("Unexpected get error: %v", err)
+	}
+	defer pool.Put(second, nil)
+
+	if second == first {
+		t.Error("Expected closed idle connection not to be reused")
+	}
+
+	stat := pool.Stats()[storageKey(server.IPAddr, server.Port)]
+	if stat.ValidationFailures != 1 || stat.Hits != 0 || stat.Misses != 2 || stat.Discarded != 1 {
+		t.Errorf("Unexpected stats: %+v", stat)
+	}
+}
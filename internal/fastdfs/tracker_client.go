@@ -0,0 +1,301 @@
+package fastdfs
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// QueryFetchStorage 查询一个可以读取指定文件的存储服务器
+func (c *Client) QueryFetchStorage(groupName string, fileName string) (*StorageServer, error) {
+	servers, err := c.queryStorageByFile(context.Background(), TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ONE, groupName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return servers[0], nil
+}
+
+// QueryFetchAllStorages 查询持有指定文件的所有存储服务器
+func (c *Client) QueryFetchAllStorages(groupName string, fileName string) ([]*StorageServer, error) {
+	return c.queryStorageByFile(context.Background(), TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL, groupName, fileName)
+}
+
+// QueryUpdateStorage 查询可以更新（删除、设置元数据等）指定文件的源存储服务器
+func (c *Client) QueryUpdateStorage(groupName string, fileName string) (*StorageServer, error) {
+	servers, err := c.queryStorageByFile(context.Background(), TRACKER_PROTO_CMD_SERVICE_QUERY_UPDATE, groupName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return servers[0], nil
+}
+
+// ResolveStorageID 通过tracker将组内的storage ID解析为存储服务器地址
+func (c *Client) ResolveStorageID(groupName string, storageID string) (*StorageServer, error) {
+	storages, err := c.ListStorages(groupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storages of group %s: %w", groupName, err)
+	}
+
+	for _, storage := range storages {
+		if storage.ID == storageID {
+			return &StorageServer{GroupName: groupName, IPAddr: storage.IPAddr, Port: storage.StoragePort}, nil
+		}
+	}
+	return nil, fmt.Errorf("storage ID %s not found in group %s", storageID, groupName)
+}
+
+// GetSourceStorage 获取上传文件的源存储服务器地址，文件名中记录storage ID或IP时都适用
+func (c *Client) GetSourceStorage(fileID string) (*StorageServer, error) {
+	info, err := DecodeFileID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file ID: %w", err)
+	}
+
+	storages, err := c.ListStorages(info.GroupName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storages of group %s: %w", info.GroupName, err)
+	}
+
+	storage := info.FindSourceStorage(storages)
+	if storage == nil {
+		return nil, fmt.Errorf("source storage %s of %s not found in group %s", info.Source(), fileID, info.GroupName)
+	}
+	return &StorageServer{GroupName: info.GroupName, IPAddr: storage.IPAddr, Port: storage.StoragePort}, nil
+}
+
+// queryStorageByFile 按组名和文件名向tracker查询存储服务器
+func (c *Client) queryStorageByFile(ctx context.Context, command byte, groupName string, fileName string) ([]*StorageServer, error) {
+	// 构建请求数据: group_name(16) + file_name
+	data := make([]byte, FDFS_GROUP_NAME_MAX_LEN+len(fileName))
+	copy(data[0:FDFS_GROUP_NAME_MAX_LEN], []byte(groupName))
+	copy(data[FDFS_GROUP_NAME_MAX_LEN:], []byte(fileName))
+
+	respData, err := c.trackerRequest(ctx, command, "query storage", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFetchStorageServers(respData)
+}
+
+// parseFetchStorageServers 解析fetch/update查询响应
+// 响应格式: group_name(16) + ip_addr(15) + port(8)，FETCH_ALL时后面跟随其余副本的ip_addr(15)
+func parseFetchStorageServers(data []byte) ([]*StorageServer, error) {
+	if len(data) < TRACKER_QUERY_STORAGE_FETCH_BODY_LEN {
+		return nil, fmt.Errorf("invalid fetch storage response length: %d", len(data))
+	}
+
+	extra := len(data) - TRACKER_QUERY_STORAGE_FETCH_BODY_LEN
+	if extra%(IP_ADDRESS_SIZE-1) != 0 {
+		return nil, fmt.Errorf("invalid fetch storage response length: %d", len(data))
+	}
+
+	groupName := strings.TrimRight(string(data[0:FDFS_GROUP_NAME_MAX_LEN]), "\x00")
+	offset := FDFS_GROUP_NAME_MAX_LEN
+	ipAddr := strings.TrimRight(string(data[offset:offset+IP_ADDRESS_SIZE-1]), "\x00")
+	offset += IP_ADDRESS_SIZE - 1
+	port := int(binary.BigEndian.Uint64(data[offset : offset+FDFS_PROTO_PKG_LEN_SIZE]))
+	offset += FDFS_PROTO_PKG_LEN_SIZE
+
+	servers := []*StorageServer{{GroupName: groupName, IPAddr: ipAddr, Port: port}}
+	for ; offset < len(data); offset += IP_ADDRESS_SIZE - 1 {
+		servers = append(servers, &StorageServer{
+			GroupName: groupName,
+			IPAddr:    strings.TrimRight(string(data[offset:offset+IP_ADDRESS_SIZE-1]), "\x00"),
+			Port:      port,
+		})
+	}
+
+	return servers, nil
+}
+
+// ListGroups 列出集群中所有组的统计信息
+func (c *Client) ListGroups() ([]*GroupInfo, error) {
+	respData, err := c.trackerRequest(context.Background(), TRACKER_PROTO_CMD_SERVER_LIST_ALL_GROUPS, "list groups", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(respData)%TRACKER_GROUP_STAT_LEN != 0 {
+		return nil, fmt.Errorf("invalid list groups response length: %d", len(respData))
+	}
+
+	groups := make([]*GroupInfo, 0, len(respData)/TRACKER_GROUP_STAT_LEN)
+	for offset := 0; offset < len(respData); offset += TRACKER_GROUP_STAT_LEN {
+		groups = append(groups, parseGroupStat(respData[offset:offset+TRACKER_GROUP_STAT_LEN]))
+	}
+
+	return groups, nil
+}
+
+// ListGroup 获取指定组的统计信息
+func (c *Client) ListGroup(groupName string) (*GroupInfo, error) {
+	data := make([]byte, FDFS_GROUP_NAME_MAX_LEN)
+	copy(data, []byte(groupName))
+
+	respData, err := c.trackerRequest(context.Background(), TRACKER_PROTO_CMD_SERVER_LIST_ONE_GROUP, "list group", data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(respData) != TRACKER_GROUP_STAT_LEN {
+		return nil, fmt.Errorf("invalid list group response length: %d", len(respData))
+	}
+
+	return parseGroupStat(respData), nil
+}
+
+// ListStorages 列出指定组内所有存储服务器的状态
+func (c *Client) ListStorages(groupName string) ([]*StorageInfo, error) {
+	data := make([]byte, FDFS_GROUP_NAME_MAX_LEN)
+	copy(data, []byte(groupName))
+
+	respData, err := c.trackerRequest(context.Background(), TRACKER_PROTO_CMD_SERVER_LIST_STORAGE, "list storage", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseStorageStats(respData)
+}
+
+// GetTopology 获取集群拓扑，包括所有组及组内存储服务器的状态
+func (c *Client) GetTopology() ([]*GroupInfo, error) {
+	groups, err := c.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		storages, err := c.ListStorages(group.GroupName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list storages of group %s: %w", group.GroupName, err)
+		}
+		group.Storages = storages
+	}
+
+	return groups, nil
+}
+
+// trackerRequest 在ctx的控制下向tracker发送请求并读取完整的响应体
+func (c *Client) trackerRequest(ctx context.Context, command byte, op string, data []byte) ([]byte, error) {
+	var respData []byte
+	err := c.withContext(ctx, func() error {
+		header := &Header{
+			Length:  int64(len(data)),
+			Command: command,
+			Status:  0,
+		}
+
+		err := c.sendHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to send %s request: %w", op, err)
+		}
+
+		if len(data) > 0 {
+			err = c.sendData(data)
+			if err != nil {
+				return fmt.Errorf("failed to send %s data: %w", op, err)
+			}
+		}
+
+		// 接收响应
+		respHeader, err := c.receiveHeader()
+		if err != nil {
+			return fmt.Errorf("failed to receive %s response: %w", op, err)
+		}
+
+		if respHeader.Status != 0 {
+			return newStatusError(op, respHeader.Status)
+		}
+
+		respData = make([]byte, respHeader.Length)
+		err = c.receiveData(respData)
+		if err != nil {
+			return fmt.Errorf("failed to receive %s data: %w", op, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// parseGroupStat 解析组统计信息
+func parseGroupStat(data []byte) *GroupInfo {
+	offset := FDFS_GROUP_NAME_MAX_LEN + 1
+	next := func() int64 {
+		value := int64(binary.BigEndian.Uint64(data[offset : offset+FDFS_PROTO_PKG_LEN_SIZE]))
+		offset += FDFS_PROTO_PKG_LEN_SIZE
+		return value
+	}
+
+	return &GroupInfo{
+		GroupName:          strings.TrimRight(string(data[0:FDFS_GROUP_NAME_MAX_LEN+1]), "\x00"),
+		TotalMB:            next(),
+		FreeMB:             next(),
+		TrunkFreeMB:        next(),
+		StorageCount:       int(next()),
+		StoragePort:        int(next()),
+		StorageHTTPPort:    int(next()),
+		ActiveCount:        int(next()),
+		CurrentWriteServer: int(next()),
+		StorePathCount:     int(next()),
+		SubdirCountPerPath: int(next()),
+		CurrentTrunkFileID: int(next()),
+	}
+}
+
+// parseStorageStats 解析存储服务器统计信息，5.x与6.x的每条记录长度均为TRACKER_STORAGE_STAT_LEN
+func parseStorageStats(data []byte) ([]*StorageInfo, error) {
+	if len(data)%TRACKER_STORAGE_STAT_LEN != 0 {
+		return nil, fmt.Errorf("invalid list storage response length %d", len(data))
+	}
+
+	storages := make([]*StorageInfo, 0, len(data)/TRACKER_STORAGE_STAT_LEN)
+	for offset := 0; offset < len(data); offset += TRACKER_STORAGE_STAT_LEN {
+		storages = append(storages, parseStorageStat(data[offset:offset+TRACKER_STORAGE_STAT_LEN]))
+	}
+
+	return storages, nil
+}
+
+// parseStorageStat 解析单条存储服务器统计信息，统计计数器不需要，直接跳过
+func parseStorageStat(data []byte) *StorageInfo {
+	offset := 0
+	nextString := func(size int) string {
+		value := strings.TrimRight(string(data[offset:offset+size]), "\x00")
+		offset += size
+		return value
+	}
+	nextInt := func() int64 {
+		value := int64(binary.BigEndian.Uint64(data[offset : offset+FDFS_PROTO_PKG_LEN_SIZE]))
+		offset += FDFS_PROTO_PKG_LEN_SIZE
+		return value
+	}
+
+	storage := &StorageInfo{Status: data[0]}
+	offset++
+	storage.ID = nextString(FDFS_STORAGE_ID_MAX_SIZE)
+	storage.IPAddr = nextString(IP_ADDRESS_SIZE)
+	storage.DomainName = nextString(FDFS_DOMAIN_NAME_MAX_SIZE)
+	storage.SrcID = nextString(FDFS_STORAGE_ID_MAX_SIZE)
+	storage.Version = nextString(FDFS_VERSION_SIZE)
+	storage.JoinTime = nextInt()
+	storage.UpTime = nextInt()
+	storage.TotalMB = nextInt()
+	storage.FreeMB = nextInt()
+	storage.UploadPriority = int(nextInt())
+	storage.StorePathCount = int(nextInt())
+	storage.SubdirCountPerPath = int(nextInt())
+	storage.CurrentWritePath = int(nextInt())
+	storage.StoragePort = int(nextInt())
+	storage.StorageHTTPPort = int(nextInt())
+	// if_trunk_server位于统计计数器之后，是记录的最后一个字节
+	storage.IsTrunkServer = data[len(data)-1] != 0
+
+	return storage
+}
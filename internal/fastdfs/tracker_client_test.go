@@ -0,0 +1,238 @@
+package fastdfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// buildFetchResponse 构造fetch查询响应体
+func buildFetchResponse(groupName string, port int, ips ...string) []byte {
+	data := make([]byte, TRACKER_QUERY_STORAGE_FETCH_BODY_LEN+(len(ips)-1)*(IP_ADDRESS_SIZE-1))
+	copy(data[0:FDFS_GROUP_NAME_MAX_LEN], groupName)
+	offset := FDFS_GROUP_NAME_MAX_LEN
+	copy(data[offset:offset+IP_ADDRESS_SIZE-1], ips[0])
+	offset += IP_ADDRESS_SIZE - 1
+	binary.BigEndian.PutUint64(data[offset:offset+FDFS_PROTO_PKG_LEN_SIZE], uint64(port))
+	offset += FDFS_PROTO_PKG_LEN_SIZE
+	for _, ip := range ips[1:] {
+		copy(data[offset:offset+IP_ADDRESS_SIZE-1], ip)
+		offset += IP_ADDRESS_SIZE - 1
+	}
+	return data
+}
+
+func TestParseFetchStorageServers(t *testing.T) {
+	data := buildFetchResponse("group1", 23000, "192.168.1.10", "192.168.1.11", "192.168.1.12")
+
+	servers, err := parseFetchStorageServers(data)
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+
+	if len(servers) != 3 {
+		t.Fatalf("Expected 3 servers, got %d", len(servers))
+	}
+
+	expected := []string{"192.168.1.10", "192.168.1.11", "192.168.1.12"}
+	for i, server := range servers {
+		if server.GroupName != "group1" {
+			t.Errorf("Expected group1, got %s", server.GroupName)
+		}
+		if server.IPAddr != expected[i] {
+			t.Errorf("Expected IP %s, got %s", expected[i], server.IPAddr)
+		}
+		if server.Port != 23000 {
+			t.Errorf("Expected port 23000, got %d", server.Port)
+		}
+	}
+}
+
+func TestParseFetchStorageServers_InvalidLength(t *testing.T) {
+	if _, err := parseFetchStorageServers(make([]byte, TRACKER_QUERY_STORAGE_FETCH_BODY_LEN-1)); err == nil {
+		t.Error("Expected error for short response")
+	}
+
+	if _, err := parseFetchStorageServers(make([]byte, TRACKER_QUERY_STORAGE_FETCH_BODY_LEN+3)); err == nil {
+		t.Error("Expected error for misaligned response")
+	}
+}
+
+func TestQueryFetchAllStorages(t *testing.T) {
+	client, server := newPipeClient(t)
+
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		if header.Command != TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ALL {
+			t.Errorf("Expected fetch all command, got %d", header.Command)
+		}
+
+		body := make([]byte, header.Length)
+		io.ReadFull(server, body)
+		if fileName := string(body[FDFS_GROUP_NAME_MAX_LEN:]); fileName != "M00/00/00/test.jpg" {
+			t.Errorf("Unexpected file name %s", fileName)
+		}
+
+		resp := buildFetchResponse("group1", 23000, "10.0.0.1", "10.0.0.2")
+		writeTestHeader(server, int64(len(resp)), 0)
+		server.Write(resp)
+	}()
+
+	servers, err := client.QueryFetchAllStorages("group1", "M00/00/00/test.jpg")
+	if err != nil {
+		t.Fatalf("Unexpected query error: %v", err)
+	}
+
+	if len(servers) != 2 || servers[1].IPAddr != "10.0.0.2" {
+		t.Errorf("Unexpected servers: %+v", servers)
+	}
+}
+
+func TestQueryUpdateStorage_ErrorStatus(t *testing.T) {
+	client, server := newPipeClient(t)
+
+	go func() {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		io.CopyN(io.Discard, server, header.Length)
+		writeTestHeader(server, 0, 2)
+	}()
+
+	_, err := client.QueryUpdateStorage("group1", "M00/00/00/missing.jpg")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound for status 2, got %v", err)
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != 2 {
+		t.Errorf("Expected StatusError with status 2, got %v", err)
+	}
+}
+
+// buildGroupStat 构造组统计信息记录
+func buildGroupStat(groupName string, fields ...int64) []byte {
+	data := make([]byte, TRACKER_GROUP_STAT_LEN)
+	copy(data, groupName)
+	offset := FDFS_GROUP_NAME_MAX_LEN + 1
+	for _, field := range fields {
+		binary.BigEndian.PutUint64(data[offset:offset+FDFS_PROTO_PKG_LEN_SIZE], uint64(field))
+		offset += FDFS_PROTO_PKG_LEN_SIZE
+	}
+	return data
+}
+
+// buildStorageStat 按FDFSStorageInfoBuff的布局构造存储服务器统计信息记录，统计计数器填充非0值
+func buildStorageStat(status byte, id string, ipAddr string, version string, port int64, trunkServer bool) []byte {
+	data := make([]byte, TRACKER_STORAGE_STAT_LEN)
+	data[0] = status
+	offset := 1
+	copy(data[offset:], id)
+	offset += FDFS_STORAGE_ID_MAX_SIZE
+	copy(data[offset:], ipAddr)
+	offset += IP_ADDRESS_SIZE + FDFS_DOMAIN_NAME_MAX_SIZE + FDFS_STORAGE_ID_MAX_SIZE
+	copy(data[offset:], version)
+	offset += FDFS_VERSION_SIZE
+	// join_time, up_time, total_mb, free_mb, upload_priority, store_path_count,
+	// subdir_count_per_path, current_write_path, storage_port, storage_http_port
+	for _, field := range []int64{1448866156, 1448866200, 1024, 512, 10, 2, 256, 1, port, 8888} {
+		binary.BigEndian.PutUint64(data[offset:], uint64(field))
+		offset += FDFS_PROTO_PKG_LEN_SIZE
+	}
+	for i := 0; i < FDFS_STORAGE_STAT_BUFF_LEN; i++ {
+		data[offset+i] = 0xff
+	}
+	offset += FDFS_STORAGE_STAT_BUFF_LEN
+	if trunkServer {
+		data[offset] = 1
+	}
+	return data
+}
+
+// serveTrackerResponses 依次读取请求并返回对应的响应体
+func serveTrackerResponses(t *testing.T, server net.Conn, commands []byte, responses [][]byte) {
+	for i, command := range commands {
+		header := readTestHeader(t, server)
+		if header == nil {
+			return
+		}
+		if header.Command != command {
+			t.Errorf("Expected command %d, got %d", command, header.Command)
+		}
+		io.CopyN(io.Discard, server, header.Length)
+		writeTestHeader(server, int64(len(responses[i])), 0)
+		server.Write(responses[i])
+	}
+}
+
+func TestListGroups(t *testing.T) {
+	client, server := newPipeClient(t)
+
+	resp := append(buildGroupStat("group1", 1024, 512, 0, 2, 23000, 8888, 2), buildGroupStat("group2", 2048, 100, 0, 1, 23001, 8888, 0)...)
+	go serveTrackerResponses(t, server, []byte{TRACKER_PROTO_CMD_SERVER_LIST_ALL_GROUPS}, [][]byte{resp})
+
+	groups, err := client.ListGroups()
+	if err != nil {
+		t.Fatalf("Unexpected list groups error: %v", err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(groups))
+	}
+
+	if groups[0].GroupName != "group1" || groups[0].TotalMB != 1024 || groups[0].FreeMB != 512 {
+		t.Errorf("Unexpected group1 info: %+v", groups[0])
+	}
+	if groups[0].StorageCount != 2 || groups[0].ActiveCount != 2 || groups[0].StoragePort != 23000 {
+		t.Errorf("Unexpected group1 storage info: %+v", groups[0])
+	}
+	if groups[1].GroupName != "group2" || groups[1].ActiveCount != 0 {
+		t.Errorf("Unexpected group2 info: %+v", groups[1])
+	}
+}
+
+func TestListStorages(t *testing.T) {
+	client, server := newPipeClient(t)
+
+	storages := append(
+		buildStorageStat(FDFS_STORAGE_STATUS_ACTIVE, "100001", "10.0.0.1", "6.06", 23000, true),
+		buildStorageStat(FDFS_STORAGE_STATUS_OFFLINE, "100002", "10.0.0.2", "6.06", 23000, false)...,
+	)
+	go serveTrackerResponses(t, server, []byte{TRACKER_PROTO_CMD_SERVER_LIST_STORAGE}, [][]byte{storages})
+
+	result, err := client.ListStorages("group1")
+	if err != nil {
+		t.Fatalf("Unexpected list storages error: %v", err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 storages, got %d", len(result))
+	}
+
+	if result[0].ID != "100001" || result[0].IPAddr != "10.0.0.1" || result[0].Version != "6.06" {
+		t.Errorf("Unexpected storage info: %+v", result[0])
+	}
+	if !result[0].IsActive() || result[0].StoragePort != 23000 || result[0].StorageHTTPPort != 8888 ||
+		result[0].CurrentWritePath != 1 || result[0].TotalMB != 1024 || !result[0].IsTrunkServer {
+		t.Errorf("Unexpected storage state: %+v", result[0])
+	}
+	if result[1].IsActive() || result[1].StatusName() != "OFFLINE" || result[1].IsTrunkServer {
+		t.Errorf("Expected second storage offline, got %+v", result[1])
+	}
+}
+
+func TestParseStorageStats_InvalidLength(t *testing.T) {
+	if _, err := parseStorageStats(make([]byte, TRACKER_STORAGE_STAT_LEN*2-1)); err == nil {
+		t.Error("Expected error for misaligned response")
+	}
+
+	if storages, err := parseStorageStats(nil); err != nil || len(storages) != 0 {
+		t.Errorf("Expected no storages for an empty group, got %v (%v)", storages, err)
+	}
+}
@@ -0,0 +1,126 @@
+package fastdfs
+
+import (
+	"sync"
+	"time"
+
+	"fastdfs-migration-system/internal/models"
+)
+
+// trackerRetryInterval 不健康的tracker在此时间之后才会被再次尝试
+const trackerRetryInterval = 30 * time.Second
+
+// TrackerStat 单个tracker的健康状态
+type TrackerStat struct {
+	Addr                string    `json:"addr"`
+	Port                int       `json:"port"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	TotalFailures       int64     `json:"total_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+}
+
+// trackerSet 一个集群的多个tracker，按轮询顺序选择并在失败时切换到其他tracker
+type trackerSet struct {
+	trackers []*TrackerStat
+	next     int
+	mu       sync.Mutex
+}
+
+// newTrackerSet 创建tracker集合，所有tracker初始都视为健康
+func newTrackerSet(endpoints []models.TrackerEndpoint) *trackerSet {
+	ts := &trackerSet{}
+	for _, endpoint := range endpoints {
+		ts.trackers = append(ts.trackers, &TrackerStat{
+			Addr:    endpoint.Addr,
+			Port:    endpoint.Port,
+			Healthy: true,
+		})
+	}
+	return ts
+}
+
+// candidates 返回本次连接应依次尝试的tracker
+// 健康的tracker按轮询顺序排在前面，距上次失败已超过重试间隔的不健康tracker排在后面；
+// 所有tracker都不可尝试时返回全部tracker，避免集群因状态过期而彻底不可用
+func (ts *trackerSet) candidates() []models.TrackerEndpoint {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if len(ts.trackers) == 0 {
+		return nil
+	}
+
+	start := ts.next % len(ts.trackers)
+	ts.next++
+
+	var healthy, retry, all []models.TrackerEndpoint
+	for i := range ts.trackers {
+		tracker := ts.trackers[(start+i)%len(ts.trackers)]
+		endpoint := models.TrackerEndpoint{Addr: tracker.Addr, Port: tracker.Port}
+		all = append(all, endpoint)
+
+		if tracker.Healthy {
+			healthy = append(healthy, endpoint)
+		} else if time.Since(tracker.LastFailure) >= trackerRetryInterval {
+			retry = append(retry, endpoint)
+		}
+	}
+
+	if len(healthy)+len(retry) == 0 {
+		return all
+	}
+	return append(healthy, retry...)
+}
+
+// markSuccess 记录tracker请求成功
+func (ts *trackerSet) markSuccess(addr string, port int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if tracker := ts.find(addr, port); tracker != nil {
+		tracker.Healthy = true
+		tracker.ConsecutiveFailures = 0
+		tracker.LastSuccess = time.Now()
+	}
+}
+
+// markFailure 记录tracker连接被拒绝或请求失败，tracker将在重试间隔内被跳过
+func (ts *trackerSet) markFailure(addr string, port int, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if tracker := ts.find(addr, port); tracker != nil {
+		tracker.Healthy = false
+		tracker.ConsecutiveFailures++
+		tracker.TotalFailures++
+		tracker.LastFailure = time.Now()
+		if err != nil {
+			tracker.LastError = err.Error()
+		}
+	}
+}
+
+// stats 获取所有tracker的健康状态快照
+func (ts *trackerSet) stats() []TrackerStat {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	stats := make([]TrackerStat, 0, len(ts.trackers))
+	for _, tracker := range ts.trackers {
+		stats = append(stats, *tracker)
+	}
+	return stats
+}
+
+// find 按地址查找tracker，调用方需持有锁
+func (ts *trackerSet) find(addr string, port int) *TrackerStat {
+	for _, tracker := range ts.trackers {
+		if tracker.Addr == addr && tracker.Port == port {
+			return tracker
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package fastdfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"fastdfs-migration-system/internal/models"
+)
+
+func TestTrackerSet_RoundRobin(t *testing.T) {
+	ts := newTrackerSet([]models.TrackerEndpoint{
+		{Addr: "10.0.0.1", Port: 22122},
+		{Addr: "10.0.0.2", Port: 22122},
+	})
+
+	first := ts.candidates()
+	second := ts.candidates()
+
+	if len(first) != 2 || len(second) != 2 {
+		t.Fatalf("Expected 2 candidates, got %d and %d", len(first), len(second))
+	}
+	if first[0].Addr != "10.0.0.1" || second[0].Addr != "10.0.0.2" {
+		t.Errorf("Expected candidates to rotate, got %v then %v", first, second)
+	}
+}
+
+func TestTrackerSet_SkipsUnhealthy(t *testing.T) {
+	ts := newTrackerSet([]models.TrackerEndpoint{
+		{Addr: "10.0.0.1", Port: 22122},
+		{Addr: "10.0.0.2", Port: 22122},
+	})
+
+	ts.markFailure("10.0.0.1", 22122, errors.New("connection refused"))
+
+	for i := 0; i < 2; i++ {
+		candidates := ts.candidates()
+		if len(candidates) != 1 || candidates[0].Addr != "10.0.0.2" {
+			t.Errorf("Expected only the healthy tracker, got %v", candidates)
+		}
+	}
+
+	// 超过重试间隔后不健康的tracker重新参与选择，排在健康tracker之后
+	ts.trackers[0].LastFailure = time.Now().Add(-trackerRetryInterval)
+	candidates := ts.candidates()
+	if len(candidates) != 2 || candidates[1].Addr != "10.0.0.1" {
+		t.Errorf("Expected unhealthy tracker to be retried last, got %v", candidates)
+	}
+
+	ts.markSuccess("10.0.0.1", 22122)
+	stats := ts.stats()
+	if !stats[0].Healthy || stats[0].ConsecutiveFailures != 0 || stats[0].TotalFailures != 1 {
+		t.Errorf("Unexpected tracker stats after recovery: %+v", stats[0])
+	}
+}
+
+func TestTrackerSet_AllUnhealthy(t *testing.T) {
+	ts := newTrackerSet([]models.TrackerEndpoint{
+		{Addr: "10.0.0.1", Port: 22122},
+		{Addr: "10.0.0.2", Port: 22122},
+	})
+
+	ts.markFailure("10.0.0.1", 22122, errors.New("connection refused"))
+	ts.markFailure("10.0.0.2", 22122, errors.New("connection refused"))
+
+	if candidates := ts.candidates(); len(candidates) != 2 {
+		t.Errorf("Expected all trackers when none is healthy, got %v", candidates)
+	}
+}
+
+func TestMultiTrackerConnectionPool_Failover(t *testing.T) {
+	// 关闭监听后该端口会拒绝连接
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	deadPort := dead.Addr().(*net.TCPAddr).Port
+	dead.Close()
+
+	live, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer live.Close()
+	livePort := live.Addr().(*net.TCPAddr).Port
+
+	pool := NewMultiTrackerConnectionPool([]models.TrackerEndpoint{
+		{Addr: "127.0.0.1", Port: deadPort},
+		{Addr: "127.0.0.1", Port: livePort},
+	}, 0)
+	defer pool.Close()
+
+	client, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Expected failover to the live tracker, got %v", err)
+	}
+	defer client.Close()
+
+	if client.trackerPort != livePort {
+		t.Errorf("Expected connection to port %d, got %d", livePort, client.trackerPort)
+	}
+
+	stats := pool.TrackerStats()
+	if stats[0].Healthy || stats[0].ConsecutiveFailures != 1 || stats[0].LastError == "" {
+		t.Errorf("Expected dead tracker to be unhealthy, got %+v", stats[0])
+	}
+	if !stats[1].Healthy {
+		t.Errorf("Expected live tracker to be healthy, got %+v", stats[1])
+	}
+}
+
+// serveTrackerCommands 依次读取连接上的请求并交给handle响应，handle返回false时关闭连接
+func serveTrackerCommands(conn net.Conn, handle func(command byte) bool) {
+	for {
+		buf := make([]byte, FDFS_PROTO_PKG_LEN_SIZE+2)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		length := int64(binary.BigEndian.Uint64(buf[0:8]))
+		if _, err := io.CopyN(io.Discard, conn, length); err != nil {
+			return
+		}
+		if !handle(buf[8]) {
+			return
+		}
+	}
+}
+
+// serveFailingTracker 响应ACTIVE_TEST，收到其他请求时直接关闭连接，模拟能接受连接但无法正常服务的tracker
+func serveFailingTracker(conn net.Conn) {
+	serveTrackerCommands(conn, func(command byte) bool {
+		if command != FDFS_PROTO_CMD_ACTIVE_TEST {
+			return false
+		}
+		writeTestHeader(conn, 0, 0)
+		return true
+	})
+}
+
+// serveFetchTracker 对所有QUERY_FETCH_ONE请求返回同一个存储服务器，其他请求返回错误状态
+func serveFetchTracker(conn net.Conn) {
+	serveTrackerCommands(conn, func(command byte) bool {
+		respondFetch(conn, command)
+		return true
+	})
+}
+
+// respondFetch 按serveFetchTracker的规则响应一个请求
+func respondFetch(conn net.Conn, command byte) {
+	if command != TRACKER_PROTO_CMD_SERVICE_QUERY_FETCH_ONE {
+		writeTestHeader(conn, 0, statusEINVAL)
+		return
+	}
+	resp := buildFetchResponse("group1", 23000, "10.0.0.5")
+	writeTestHeader(conn, int64(len(resp)), 0)
+	conn.Write(resp)
+}
+
+func TestMultiTrackerConnectionPool_FailingTracker(t *testing.T) {
+	_, failingPort := startTrackerWith(t, serveFailingTracker)
+	_, livePort := startTrackerWith(t, serveFetchTracker)
+
+	pool := NewMultiTrackerConnectionPool([]models.TrackerEndpoint{
+		{Addr: "127.0.0.1", Port: failingPort},
+		{Addr: "127.0.0.1", Port: livePort},
+	}, 0)
+	defer pool.Close()
+	client := NewPooledClient(pool)
+
+	// 第一次查询轮询到出错的tracker，失败后在正常的tracker上重试
+	server, err := client.QueryFetchStorage("group1", "M00/00/00/a.jpg")
+	if err != nil {
+		t.Fatalf("Expected query to be retried on the live tracker, got %v", err)
+	}
+	if server.IPAddr != "10.0.0.5" || server.Port != 23000 {
+		t.Errorf("Unexpected storage server: %+v", server)
+	}
+
+	stats := pool.TrackerStats()
+	if stats[0].Healthy || stats[0].ConsecutiveFailures != 1 || stats[0].LastError == "" {
+		t.Errorf("Expected failing tracker to be unhealthy, got %+v", stats[0])
+	}
+	if !stats[1].Healthy {
+		t.Errorf("Expected live tracker to be healthy, got %+v", stats[1])
+	}
+
+	// 之后的查询跳过不健康的tracker
+	for i := 0; i < 3; i++ {
+		if _, err := client.QueryFetchStorage("group1", "M00/00/00/a.jpg"); err != nil {
+			t.Fatalf("Unexpected query error: %v", err)
+		}
+	}
+	if stats := pool.TrackerStats(); stats[0].TotalFailures != 1 {
+		t.Errorf("Expected the unhealthy tracker to be skipped, got %+v", stats[0])
+	}
+
+	// tracker返回的错误状态码是正常的响应，不计为tracker失败
+	if _, err := client.ListGroups(); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Expected ErrInvalidArgument, got %v", err)
+	}
+	if stats := pool.TrackerStats(); !stats[1].Healthy {
+		t.Errorf("Expected error status to keep the tracker healthy, got %+v", stats[1])
+	}
+}
+
+func TestPooledClient_DataOperationFailover(t *testing.T) {
+	_, failingPort := startTrackerWith(t, serveFailingTracker)
+	_, livePort := startTrackerWith(t, serveFetchTracker)
+
+	pool := NewMultiTrackerConnectionPool([]models.TrackerEndpoint{
+		{Addr: "127.0.0.1", Port: failingPort},
+		{Addr: "127.0.0.1", Port: livePort},
+	}, 0)
+	defer pool.Close()
+	client := NewPooledClient(pool)
+
+	// 查询存储服务器时tracker失败，删除尚未执行，在下一个tracker上重试
+	// 正常的tracker对QUERY_UPDATE返回错误状态，说明请求到达了第二个tracker
+	if err := client.DeleteFile("group1/M00/00/00/a.jpg"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("Expected delete to be retried on the live tracker, got %v", err)
+	}
+	if stats := pool.TrackerStats(); stats[0].Healthy || stats[0].TotalFailures != 1 {
+		t.Errorf("Expected failing tracker to be unhealthy, got %+v", stats[0])
+	}
+}
+
+func TestPooledClient_StorageFailureKeepsTrackerConnection(t *testing.T) {
+	// 存储服务器地址上没有监听，连接存储服务器会失败
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	storagePort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	_, trackerPort := startTrackerWith(t, func(conn net.Conn) {
+		serveTrackerCommands(conn, func(command byte) bool {
+			resp := buildFetchResponse("group1", storagePort, "127.0.0.1")
+			writeTestHeader(conn, int64(len(resp)), 0)
+			conn.Write(resp)
+			return true
+		})
+	})
+
+	pool := NewMultiTrackerConnectionPool([]models.TrackerEndpoint{{Addr: "127.0.0.1", Port: trackerPort}}, 0)
+	defer pool.Close()
+	client := NewPooledClient(pool)
+
+	if err := client.DeleteFile("group1/M00/00/00/a.jpg"); !IsRetryable(err) {
+		t.Fatalf("Expected storage network error, got %v", err)
+	}
+
+	stats := pool.Stats()
+	if stats.Idle != 1 || stats.Misses != 1 {
+		t.Errorf("Expected tracker connection to be returned to the pool, got %+v", stats)
+	}
+	if trackers := pool.TrackerStats(); !trackers[0].Healthy {
+		t.Errorf("Expected tracker to stay healthy, got %+v", trackers[0])
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -9,17 +11,50 @@ import (
 
 // Cluster FastDFS集群配置模型
 type Cluster struct {
-	ID          string    `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"not null" json:"name"`
-	Version     string    `gorm:"not null" json:"version"`
-	TrackerAddr string    `gorm:"not null" json:"tracker_addr"`
-	TrackerPort int       `gorm:"not null" json:"tracker_port"`
-	Username    string    `json:"username,omitempty"`
-	Password    string    `json:"password,omitempty"`
-	Status      string    `gorm:"default:'active'" json:"status"`
-	Description string    `gorm:"type:text" json:"description,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string           `gorm:"primaryKey" json:"id"`
+	Name        string           `gorm:"not null" json:"name"`
+	Version     string           `gorm:"not null" json:"version"`
+	TrackerAddr string           `gorm:"not null" json:"tracker_addr"`
+	TrackerPort int              `gorm:"not null" json:"tracker_port"`
+	Trackers    TrackerEndpoints `gorm:"type:json" json:"trackers,omitempty"` // 除主tracker外的其他tracker
+	Username    string           `json:"username,omitempty"`
+	Password    string           `json:"password,omitempty"`
+	Status      string           `gorm:"default:'active'" json:"status"`
+	Description string           `gorm:"type:text" json:"description,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// TrackerEndpoint tracker服务器地址
+type TrackerEndpoint struct {
+	Addr string `json:"addr"`
+	Port int    `json:"port"`
+}
+
+// String 获取tracker地址字符串
+func (e TrackerEndpoint) String() string {
+	return fmt.Sprintf("%s:%d", e.Addr, e.Port)
+}
+
+// TrackerEndpoints tracker地址列表类型
+type TrackerEndpoints []TrackerEndpoint
+
+// 实现GORM的Valuer和Scanner接口，用于JSON字段的序列化
+func (te TrackerEndpoints) Value() (driver.Value, error) {
+	return json.Marshal(te)
+}
+
+func (te *TrackerEndpoints) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	
+	return json.Unmarshal(bytes, te)
 }
 
 // BeforeCreate GORM钩子，创建前生成ID
@@ -42,6 +77,21 @@ func (c *Cluster) GetConnectionString() string {
 	return fmt.Sprintf("%s:%d", c.TrackerAddr, c.TrackerPort)
 }
 
+// GetTrackerEndpoints 获取集群的所有tracker地址，主tracker排在第一位，重复的地址只保留一个
+func (c *Cluster) GetTrackerEndpoints() []TrackerEndpoint {
+	endpoints := []TrackerEndpoint{{Addr: c.TrackerAddr, Port: c.TrackerPort}}
+	seen := map[TrackerEndpoint]bool{endpoints[0]: true}
+	
+	for _, endpoint := range c.Trackers {
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	
+	return endpoints
+}
+
 // IsActive 检查集群是否处于活跃状态
 func (c *Cluster) IsActive() bool {
 	return c.Status == ClusterStatusActive
@@ -58,6 +108,14 @@ func (c *Cluster) Validate() error {
 	if c.TrackerPort <= 0 || c.TrackerPort > 65535 {
 		return fmt.Errorf("tracker port must be between 1 and 65535")
 	}
+	for _, endpoint := range c.Trackers {
+		if endpoint.Addr == "" {
+			return fmt.Errorf("tracker address is required")
+		}
+		if endpoint.Port <= 0 || endpoint.Port > 65535 {
+			return fmt.Errorf("tracker port of %s must be between 1 and 65535", endpoint.Addr)
+		}
+	}
 	if c.Version == "" {
 		return fmt.Errorf("cluster version is required")
 	}
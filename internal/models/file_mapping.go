@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FileMapping 文件ID映射模型，记录迁移后源文件ID对应的目标文件ID
+// 上传到目标集群会生成新的文件ID，应用通过映射转换其保存的旧文件ID
+type FileMapping struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	MigrationID  string    `gorm:"uniqueIndex:idx_file_mappings_migration_source;not null" json:"migration_id"`
+	SourceFileID string    `gorm:"uniqueIndex:idx_file_mappings_migration_source;index;not null" json:"source_file_id"`
+	TargetFileID string    `gorm:"index;not null" json:"target_file_id"`
+	FileSize     int64     `json:"file_size"`
+	CRC32        uint32    `json:"crc32"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BeforeCreate GORM钩子，创建前生成ID
+func (fm *FileMapping) BeforeCreate(tx *gorm.DB) error {
+	if fm.ID == "" {
+		fm.ID = generateID()
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -46,8 +47,37 @@ type MigrationConfig struct {
 	
 	// 验证配置
 	VerificationEnabled bool `json:"verification_enabled"`
+	
+	// 验证时是否额外下载源文件和目标文件比较SHA-256，否则只比较大小和CRC32
+	FullVerification bool `json:"full_verification"`
+	
+	// 是否同时迁移文件元数据
+	PreserveMetadata bool `json:"preserve_metadata"`
+	
+	// 是否将master文件及其slave文件作为一组迁移，保留slave文件的命名关系
+	MasterSlaveMode bool `json:"master_slave_mode"`
+	
+	// 待迁移文件的枚举来源
+	FileSource *FileSource `json:"file_source,omitempty"`
 }
 
+// FileSource 待迁移文件的枚举来源
+type FileSource struct {
+	Type           string   `json:"type"`                       // file_ids、binlog或data_dir
+	FileIDs        []string `json:"file_ids,omitempty"`         // file_ids：显式指定的文件ID列表
+	GroupName      string   `json:"group_name,omitempty"`       // binlog、data_dir：存储服务器所属的组名
+	Path           string   `json:"path,omitempty"`             // binlog为存储服务器的data/sync目录，data_dir为存储路径
+	StorePathIndex int      `json:"store_path_index,omitempty"` // data_dir：存储路径索引
+	PositionFile   string   `json:"position_file,omitempty"`    // binlog：持久化读取位置的文件
+}
+
+// FileSourceType 文件枚举来源类型常量
+const (
+	FileSourceFileIDs = "file_ids"
+	FileSourceBinlog  = "binlog"
+	FileSourceDataDir = "data_dir"
+)
+
 // TimeFilter 时间过滤器
 type TimeFilter struct {
 	StartTime *time.Time `json:"start_time,omitempty"`
@@ -70,7 +100,7 @@ type RetryConfig struct {
 }
 
 // 实现GORM的Valuer和Scanner接口，用于JSON字段的序列化
-func (mc MigrationConfig) Value() (interface{}, error) {
+func (mc MigrationConfig) Value() (driver.Value, error) {
 	return json.Marshal(mc)
 }
 
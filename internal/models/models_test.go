@@ -204,6 +204,44 @@ func TestCluster_GetConnectionString(t *testing.T) {
 	}
 }
 
+func TestCluster_GetTrackerEndpoints(t *testing.T) {
+	cluster := &Cluster{
+		TrackerAddr: "192.168.1.100",
+		TrackerPort: 22122,
+		Trackers: TrackerEndpoints{
+			{Addr: "192.168.1.101", Port: 22122},
+			{Addr: "192.168.1.100", Port: 22122},
+		},
+	}
+
+	endpoints := cluster.GetTrackerEndpoints()
+	if len(endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d", len(endpoints))
+	}
+
+	if endpoints[0].String() != "192.168.1.100:22122" || endpoints[1].String() != "192.168.1.101:22122" {
+		t.Errorf("Unexpected endpoints: %v", endpoints)
+	}
+}
+
+func TestTrackerEndpoints_ValueScan(t *testing.T) {
+	trackers := TrackerEndpoints{{Addr: "192.168.1.101", Port: 22122}}
+
+	data, err := trackers.Value()
+	if err != nil {
+		t.Fatalf("Failed to serialize trackers: %v", err)
+	}
+
+	var scanned TrackerEndpoints
+	if err := scanned.Scan(data); err != nil {
+		t.Fatalf("Failed to deserialize trackers: %v", err)
+	}
+
+	if len(scanned) != 1 || scanned[0] != trackers[0] {
+		t.Errorf("Expected %v, got %v", trackers, scanned)
+	}
+}
+
 func TestCluster_Validate(t *testing.T) {
 	tests := []struct {
 		cluster   *Cluster
@@ -243,6 +281,17 @@ func TestCluster_Validate(t *testing.T) {
 			shouldErr: true,
 			errMsg:    "tracker port must be between 1 and 65535",
 		},
+		{
+			cluster: &Cluster{
+				Name:        "Test",
+				TrackerAddr: "192.168.1.100",
+				TrackerPort: 22122,
+				Trackers:    TrackerEndpoints{{Addr: "192.168.1.101", Port: 0}},
+				Version:     "5.0.7",
+			},
+			shouldErr: true,
+			errMsg:    "tracker port of 192.168.1.101 must be between 1 and 65535",
+		},
 	}
 
 	for i, test := range tests {
@@ -631,6 +680,11 @@ func TestTransferState_AdditionalMethods(t *testing.T) {
 		t.Errorf("Expected next chunk index 2, got %d", nextChunk.Index)
 	}
 
+	// 测试GetResumeOffset
+	if offset := state.GetResumeOffset(); offset != nextChunk.Offset {
+		t.Errorf("Expected resume offset %d, got %d", nextChunk.Offset, offset)
+	}
+
 	// 测试UpdateChunkState
 	state.UpdateChunkState(2, true, "checksum123")
 	if !state.ChunkStates[2].Completed {
@@ -657,4 +711,27 @@ func TestTransferState_AdditionalMethods(t *testing.T) {
 	if eta <= 0 {
 		t.Error("ETA should be positive")
 	}
-}
\ No newline at end of file
+}
+func TestTransferState_ResetChunks(t *testing.T) {
+	state := &TransferState{TransferredSize: 100}
+	state.ResetChunks(2500, 1000)
+
+	if state.GetTotalChunks() != 3 || state.TransferredSize != 0 {
+		t.Fatalf("Expected 3 chunks and no progress, got %d chunks, %d bytes", state.GetTotalChunks(), state.TransferredSize)
+	}
+	if last := state.ChunkStates[2]; last.Offset != 2000 || last.Size != 500 {
+		t.Errorf("Unexpected last chunk: %+v", last)
+	}
+
+	if !state.MatchesChunks(2500, 1000) {
+		t.Error("Expected chunks to match the plan they were built from")
+	}
+	if state.MatchesChunks(3000, 1000) || state.MatchesChunks(2500, 500) {
+		t.Error("Expected chunks not to match a different size or chunk size")
+	}
+
+	state.ResetChunks(0, 1000)
+	if state.GetTotalChunks() != 0 {
+		t.Errorf("Expected no chunks for an empty file, got %d", state.GetTotalChunks())
+	}
+}
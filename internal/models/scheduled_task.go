@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -32,7 +33,7 @@ func (st *ScheduledTask) BeforeCreate(tx *gorm.DB) error {
 }
 
 // TaskConfig 实现GORM的Valuer和Scanner接口，用于JSON字段的序列化
-func (tc TaskConfig) Value() (interface{}, error) {
+func (tc TaskConfig) Value() (driver.Value, error) {
 	return json.Marshal(tc)
 }
 
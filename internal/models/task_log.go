@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"time"
 
@@ -30,7 +31,7 @@ func (tl *TaskLog) BeforeCreate(tx *gorm.DB) error {
 type LogDetails map[string]interface{}
 
 // 实现GORM的Valuer和Scanner接口，用于JSON字段的序列化
-func (ld LogDetails) Value() (interface{}, error) {
+func (ld LogDetails) Value() (driver.Value, error) {
 	return json.Marshal(ld)
 }
 
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -20,6 +21,7 @@ type TransferState struct {
 	ChunkStates      ChunkStates `gorm:"type:json" json:"chunk_states"`
 	Status           string       `gorm:"default:'pending'" json:"status"`
 	Checksum         string       `json:"checksum,omitempty"`
+	TargetFileID     string       `json:"target_file_id,omitempty"` // 分块传输时目标集群中正在组装的文件ID
 	LastUpdate       time.Time    `json:"last_update"`
 	CreatedAt        time.Time    `json:"created_at"`
 }
@@ -51,7 +53,7 @@ func (ts *TransferState) BeforeUpdate(tx *gorm.DB) error {
 type ChunkStates []ChunkState
 
 // 实现GORM的Valuer和Scanner接口，用于JSON字段的序列化
-func (cs ChunkStates) Value() (interface{}, error) {
+func (cs ChunkStates) Value() (driver.Value, error) {
 	return json.Marshal(cs)
 }
 
@@ -70,11 +72,12 @@ func (cs *ChunkStates) Scan(value interface{}) error {
 
 // TransferStatus 传输状态常量
 const (
-	TransferStatusPending    = "pending"
-	TransferStatusRunning    = "running"
-	TransferStatusPaused     = "paused"
-	TransferStatusCompleted  = "completed"
-	TransferStatusFailed     = "failed"
+	TransferStatusPending     = "pending"
+	TransferStatusRunning     = "running"
+	TransferStatusPaused      = "paused"
+	TransferStatusCompleted   = "completed"
+	TransferStatusFailed      = "failed"
+	TransferStatusQuarantined = "quarantined" // 多次校验不一致，目标副本保留待人工检查
 )
 
 // GetProgress 计算传输进度百分比
@@ -116,6 +119,11 @@ func (ts *TransferState) IsFailed() bool {
 	return ts.Status == TransferStatusFailed
 }
 
+// IsQuarantined 检查文件是否因校验不一致被隔离
+func (ts *TransferState) IsQuarantined() bool {
+	return ts.Status == TransferStatusQuarantined
+}
+
 // IsRunning 检查传输是否正在进行
 func (ts *TransferState) IsRunning() bool {
 	return ts.Status == TransferStatusRunning
@@ -136,6 +144,47 @@ func (ts *TransferState) GetNextIncompleteChunk() *ChunkState {
 	return nil
 }
 
+// GetResumeOffset 获取断点续传的起始偏移量，即第一个未完成分块的偏移量
+// 所有分块都已完成时返回文件总大小
+func (ts *TransferState) GetResumeOffset() int64 {
+	if chunk := ts.GetNextIncompleteChunk(); chunk != nil {
+		return chunk.Offset
+	}
+	return ts.TotalSize
+}
+
+// ResetChunks 按chunkSize将totalSize字节重新划分为分块，并清空已传输的进度
+func (ts *TransferState) ResetChunks(totalSize int64, chunkSize int64) {
+	ts.TotalSize = totalSize
+	ts.ChunkSize = chunkSize
+	ts.TransferredSize = 0
+	ts.ChunkStates = nil
+	
+	if chunkSize <= 0 {
+		chunkSize = totalSize
+	}
+	for offset := int64(0); offset < totalSize; offset += chunkSize {
+		size := chunkSize
+		if offset+size > totalSize {
+			size = totalSize - offset
+		}
+		ts.ChunkStates = append(ts.ChunkStates, ChunkState{
+			Index:  len(ts.ChunkStates),
+			Offset: offset,
+			Size:   size,
+		})
+	}
+}
+
+// MatchesChunks 检查已有的分块划分是否与totalSize和chunkSize一致，一致时可以从中断处继续
+func (ts *TransferState) MatchesChunks(totalSize int64, chunkSize int64) bool {
+	if ts.TotalSize != totalSize || ts.ChunkSize != chunkSize || len(ts.ChunkStates) == 0 {
+		return false
+	}
+	last := ts.ChunkStates[len(ts.ChunkStates)-1]
+	return last.Offset+last.Size == totalSize
+}
+
 // UpdateChunkState 更新指定分块的状态
 func (ts *TransferState) UpdateChunkState(index int, completed bool, checksum string) {
 	if index >= 0 && index < len(ts.ChunkStates) {
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"fastdfs-migration-system/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fileMappingLookupBatch 批量查询时每条SQL携带的文件ID数，避免超出SQLite的参数数量限制
+const fileMappingLookupBatch = 500
+
+// fileMappingRepository 文件ID映射仓库实现
+type fileMappingRepository struct {
+	db *gorm.DB
+}
+
+// NewFileMappingRepository 创建文件ID映射仓库
+func NewFileMappingRepository(db *gorm.DB) FileMappingRepository {
+	return &fileMappingRepository{db: db}
+}
+
+// Save 保存文件ID映射，同一迁移任务中源文件ID已有映射时覆盖原映射
+func (r *fileMappingRepository) Save(mapping *models.FileMapping) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "migration_id"}, {Name: "source_file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"target_file_id", "file_size", "crc32", "created_at"}),
+	}).Create(mapping).Error
+}
+
+// Get 获取迁移任务中源文件的映射
+func (r *fileMappingRepository) Get(migrationID string, sourceFileID string) (*models.FileMapping, error) {
+	var mapping models.FileMapping
+	err := r.db.Where("migration_id = ? AND source_file_id = ?", migrationID, sourceFileID).First(&mapping).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// GetBySourceFileID 根据源文件ID获取映射，文件被多次迁移时返回最新的映射
+func (r *fileMappingRepository) GetBySourceFileID(sourceFileID string) (*models.FileMapping, error) {
+	var mapping models.FileMapping
+	err := r.db.Where("source_file_id = ?", sourceFileID).
+		Order("created_at DESC").
+		First(&mapping).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// GetBySourceFileIDs 批量根据源文件ID获取映射，返回源文件ID到最新映射的映射表，没有映射的文件ID不在结果中
+func (r *fileMappingRepository) GetBySourceFileIDs(sourceFileIDs []string) (map[string]*models.FileMapping, error) {
+	return r.lookup("source_file_id", sourceFileIDs, func(mapping *models.FileMapping) string {
+		return mapping.SourceFileID
+	})
+}
+
+// GetByTargetFileID 根据目标文件ID反查映射
+func (r *fileMappingRepository) GetByTargetFileID(targetFileID string) (*models.FileMapping, error) {
+	var mapping models.FileMapping
+	err := r.db.Where("target_file_id = ?", targetFileID).
+		Order("created_at DESC").
+		First(&mapping).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// GetByTargetFileIDs 批量根据目标文件ID反查映射，返回目标文件ID到映射的映射表
+func (r *fileMappingRepository) GetByTargetFileIDs(targetFileIDs []string) (map[string]*models.FileMapping, error) {
+	return r.lookup("target_file_id", targetFileIDs, func(mapping *models.FileMapping) string {
+		return mapping.TargetFileID
+	})
+}
+
+// GetByMigrationID 分页获取迁移任务的映射
+func (r *fileMappingRepository) GetByMigrationID(migrationID string, pagination *models.Pagination) ([]*models.FileMapping, error) {
+	var mappings []*models.FileMapping
+	var total int64
+
+	query := r.db.Model(&models.FileMapping{}).Where("migration_id = ?", migrationID)
+
+	// 计算总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	pagination.Total = total
+
+	// 分页查询
+	err := query.Offset(pagination.GetOffset()).
+		Limit(pagination.GetLimit()).
+		Order("created_at, id").
+		Find(&mappings).Error
+
+	return mappings, err
+}
+
+// ForEachByMigrationID 按批读取迁移任务的全部映射并逐条调用fn，用于导出大量映射
+// fn返回错误时停止遍历并返回该错误
+func (r *fileMappingRepository) ForEachByMigrationID(migrationID string, fn func(mapping *models.FileMapping) error) error {
+	var batch []*models.FileMapping
+	var fnErr error
+
+	err := r.db.Where("migration_id = ?", migrationID).
+		FindInBatches(&batch, fileMappingLookupBatch, func(tx *gorm.DB, _ int) error {
+			for _, mapping := range batch {
+				if fnErr = fn(mapping); fnErr != nil {
+					return fnErr
+				}
+			}
+			return nil
+		}).Error
+
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// DeleteByMigrationID 删除迁移任务的全部映射
+func (r *fileMappingRepository) DeleteByMigrationID(migrationID string) error {
+	return r.db.Where("migration_id = ?", migrationID).Delete(&models.FileMapping{}).Error
+}
+
+// lookup 按column分批查询fileIDs的映射，同一文件ID有多条映射时保留最新的
+func (r *fileMappingRepository) lookup(column string, fileIDs []string, key func(mapping *models.FileMapping) string) (map[string]*models.FileMapping, error) {
+	results := make(map[string]*models.FileMapping, len(fileIDs))
+
+	for start := 0; start < len(fileIDs); start += fileMappingLookupBatch {
+		end := start + fileMappingLookupBatch
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+
+		var mappings []*models.FileMapping
+		err := r.db.Where(column+" IN ?", fileIDs[start:end]).
+			Order("created_at").
+			Find(&mappings).Error
+		if err != nil {
+			return nil, err
+		}
+
+		// 按创建时间升序遍历，后写入的映射覆盖先写入的
+		for _, mapping := range mappings {
+			results[key(mapping)] = mapping
+		}
+	}
+
+	return results, nil
+}
@@ -63,6 +63,19 @@ type TransferStateRepository interface {
 	UpdateProgress(id string, transferredSize int64, chunkStates []models.ChunkState) error
 }
 
+// FileMappingRepository 文件ID映射仓库接口
+type FileMappingRepository interface {
+	Save(mapping *models.FileMapping) error
+	Get(migrationID string, sourceFileID string) (*models.FileMapping, error)
+	GetBySourceFileID(sourceFileID string) (*models.FileMapping, error)
+	GetBySourceFileIDs(sourceFileIDs []string) (map[string]*models.FileMapping, error)
+	GetByTargetFileID(targetFileID string) (*models.FileMapping, error)
+	GetByTargetFileIDs(targetFileIDs []string) (map[string]*models.FileMapping, error)
+	GetByMigrationID(migrationID string, pagination *models.Pagination) ([]*models.FileMapping, error)
+	ForEachByMigrationID(migrationID string, fn func(mapping *models.FileMapping) error) error
+	DeleteByMigrationID(migrationID string) error
+}
+
 // Repository 仓库集合接口
 type Repository interface {
 	Migration() MigrationRepository
@@ -70,4 +83,5 @@ type Repository interface {
 	TaskLog() TaskLogRepository
 	ScheduledTask() ScheduledTaskRepository
 	TransferState() TransferStateRepository
+	FileMapping() FileMappingRepository
 }
\ No newline at end of file
@@ -12,6 +12,7 @@ type repository struct {
 	taskLogRepo         TaskLogRepository
 	scheduledTaskRepo   ScheduledTaskRepository
 	transferStateRepo   TransferStateRepository
+	fileMappingRepo     FileMappingRepository
 }
 
 // NewRepository 创建仓库集合
@@ -23,6 +24,7 @@ func NewRepository(db *gorm.DB) Repository {
 		taskLogRepo:       NewTaskLogRepository(db),
 		scheduledTaskRepo: NewScheduledTaskRepository(db),
 		transferStateRepo: NewTransferStateRepository(db),
+		fileMappingRepo:   NewFileMappingRepository(db),
 	}
 }
 
@@ -49,4 +51,9 @@ func (r *repository) ScheduledTask() ScheduledTaskRepository {
 // TransferState 获取传输状态仓库
 func (r *repository) TransferState() TransferStateRepository {
 	return r.transferStateRepo
+}
+
+// FileMapping 获取文件ID映射仓库
+func (r *repository) FileMapping() FileMappingRepository {
+	return r.fileMappingRepo
 }
\ No newline at end of file
@@ -18,6 +18,7 @@ func TestRepositoryInterfaces(t *testing.T) {
 		_ = repo.TaskLog()
 		_ = repo.ScheduledTask()
 		_ = repo.TransferState()
+		_ = repo.FileMapping()
 	}
 }
 
@@ -113,4 +114,24 @@ func TestTransferStateRepositoryInterface(t *testing.T) {
 		_, _ = repo.GetByStatus("pending")
 		_ = repo.UpdateProgress("test-id", 1024, chunkStates)
 	}
+}
+
+func TestFileMappingRepositoryInterface(t *testing.T) {
+	// 验证FileMappingRepository接口的完整性
+	var repo FileMappingRepository
+	
+	if repo != nil {
+		mapping := &models.FileMapping{}
+		pagination := &models.Pagination{}
+		
+		_ = repo.Save(mapping)
+		_, _ = repo.Get("migration-id", "source-id")
+		_, _ = repo.GetBySourceFileID("source-id")
+		_, _ = repo.GetBySourceFileIDs([]string{"source-id"})
+		_, _ = repo.GetByTargetFileID("target-id")
+		_, _ = repo.GetByTargetFileIDs([]string{"target-id"})
+		_, _ = repo.GetByMigrationID("migration-id", pagination)
+		_ = repo.ForEachByMigrationID("migration-id", func(*models.FileMapping) error { return nil })
+		_ = repo.DeleteByMigrationID("migration-id")
+	}
 }
\ No newline at end of file
@@ -1,9 +1,9 @@
 package repository
 
 import (
+	"fmt"
 	"os"
 	"testing"
-	"time"
 
 	"fastdfs-migration-system/internal/database"
 	"fastdfs-migration-system/internal/models"
@@ -534,6 +534,93 @@ func TestTransferStateRepository_CRUD(t *testing.T) {
 	}
 }
 
+func TestFileMappingRepository_CRUD(t *testing.T) {
+	repo := testRepo.FileMapping()
+
+	// 测试保存
+	for i, sourceFileID := range []string{"group1/M00/00/00/a.jpg", "group1/M00/00/00/b.jpg"} {
+		mapping := &models.FileMapping{
+			MigrationID:  "mapping-migration",
+			SourceFileID: sourceFileID,
+			TargetFileID: fmt.Sprintf("group2/M00/00/00/%d.jpg", i),
+			FileSize:     int64(1024 * (i + 1)),
+			CRC32:        uint32(i + 1),
+		}
+		if err := repo.Save(mapping); err != nil {
+			t.Fatalf("Failed to save file mapping: %v", err)
+		}
+	}
+
+	// 同一迁移任务中再次保存同一源文件时覆盖原映射
+	err := repo.Save(&models.FileMapping{
+		MigrationID:  "mapping-migration",
+		SourceFileID: "group1/M00/00/00/b.jpg",
+		TargetFileID: "group2/M00/00/00/b2.jpg",
+		FileSize:     2048,
+	})
+	if err != nil {
+		t.Fatalf("Failed to overwrite file mapping: %v", err)
+	}
+
+	found, err := repo.Get("mapping-migration", "group1/M00/00/00/b.jpg")
+	if err != nil {
+		t.Fatalf("Failed to get file mapping: %v", err)
+	}
+	if found.TargetFileID != "group2/M00/00/00/b2.jpg" {
+		t.Errorf("Expected overwritten target file ID, got %s", found.TargetFileID)
+	}
+
+	// 测试批量查询
+	mappings, err := repo.GetBySourceFileIDs([]string{"group1/M00/00/00/a.jpg", "group1/M00/00/00/b.jpg", "group1/M00/00/00/c.jpg"})
+	if err != nil {
+		t.Fatalf("Failed to look up file mappings: %v", err)
+	}
+	if len(mappings) != 2 || mappings["group1/M00/00/00/a.jpg"].TargetFileID != "group2/M00/00/00/0.jpg" {
+		t.Errorf("Unexpected bulk lookup result: %v", mappings)
+	}
+
+	// 测试反查
+	reverse, err := repo.GetByTargetFileID("group2/M00/00/00/b2.jpg")
+	if err != nil {
+		t.Fatalf("Failed to reverse look up file mapping: %v", err)
+	}
+	if reverse.SourceFileID != "group1/M00/00/00/b.jpg" {
+		t.Errorf("Expected source file ID group1/M00/00/00/b.jpg, got %s", reverse.SourceFileID)
+	}
+
+	reverseMappings, err := repo.GetByTargetFileIDs([]string{"group2/M00/00/00/0.jpg", "group2/M00/00/00/1.jpg"})
+	if err != nil {
+		t.Fatalf("Failed to reverse look up file mappings: %v", err)
+	}
+	if len(reverseMappings) != 1 {
+		t.Errorf("Expected replaced target file ID not to be found, got %v", reverseMappings)
+	}
+
+	// 测试遍历和分页查询
+	var count int
+	err = repo.ForEachByMigrationID("mapping-migration", func(mapping *models.FileMapping) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Errorf("Expected to visit 2 mappings, got %d (%v)", count, err)
+	}
+
+	pagination := &models.Pagination{Page: 1, PageSize: 1}
+	page, err := repo.GetByMigrationID("mapping-migration", pagination)
+	if err != nil || len(page) != 1 || pagination.Total != 2 {
+		t.Errorf("Unexpected page: %d mappings, total %d (%v)", len(page), pagination.Total, err)
+	}
+
+	// 测试删除
+	if err := repo.DeleteByMigrationID("mapping-migration"); err != nil {
+		t.Fatalf("Failed to delete file mappings: %v", err)
+	}
+	if _, err := repo.GetBySourceFileID("group1/M00/00/00/a.jpg"); err == nil {
+		t.Error("Expected file mapping to be deleted")
+	}
+}
+
 func TestRepository_Integration(t *testing.T) {
 	// 测试仓库集合的完整性
 	if testRepo.Migration() == nil {
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"strings"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/models"
+)
+
+// chunkCheckpointFunc 持久化分块传输状态，每个分块完成后调用
+type chunkCheckpointFunc func(state *models.TransferState) error
+
+// transferChunked 按state中的分块将文件复制到目标集群，返回目标文件ID
+// 第一个分块以appender文件上传，之后的分块依次追加；每个分块完成后调用checkpoint记录分块的CRC32和目标文件ID，
+// 中断后以同一个state再次调用时从第一个未完成的分块继续。
+// 源文件不是appender文件时，组装完成后重新生成普通文件名；目标集群不支持时目标文件保留为appender文件。
+// 只复制文件内容，元数据由调用方复制
+func (s *FastDFSService) transferChunked(ctx context.Context, source, target *fastdfs.PooledClient, fileInfo *fastdfs.FileInfo, state *models.TransferState, checkpoint chunkCheckpointFunc) (string, error) {
+	fileID := fileInfo.GetFileID()
+
+	if err := s.resumeChunks(ctx, target, state); err != nil {
+		return "", err
+	}
+
+	extName := strings.TrimPrefix(path.Ext(fileInfo.FileName), ".")
+	for chunk := state.GetNextIncompleteChunk(); chunk != nil; chunk = state.GetNextIncompleteChunk() {
+		var buf bytes.Buffer
+		buf.Grow(int(chunk.Size))
+		if _, err := source.DownloadRangeTo(ctx, fileID, chunk.Offset, chunk.Size, &buf); err != nil {
+			return "", fmt.Errorf("failed to download chunk %d of %s: %w", chunk.Index, fileID, err)
+		}
+		if int64(buf.Len()) != chunk.Size {
+			return "", fmt.Errorf("failed to download chunk %d of %s: got %d of %d bytes", chunk.Index, fileID, buf.Len(), chunk.Size)
+		}
+		checksum := fmt.Sprintf("%08x", crc32.ChecksumIEEE(buf.Bytes()))
+
+		if state.TargetFileID == "" {
+			targetFileID, err := target.UploadAppenderFrom(ctx, fileInfo.GroupName, extName, chunk.Size, &buf)
+			if err != nil {
+				return "", fmt.Errorf("failed to upload chunk %d of %s: %w", chunk.Index, fileID, err)
+			}
+			state.TargetFileID = targetFileID
+		} else if err := target.AppendFrom(ctx, state.TargetFileID, chunk.Size, &buf); err != nil {
+			return "", fmt.Errorf("failed to append chunk %d of %s: %w", chunk.Index, fileID, err)
+		}
+
+		state.UpdateChunkState(chunk.Index, true, checksum)
+		state.TransferredSize = chunk.Offset + chunk.Size
+		if err := checkpoint(state); err != nil {
+			return "", fmt.Errorf("failed to save checkpoint of chunk %d of %s: %w", chunk.Index, fileID, err)
+		}
+	}
+
+	// 之前的调用已重新生成文件名时目标文件不再是appender文件，不需要再次生成
+	targetFileID := state.TargetFileID
+	if !fastdfs.IsAppenderFile(fileID) && fastdfs.IsAppenderFile(targetFileID) {
+		regenerated, err := target.RegenerateAppenderFileNameContext(ctx, targetFileID)
+		switch {
+		case errors.Is(err, fastdfs.ErrUnsupported):
+			s.logger.Warnf("Target cluster cannot convert appender file %s, keeping it as the copy of %s", targetFileID, fileID)
+		case err != nil:
+			return "", fmt.Errorf("failed to regenerate file name of %s: %w", targetFileID, err)
+		default:
+			targetFileID = regenerated
+			state.TargetFileID = regenerated
+		}
+	}
+
+	return targetFileID, nil
+}
+
+// resumeChunks 使目标appender文件与state中已完成的分块一致
+// 追加成功但检查点未保存时目标文件比记录的长，截断到记录的位置；目标文件丢失或比记录的短时从头传输
+func (s *FastDFSService) resumeChunks(ctx context.Context, target *fastdfs.PooledClient, state *models.TransferState) error {
+	if state.TargetFileID == "" {
+		return nil
+	}
+
+	offset := state.GetResumeOffset()
+	targetInfo, err := target.GetFileInfoContext(ctx, state.TargetFileID)
+	if errors.Is(err, fastdfs.ErrFileNotFound) {
+		s.logger.Warnf("Partial target file %s not found, restarting transfer of %s", state.TargetFileID, state.FileID)
+		state.TargetFileID = ""
+		state.ResetChunks(state.TotalSize, state.ChunkSize)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get partial target file info: %w", err)
+	}
+
+	if targetInfo.FileSize < offset {
+		s.logger.Warnf("Partial target file %s has %d bytes, expected %d, restarting transfer of %s", state.TargetFileID, targetInfo.FileSize, offset, state.FileID)
+		state.ResetChunks(state.TotalSize, state.ChunkSize)
+		offset = 0
+	}
+
+	if targetInfo.FileSize > offset {
+		if err := target.TruncateFileContext(ctx, state.TargetFileID, offset); err != nil {
+			return fmt.Errorf("failed to truncate partial target file %s: %w", state.TargetFileID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/fastdfs/fdfstest"
+	"fastdfs-migration-system/internal/models"
+)
+
+// chunkTestData 生成size字节不重复的测试数据，错位拼接的分块不会与原数据相同
+func chunkTestData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7 / 3)
+	}
+	return data
+}
+
+func TestMigrationEngine_ChunkedResume(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+	engine.config.ChunkSize = 1024
+
+	data := chunkTestData(5000)
+	fileID, err := source.PutFile("group1", "bin", data, nil)
+	if err != nil {
+		t.Fatalf("Failed to seed source file: %v", err)
+	}
+
+	// 第3次追加（即第4个分块）时目标集群空间不足
+	scenario, err := fdfstest.ParseScenario("storage append status status=28 nth=3 times=1")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	target.SetScenario(scenario)
+
+	migration := createTestMigration(t, repo, []string{fileID}, models.MigrationConfig{})
+	if err := engine.Run(context.Background(), migration.ID); err == nil {
+		t.Fatal("Expected run to fail")
+	}
+
+	states, err := repo.TransferState().GetByTaskID(migration.ID)
+	if err != nil || len(states) != 1 {
+		t.Fatalf("Expected 1 transfer state, got %d (%v)", len(states), err)
+	}
+	state := states[0]
+	if state.GetTotalChunks() != 5 || state.GetCompletedChunks() != 3 || state.TransferredSize != 3072 || state.TargetFileID == "" {
+		t.Fatalf("Unexpected transfer state after failure: %s, target %q", state.GetProgressString(), state.TargetFileID)
+	}
+	for _, chunk := range state.ChunkStates[:3] {
+		if chunk.Checksum == "" {
+			t.Errorf("Expected checksum for completed chunk %d", chunk.Index)
+		}
+	}
+
+	// 再次执行时只下载剩余的两个分块，用不延迟的delay规则记录下载请求
+	target.SetScenario(nil)
+	source.SetScenario(&fdfstest.Scenario{Rules: []*fdfstest.Rule{
+		{Target: fdfstest.TargetStorage, Command: "download", Action: fdfstest.ActionDelay},
+	}})
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected error on resume: %v", err)
+	}
+	if downloads := source.FaultHistory(); len(downloads) != 2 {
+		t.Errorf("Expected 2 chunk downloads on resume, got %d", len(downloads))
+	}
+
+	mapping, err := repo.FileMapping().Get(migration.ID, fileID)
+	if err != nil {
+		t.Fatalf("Failed to get file mapping: %v", err)
+	}
+	copied, exists := target.File(mapping.TargetFileID)
+	if !exists || !bytes.Equal(copied, data) {
+		t.Fatalf("Expected target %s to match source", mapping.TargetFileID)
+	}
+	if fastdfs.IsAppenderFile(mapping.TargetFileID) {
+		t.Errorf("Expected assembled copy of a normal file to be a normal file, got %s", mapping.TargetFileID)
+	}
+	if files := target.FileIDs("group1"); len(files) != 1 {
+		t.Errorf("Expected no partial copies in target cluster, got %v", files)
+	}
+}
+
+func TestTransferChunked_TruncatesUncheckpointedAppend(t *testing.T) {
+	s, source, target := newTransferTestService(t)
+	sourceClient, targetClient, err := s.getTransferClients("source", "target")
+	if err != nil {
+		t.Fatalf("Failed to get clients: %v", err)
+	}
+
+	data := chunkTestData(3000)
+	fileID, err := source.PutFile("group1", "bin", data, nil)
+	if err != nil {
+		t.Fatalf("Failed to seed source file: %v", err)
+	}
+	fileInfo, err := sourceClient.GetFileInfo(fileID)
+	if err != nil {
+		t.Fatalf("Failed to get source file info: %v", err)
+	}
+
+	// 第二个分块已追加到目标文件，但检查点只记录了第一个分块
+	partialID, err := targetClient.UploadAppenderFile("group1", "partial.bin", data[:2048])
+	if err != nil {
+		t.Fatalf("Failed to seed partial target file: %v", err)
+	}
+	state := &models.TransferState{FileID: fileID, TargetFileID: partialID}
+	state.ResetChunks(fileInfo.FileSize, 1024)
+	state.UpdateChunkState(0, true, "")
+	state.TransferredSize = 1024
+
+	var checkpoints int
+	checkpoint := func(*models.TransferState) error {
+		checkpoints++
+		return nil
+	}
+
+	targetFileID, err := s.transferChunked(context.Background(), sourceClient, targetClient, fileInfo, state, checkpoint)
+	if err != nil {
+		t.Fatalf("Unexpected transfer error: %v", err)
+	}
+	if checkpoints != 2 {
+		t.Errorf("Expected 2 checkpoints, got %d", checkpoints)
+	}
+
+	copied, exists := target.File(targetFileID)
+	if !exists || !bytes.Equal(copied, data) {
+		t.Errorf("Expected target %s to match source, got %d bytes", targetFileID, len(copied))
+	}
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -142,6 +144,18 @@ func (s *FastDFSService) GetClusterClient(clusterID string) (*fastdfs.PooledClie
 	return s.clusterManager.GetClient(clusterID)
 }
 
+// GetClusterTopology 获取集群拓扑，包括所有组的容量、存储服务器数量及各存储服务器的状态
+func (s *FastDFSService) GetClusterTopology(clusterID string) ([]*fastdfs.GroupInfo, error) {
+	groups, err := s.clusterManager.GetTopology(clusterID)
+	if err != nil {
+		s.logger.Errorf("Failed to get topology of cluster %s: %v", clusterID, err)
+		return nil, err
+	}
+	
+	s.logger.Debugf("Got topology of cluster %s: %d groups", clusterID, len(groups))
+	return groups, nil
+}
+
 // ListFiles 列出文件
 func (s *FastDFSService) ListFiles(clusterID string, groupName string, startFileName string, limit int) ([]*fastdfs.FileInfo, error) {
 	client, err := s.clusterManager.GetClient(clusterID)
@@ -161,12 +175,17 @@ func (s *FastDFSService) ListFiles(clusterID string, groupName string, startFile
 
 // DownloadFile 下载文件
 func (s *FastDFSService) DownloadFile(clusterID string, fileID string) ([]byte, error) {
+	return s.DownloadFileContext(context.Background(), clusterID, fileID)
+}
+
+// DownloadFileContext 在ctx的控制下下载文件
+func (s *FastDFSService) DownloadFileContext(ctx context.Context, clusterID string, fileID string) ([]byte, error) {
 	client, err := s.clusterManager.GetClient(clusterID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster client: %w", err)
 	}
 	
-	data, err := client.DownloadFile(fileID)
+	data, err := client.DownloadFileContext(ctx, fileID)
 	if err != nil {
 		s.logger.Errorf("Failed to download file %s from cluster %s: %v", fileID, clusterID, err)
 		return nil, err
@@ -176,14 +195,53 @@ func (s *FastDFSService) DownloadFile(clusterID string, fileID string) ([]byte,
 	return data, nil
 }
 
+// DownloadTo 以流的方式下载文件，避免将整个文件加载到内存
+func (s *FastDFSService) DownloadTo(ctx context.Context, clusterID string, fileID string, w io.Writer) (int64, error) {
+	client, err := s.clusterManager.GetClient(clusterID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cluster client: %w", err)
+	}
+	
+	written, err := client.DownloadTo(ctx, fileID, w)
+	if err != nil {
+		s.logger.Errorf("Failed to download file %s from cluster %s: %v", fileID, clusterID, err)
+		return written, err
+	}
+	
+	s.logger.Debugf("Downloaded file %s from cluster %s, size: %d bytes", fileID, clusterID, written)
+	return written, nil
+}
+
+// DownloadRange 下载文件的指定区间，用于分块传输和断点续传
+func (s *FastDFSService) DownloadRange(clusterID string, fileID string, offset int64, length int64) ([]byte, error) {
+	client, err := s.clusterManager.GetClient(clusterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster client: %w", err)
+	}
+	
+	data, err := client.DownloadRange(fileID, offset, length)
+	if err != nil {
+		s.logger.Errorf("Failed to download range [%d, +%d) of file %s from cluster %s: %v", offset, length, fileID, clusterID, err)
+		return nil, err
+	}
+	
+	s.logger.Debugf("Downloaded range [%d, +%d) of file %s from cluster %s", offset, length, fileID, clusterID)
+	return data, nil
+}
+
 // UploadFile 上传文件
 func (s *FastDFSService) UploadFile(clusterID string, groupName string, fileName string, data []byte) (string, error) {
+	return s.UploadFileContext(context.Background(), clusterID, groupName, fileName, data)
+}
+
+// UploadFileContext 在ctx的控制下上传文件
+func (s *FastDFSService) UploadFileContext(ctx context.Context, clusterID string, groupName string, fileName string, data []byte) (string, error) {
 	client, err := s.clusterManager.GetClient(clusterID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get cluster client: %w", err)
 	}
 	
-	fileID, err := client.UploadFile(groupName, fileName, data)
+	fileID, err := client.UploadFileContext(ctx, groupName, fileName, data)
 	if err != nil {
 		s.logger.Errorf("Failed to upload file %s to cluster %s: %v", fileName, clusterID, err)
 		return "", err
@@ -193,14 +251,36 @@ func (s *FastDFSService) UploadFile(clusterID string, groupName string, fileName
 	return fileID, nil
 }
 
+// UploadFrom 以流的方式上传文件，size为r中待上传的字节数
+func (s *FastDFSService) UploadFrom(ctx context.Context, clusterID string, groupName string, extName string, size int64, r io.Reader) (string, error) {
+	client, err := s.clusterManager.GetClient(clusterID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cluster client: %w", err)
+	}
+	
+	fileID, err := client.UploadFrom(ctx, groupName, extName, size, r)
+	if err != nil {
+		s.logger.Errorf("Failed to upload stream (%d bytes) to cluster %s: %v", size, clusterID, err)
+		return "", err
+	}
+	
+	s.logger.Debugf("Uploaded stream to cluster %s, size: %d bytes, fileID: %s", clusterID, size, fileID)
+	return fileID, nil
+}
+
 // DeleteFile 删除文件
 func (s *FastDFSService) DeleteFile(clusterID string, fileID string) error {
+	return s.DeleteFileContext(context.Background(), clusterID, fileID)
+}
+
+// DeleteFileContext 在ctx的控制下删除文件
+func (s *FastDFSService) DeleteFileContext(ctx context.Context, clusterID string, fileID string) error {
 	client, err := s.clusterManager.GetClient(clusterID)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster client: %w", err)
 	}
 	
-	err = client.DeleteFile(fileID)
+	err = client.DeleteFileContext(ctx, fileID)
 	if err != nil {
 		s.logger.Errorf("Failed to delete file %s from cluster %s: %v", fileID, clusterID, err)
 		return err
@@ -212,12 +292,17 @@ func (s *FastDFSService) DeleteFile(clusterID string, fileID string) error {
 
 // GetFileInfo 获取文件信息
 func (s *FastDFSService) GetFileInfo(clusterID string, fileID string) (*fastdfs.FileInfo, error) {
+	return s.GetFileInfoContext(context.Background(), clusterID, fileID)
+}
+
+// GetFileInfoContext 在ctx的控制下获取文件信息
+func (s *FastDFSService) GetFileInfoContext(ctx context.Context, clusterID string, fileID string) (*fastdfs.FileInfo, error) {
 	client, err := s.clusterManager.GetClient(clusterID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster client: %w", err)
 	}
 	
-	fileInfo, err := client.GetFileInfo(fileID)
+	fileInfo, err := client.GetFileInfoContext(ctx, fileID)
 	if err != nil {
 		s.logger.Errorf("Failed to get file info %s from cluster %s: %v", fileID, clusterID, err)
 		return nil, err
@@ -227,6 +312,50 @@ func (s *FastDFSService) GetFileInfo(clusterID string, fileID string) (*fastdfs.
 	return fileInfo, nil
 }
 
+// GetMetadata 获取文件元数据
+func (s *FastDFSService) GetMetadata(clusterID string, fileID string) (map[string]string, error) {
+	return s.GetMetadataContext(context.Background(), clusterID, fileID)
+}
+
+// GetMetadataContext 在ctx的控制下获取文件元数据
+func (s *FastDFSService) GetMetadataContext(ctx context.Context, clusterID string, fileID string) (map[string]string, error) {
+	client, err := s.clusterManager.GetClient(clusterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster client: %w", err)
+	}
+	
+	metadata, err := client.GetMetadataContext(ctx, fileID)
+	if err != nil {
+		s.logger.Errorf("Failed to get metadata of file %s from cluster %s: %v", fileID, clusterID, err)
+		return nil, err
+	}
+	
+	s.logger.Debugf("Got %d metadata entries of file %s from cluster %s", len(metadata), fileID, clusterID)
+	return metadata, nil
+}
+
+// SetMetadata 设置文件元数据
+func (s *FastDFSService) SetMetadata(clusterID string, fileID string, metadata map[string]string, flag fastdfs.MetadataFlag) error {
+	return s.SetMetadataContext(context.Background(), clusterID, fileID, metadata, flag)
+}
+
+// SetMetadataContext 在ctx的控制下设置文件元数据
+func (s *FastDFSService) SetMetadataContext(ctx context.Context, clusterID string, fileID string, metadata map[string]string, flag fastdfs.MetadataFlag) error {
+	client, err := s.clusterManager.GetClient(clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster client: %w", err)
+	}
+	
+	err = client.SetMetadataContext(ctx, fileID, metadata, flag)
+	if err != nil {
+		s.logger.Errorf("Failed to set metadata of file %s on cluster %s: %v", fileID, clusterID, err)
+		return err
+	}
+	
+	s.logger.Debugf("Set %d metadata entries of file %s on cluster %s", len(metadata), fileID, clusterID)
+	return nil
+}
+
 // HealthCheck 健康检查
 func (s *FastDFSService) HealthCheck() map[string]error {
 	return s.clusterManager.HealthCheck()
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/models"
+)
+
+// 过滤规则名称，与MigrationConfig中对应字段的JSON名称一致，用于统计每条规则排除的文件数
+const (
+	FilterRuleStartTime         = "start_time"
+	FilterRuleEndTime           = "end_time"
+	FilterRuleIncludeExtensions = "include_extensions"
+	FilterRuleExcludeExtensions = "exclude_extensions"
+	FilterRuleIncludeMimeTypes  = "include_mime_types"
+	FilterRuleExcludeMimeTypes  = "exclude_mime_types"
+)
+
+// mimeSniffLength 检测MIME类型时读取的文件头字节数，http.DetectContentType最多使用这么多字节
+const mimeSniffLength = 512
+
+// fileFilter 迁移任务的文件过滤管道，由MigrationConfig的TimeFilter和FileTypeFilter构成
+// 检查分两步：rejectByName只使用枚举得到的文件ID和创建时间，rejectByInfo在查询源文件信息后检查其余规则。
+// nil表示不过滤任何文件
+type fileFilter struct {
+	startTime         *time.Time
+	endTime           *time.Time
+	includeExtensions []string
+	excludeExtensions []string
+	includeMimeTypes  []string
+	excludeMimeTypes  []string
+}
+
+// newFileFilter 按迁移配置创建过滤管道，没有配置任何过滤规则时返回nil
+func newFileFilter(config *models.MigrationConfig) *fileFilter {
+	filter := &fileFilter{}
+	if config.TimeFilter != nil {
+		filter.startTime = config.TimeFilter.StartTime
+		filter.endTime = config.TimeFilter.EndTime
+	}
+	if config.FileTypeFilter != nil {
+		filter.includeExtensions = normalizeExtensions(config.FileTypeFilter.IncludeExtensions)
+		filter.excludeExtensions = normalizeExtensions(config.FileTypeFilter.ExcludeExtensions)
+		filter.includeMimeTypes = normalizeMimeTypes(config.FileTypeFilter.IncludeMimeTypes)
+		filter.excludeMimeTypes = normalizeMimeTypes(config.FileTypeFilter.ExcludeMimeTypes)
+	}
+
+	if filter.startTime == nil && filter.endTime == nil &&
+		len(filter.includeExtensions) == 0 && len(filter.excludeExtensions) == 0 &&
+		len(filter.includeMimeTypes) == 0 && len(filter.excludeMimeTypes) == 0 {
+		return nil
+	}
+	return filter
+}
+
+// rejectByName 按文件ID的扩展名和创建时间检查文件，返回排除文件的规则名称，文件通过时返回空字符串
+// 创建时间未知（为0）时跳过时间规则，由rejectByInfo检查
+func (f *fileFilter) rejectByName(file *fastdfs.FileInfo) string {
+	if f == nil {
+		return ""
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(file.FileName), "."))
+	if len(f.includeExtensions) > 0 && !slices.Contains(f.includeExtensions, ext) {
+		return FilterRuleIncludeExtensions
+	}
+	if slices.Contains(f.excludeExtensions, ext) {
+		return FilterRuleExcludeExtensions
+	}
+
+	if file.CreateTime != 0 {
+		return f.rejectByTime(file.CreateTime)
+	}
+	return ""
+}
+
+// needsMimeType 检查是否有规则需要源文件的MIME类型
+func (f *fileFilter) needsMimeType() bool {
+	return f != nil && (len(f.includeMimeTypes) > 0 || len(f.excludeMimeTypes) > 0)
+}
+
+// rejectByInfo 按源文件信息检查rejectByName未能检查的规则
+// timeChecked表示rejectByName已检查过时间规则，mimeType为sniffMimeType检测到的类型
+func (f *fileFilter) rejectByInfo(fileInfo *fastdfs.FileInfo, timeChecked bool, mimeType string) string {
+	if f == nil {
+		return ""
+	}
+
+	if !timeChecked {
+		if rule := f.rejectByTime(fileInfo.CreateTime); rule != "" {
+			return rule
+		}
+	}
+
+	if !f.needsMimeType() {
+		return ""
+	}
+	mediaType := mediaTypeOf(mimeType)
+	if len(f.includeMimeTypes) > 0 && !matchMimeType(f.includeMimeTypes, mediaType) {
+		return FilterRuleIncludeMimeTypes
+	}
+	if matchMimeType(f.excludeMimeTypes, mediaType) {
+		return FilterRuleExcludeMimeTypes
+	}
+	return ""
+}
+
+// rejectByTime 检查创建时间是否在[StartTime, EndTime]范围内
+func (f *fileFilter) rejectByTime(createTime int64) string {
+	created := time.Unix(createTime, 0)
+	if f.startTime != nil && created.Before(*f.startTime) {
+		return FilterRuleStartTime
+	}
+	if f.endTime != nil && created.After(*f.endTime) {
+		return FilterRuleEndTime
+	}
+	return ""
+}
+
+// sniffMimeType 读取源文件开头最多mimeSniffLength个字节检测MIME类型
+func sniffMimeType(ctx context.Context, client *fastdfs.PooledClient, fileInfo *fastdfs.FileInfo) (string, error) {
+	length := fileInfo.FileSize
+	if length > mimeSniffLength {
+		length = mimeSniffLength
+	}
+
+	// 下载长度为0表示读取到文件末尾，空文件不需要下载
+	var buf bytes.Buffer
+	if length > 0 {
+		if _, err := client.DownloadRangeTo(ctx, fileInfo.GetFileID(), 0, length, &buf); err != nil {
+			return "", fmt.Errorf("failed to read file header: %w", err)
+		}
+	}
+	return http.DetectContentType(buf.Bytes()), nil
+}
+
+// matchMimeType 检查mediaType是否匹配patterns中的任一类型，"image/*"匹配所有image类型
+func matchMimeType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeExtensions 将扩展名转换为不带点的小写形式
+func normalizeExtensions(extensions []string) []string {
+	var results []string
+	for _, ext := range extensions {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			results = append(results, ext)
+		}
+	}
+	return results
+}
+
+// normalizeMimeTypes 将MIME类型转换为不带参数的小写形式
+func normalizeMimeTypes(mimeTypes []string) []string {
+	var results []string
+	for _, mimeType := range mimeTypes {
+		if mediaType := mediaTypeOf(mimeType); mediaType != "" {
+			results = append(results, mediaType)
+		}
+	}
+	return results
+}
+
+// mediaTypeOf 去掉MIME类型中的参数，如"text/plain; charset=utf-8"转换为"text/plain"
+func mediaTypeOf(mimeType string) string {
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/models"
+	"fastdfs-migration-system/internal/repository"
+)
+
+// pngHeader PNG文件头，http.DetectContentType据此识别为image/png
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestFileFilter(t *testing.T) {
+	if filter := newFileFilter(&models.MigrationConfig{FileTypeFilter: &models.FileTypeFilter{}}); filter != nil {
+		t.Errorf("Expected nil filter without rules, got %+v", filter)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	filter := newFileFilter(&models.MigrationConfig{
+		TimeFilter: &models.TimeFilter{StartTime: &start, EndTime: &end},
+		FileTypeFilter: &models.FileTypeFilter{
+			IncludeExtensions: []string{".JPG", "png", "txt"},
+			ExcludeExtensions: []string{"txt"},
+			IncludeMimeTypes:  []string{"image/*"},
+			ExcludeMimeTypes:  []string{"image/gif; charset=binary"},
+		},
+	})
+
+	inRange := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+	tests := []struct {
+		name       string
+		fileName   string
+		createTime int64
+		want       string
+	}{
+		{"included", "M00/00/00/a.jpg", inRange, ""},
+		{"extension case", "M00/00/00/a.PNG", inRange, ""},
+		{"not included", "M00/00/00/a.gif", inRange, FilterRuleIncludeExtensions},
+		{"no extension", "M00/00/00/a", inRange, FilterRuleIncludeExtensions},
+		{"excluded", "M00/00/00/a.txt", inRange, FilterRuleExcludeExtensions},
+		{"too old", "M00/00/00/a.jpg", start.Unix() - 1, FilterRuleStartTime},
+		{"too new", "M00/00/00/a.jpg", end.Unix() + 1, FilterRuleEndTime},
+		{"unknown time", "M00/00/00/a.jpg", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &fastdfs.FileInfo{GroupName: "group1", FileName: tt.fileName, CreateTime: tt.createTime}
+			if got := filter.rejectByName(file); got != tt.want {
+				t.Errorf("Expected rule %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	fileInfo := &fastdfs.FileInfo{GroupName: "group1", FileName: "M00/00/00/a.jpg", CreateTime: start.Unix() - 1}
+	if got := filter.rejectByInfo(fileInfo, false, "image/jpeg"); got != FilterRuleStartTime {
+		t.Errorf("Expected unchecked time rule to reject the file, got %q", got)
+	}
+	fileInfo.CreateTime = inRange
+	for mimeType, want := range map[string]string{
+		"image/jpeg":                "",
+		"text/plain; charset=utf-8": FilterRuleIncludeMimeTypes,
+		"image/gif":                 FilterRuleExcludeMimeTypes,
+	} {
+		if got := filter.rejectByInfo(fileInfo, true, mimeType); got != want {
+			t.Errorf("MIME type %s: expected rule %q, got %q", mimeType, want, got)
+		}
+	}
+
+	var none *fileFilter
+	if none.rejectByName(fileInfo) != "" || none.needsMimeType() || none.rejectByInfo(fileInfo, false, "") != "" {
+		t.Error("Expected nil filter to accept every file")
+	}
+}
+
+func TestMigrationEngine_Filters(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+
+	var fileIDs []string
+	for _, file := range []struct {
+		ext  string
+		data []byte
+	}{
+		{"png", append(pngHeader, make([]byte, 2048)...)},
+		{"txt", []byte("plain text")},
+		{"jpg", []byte("not really an image")},
+		{"log", []byte("log line")},
+	} {
+		fileID, err := source.PutFile("group1", file.ext, file.data, nil)
+		if err != nil {
+			t.Fatalf("Failed to seed source file: %v", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+
+	migration := createTestMigration(t, repo, fileIDs, models.MigrationConfig{
+		FileTypeFilter: &models.FileTypeFilter{
+			ExcludeExtensions: []string{"log"},
+			IncludeMimeTypes:  []string{"image/*"},
+		},
+	})
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected run error: %v", err)
+	}
+
+	copied := target.FileIDs("group1")
+	if len(copied) != 1 {
+		t.Fatalf("Expected only the PNG file to be copied, got %v", copied)
+	}
+	if data, _ := target.File(copied[0]); len(data) != len(pngHeader)+2048 {
+		t.Errorf("Expected the full PNG file to be copied, got %d bytes", len(data))
+	}
+
+	result, _ := repo.Migration().GetByID(migration.ID)
+	if result.Status != models.MigrationStatusCompleted || result.ProcessedFiles != 4 || result.Progress != 100 {
+		t.Errorf("Expected completed migration with filtered files processed, got %+v", result)
+	}
+	if filtered := finishedFilterCounts(t, repo, migration.ID); filtered != "map[exclude_extensions:1 include_mime_types:2]" {
+		t.Errorf("Unexpected filter counts: %s", filtered)
+	}
+
+	// 所有文件都早于StartTime
+	startTime := time.Now().Add(time.Hour)
+	migration = createTestMigration(t, repo, fileIDs, models.MigrationConfig{
+		TimeFilter: &models.TimeFilter{StartTime: &startTime},
+	})
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected run error: %v", err)
+	}
+	if copied := target.FileIDs("group1"); len(copied) != 1 {
+		t.Errorf("Expected no files to be copied, got %v", copied)
+	}
+	if filtered := finishedFilterCounts(t, repo, migration.ID); filtered != "map[start_time:4]" {
+		t.Errorf("Unexpected filter counts: %s", filtered)
+	}
+}
+
+// finishedFilterCounts 获取迁移结束日志中每条过滤规则排除的文件数
+func finishedFilterCounts(t *testing.T, repo repository.Repository, migrationID string) string {
+	logs, err := repo.TaskLog().GetByTaskID(migrationID, &models.Pagination{Page: 1, PageSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to load task logs: %v", err)
+	}
+	for _, log := range logs {
+		if log.Message == "Migration finished" {
+			return fmt.Sprint(log.Details["filtered_files"])
+		}
+	}
+	t.Fatal("Migration finished log not found")
+	return ""
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"fastdfs-migration-system/internal/models"
+	"fastdfs-migration-system/internal/repository"
+)
+
+// 文件ID映射的导出格式
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+)
+
+// fileMappingCSVHeader CSV导出的表头
+var fileMappingCSVHeader = []string{"source_file_id", "target_file_id", "file_size", "crc32", "migration_id", "created_at"}
+
+// FileMappingService 文件ID映射服务，供应用在迁移后将旧文件ID转换为目标集群中的文件ID
+type FileMappingService struct {
+	repo repository.Repository
+}
+
+// NewFileMappingService 创建文件ID映射服务
+func NewFileMappingService(repo repository.Repository) *FileMappingService {
+	return &FileMappingService{repo: repo}
+}
+
+// TranslateFileIDs 批量将源文件ID转换为目标文件ID，没有映射的文件ID不在结果中
+func (s *FileMappingService) TranslateFileIDs(sourceFileIDs []string) (map[string]string, error) {
+	mappings, err := s.repo.FileMapping().GetBySourceFileIDs(sourceFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up file mappings: %w", err)
+	}
+
+	results := make(map[string]string, len(mappings))
+	for sourceFileID, mapping := range mappings {
+		results[sourceFileID] = mapping.TargetFileID
+	}
+	return results, nil
+}
+
+// ReverseTranslateFileIDs 批量将目标文件ID转换回源文件ID，没有映射的文件ID不在结果中
+func (s *FileMappingService) ReverseTranslateFileIDs(targetFileIDs []string) (map[string]string, error) {
+	mappings, err := s.repo.FileMapping().GetByTargetFileIDs(targetFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up file mappings: %w", err)
+	}
+
+	results := make(map[string]string, len(mappings))
+	for targetFileID, mapping := range mappings {
+		results[targetFileID] = mapping.SourceFileID
+	}
+	return results, nil
+}
+
+// Export 将迁移任务的全部文件ID映射以format格式写入w，映射逐批从数据库读取
+func (s *FileMappingService) Export(w io.Writer, migrationID string, format string) error {
+	switch format {
+	case ExportFormatCSV:
+		return s.exportCSV(w, migrationID)
+	case ExportFormatJSON:
+		return s.exportJSON(w, migrationID)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// exportCSV 以CSV格式导出映射，第一行为表头
+func (s *FileMappingService) exportCSV(w io.Writer, migrationID string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(fileMappingCSVHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	err := s.repo.FileMapping().ForEachByMigrationID(migrationID, func(mapping *models.FileMapping) error {
+		return writer.Write([]string{
+			mapping.SourceFileID,
+			mapping.TargetFileID,
+			strconv.FormatInt(mapping.FileSize, 10),
+			strconv.FormatUint(uint64(mapping.CRC32), 10),
+			mapping.MigrationID,
+			mapping.CreatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export file mappings: %w", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportJSON 以JSON数组格式导出映射，每个元素占一行
+func (s *FileMappingService) exportJSON(w io.Writer, migrationID string) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	separator := "\n"
+	err := s.repo.FileMapping().ForEachByMigrationID(migrationID, func(mapping *models.FileMapping) error {
+		data, err := json.Marshal(mapping)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		separator = ",\n"
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export file mappings: %w", err)
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"fastdfs-migration-system/internal/models"
+)
+
+func TestFileMappingService(t *testing.T) {
+	repo := newTestRepository(t)
+	service := NewFileMappingService(repo)
+
+	for i := 0; i < 3; i++ {
+		err := repo.FileMapping().Save(&models.FileMapping{
+			MigrationID:  "migration-1",
+			SourceFileID: fmt.Sprintf("group1/M00/00/00/source%d.jpg", i),
+			TargetFileID: fmt.Sprintf("group1/M00/00/00/target%d.jpg", i),
+			FileSize:     int64(100 + i),
+			CRC32:        uint32(i),
+		})
+		if err != nil {
+			t.Fatalf("Failed to save file mapping: %v", err)
+		}
+	}
+
+	translated, err := service.TranslateFileIDs([]string{"group1/M00/00/00/source1.jpg", "group1/M00/00/00/unknown.jpg"})
+	if err != nil || len(translated) != 1 || translated["group1/M00/00/00/source1.jpg"] != "group1/M00/00/00/target1.jpg" {
+		t.Errorf("Unexpected translation: %v (%v)", translated, err)
+	}
+
+	reversed, err := service.ReverseTranslateFileIDs([]string{"group1/M00/00/00/target2.jpg"})
+	if err != nil || reversed["group1/M00/00/00/target2.jpg"] != "group1/M00/00/00/source2.jpg" {
+		t.Errorf("Unexpected reverse translation: %v (%v)", reversed, err)
+	}
+
+	var csvOutput bytes.Buffer
+	if err := service.Export(&csvOutput, "migration-1", ExportFormatCSV); err != nil {
+		t.Fatalf("Unexpected CSV export error: %v", err)
+	}
+	records, err := csv.NewReader(&csvOutput).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV export: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "source_file_id" || records[1][2] == "" {
+		t.Errorf("Unexpected CSV export: %v", records)
+	}
+
+	var jsonOutput bytes.Buffer
+	if err := service.Export(&jsonOutput, "migration-1", ExportFormatJSON); err != nil {
+		t.Fatalf("Unexpected JSON export error: %v", err)
+	}
+	var mappings []*models.FileMapping
+	if err := json.Unmarshal(jsonOutput.Bytes(), &mappings); err != nil {
+		t.Fatalf("Invalid JSON export: %v\n%s", err, jsonOutput.String())
+	}
+	if len(mappings) != 3 || mappings[0].TargetFileID == "" {
+		t.Errorf("Unexpected JSON export: %s", jsonOutput.String())
+	}
+
+	jsonOutput.Reset()
+	if err := service.Export(&jsonOutput, "migration-2", ExportFormatJSON); err != nil {
+		t.Fatalf("Unexpected JSON export error: %v", err)
+	}
+	if err := json.Unmarshal(jsonOutput.Bytes(), &mappings); err != nil || len(mappings) != 0 {
+		t.Errorf("Expected empty JSON array, got %s", jsonOutput.String())
+	}
+
+	if err := service.Export(&jsonOutput, "migration-1", "xml"); err == nil {
+		t.Error("Expected error for unsupported export format")
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/models"
+)
+
+// uploadFunc 将r中size字节的内容上传到目标集群，返回目标文件ID
+type uploadFunc func(ctx context.Context, extName string, size int64, r io.Reader) (string, error)
+
+// TransferFile 将源集群中的单个文件复制到目标集群，返回目标集群中的文件ID
+// 文件内容以流的方式从源集群直接转发到目标集群，不会整体加载到内存
+func (s *FastDFSService) TransferFile(ctx context.Context, sourceClusterID string, targetClusterID string, fileID string, config *models.MigrationConfig) (string, error) {
+	source, target, err := s.getTransferClients(sourceClusterID, targetClusterID)
+	if err != nil {
+		return "", err
+	}
+
+	return s.transferFile(ctx, source, target, fileID, config, nil)
+}
+
+// TransferFileGroup 将master文件及其slave文件一起复制到目标集群，返回源文件ID到目标文件ID的映射
+// slave文件基于目标集群中新的master文件上传，从而保留master/slave的命名关系
+func (s *FastDFSService) TransferFileGroup(ctx context.Context, sourceClusterID string, targetClusterID string, group *fastdfs.MasterSlaveGroup, config *models.MigrationConfig) (map[string]string, error) {
+	source, target, err := s.getTransferClients(sourceClusterID, targetClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]string)
+
+	// master文件不在本批文件中时，slave文件只能作为普通文件迁移
+	if group.MasterFileID == "" {
+		for _, slaveFileID := range group.SlaveFileIDs {
+			s.logger.Warnf("Master of slave file %s not found, transferring it as a normal file", slaveFileID)
+			targetFileID, err := s.transferFile(ctx, source, target, slaveFileID, config, nil)
+			if err != nil {
+				return results, err
+			}
+			results[slaveFileID] = targetFileID
+		}
+		return results, nil
+	}
+
+	targetMasterID, err := s.transferFile(ctx, source, target, group.MasterFileID, config, nil)
+	if err != nil {
+		return results, err
+	}
+	results[group.MasterFileID] = targetMasterID
+
+	for _, slaveFileID := range group.SlaveFileIDs {
+		prefix, err := fastdfs.SlavePrefixOf(group.MasterFileID, slaveFileID)
+		if err != nil {
+			return results, fmt.Errorf("invalid slave file ID %s: %w", slaveFileID, err)
+		}
+
+		targetFileID, err := s.transferFile(ctx, source, target, slaveFileID, config, slaveUploadFunc(target, targetMasterID, prefix))
+		if err != nil {
+			return results, err
+		}
+		results[slaveFileID] = targetFileID
+	}
+
+	return results, nil
+}
+
+// getTransferClients 获取源集群和目标集群的客户端
+func (s *FastDFSService) getTransferClients(sourceClusterID string, targetClusterID string) (*fastdfs.PooledClient, *fastdfs.PooledClient, error) {
+	source, err := s.clusterManager.GetClient(sourceClusterID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get source cluster client: %w", err)
+	}
+
+	target, err := s.clusterManager.GetClient(targetClusterID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get target cluster client: %w", err)
+	}
+
+	return source, target, nil
+}
+
+// transferFile 复制单个文件的内容和元数据，upload为nil时按源文件类型选择上传方式
+func (s *FastDFSService) transferFile(ctx context.Context, source, target *fastdfs.PooledClient, fileID string, config *models.MigrationConfig, upload uploadFunc) (string, error) {
+	fileInfo, err := source.GetFileInfoContext(ctx, fileID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get source file info: %w", err)
+	}
+
+	return s.transferFileInfo(ctx, source, target, fileInfo, config, upload)
+}
+
+// transferFileInfo 复制已查询到文件信息的单个文件，fileInfo须来自源集群的QUERY_FILE_INFO
+func (s *FastDFSService) transferFileInfo(ctx context.Context, source, target *fastdfs.PooledClient, fileInfo *fastdfs.FileInfo, config *models.MigrationConfig, upload uploadFunc) (string, error) {
+	if upload == nil {
+		upload = defaultUploadFunc(target, fileInfo)
+	}
+
+	targetFileID, err := copyFileContent(ctx, source, fileInfo, upload)
+	if err != nil {
+		return "", err
+	}
+
+	return s.finishTransfer(ctx, source, target, fileInfo, targetFileID, config)
+}
+
+// finishTransfer 在文件内容复制完成后按配置复制元数据
+func (s *FastDFSService) finishTransfer(ctx context.Context, source, target *fastdfs.PooledClient, fileInfo *fastdfs.FileInfo, targetFileID string, config *models.MigrationConfig) (string, error) {
+	fileID := fileInfo.GetFileID()
+	if config != nil && config.PreserveMetadata {
+		if err := copyMetadata(ctx, source, target, fileID, targetFileID); err != nil {
+			// 元数据复制失败时删除目标文件，避免留下不完整的副本；ctx可能已被取消，因此清理不受ctx控制
+			if delErr := target.DeleteFile(targetFileID); delErr != nil {
+				s.logger.Warnf("Failed to clean up target file %s: %v", targetFileID, delErr)
+			}
+			return "", err
+		}
+	}
+
+	s.logger.Debugf("Transferred file %s (%d bytes) as %s", fileID, fileInfo.FileSize, targetFileID)
+	return targetFileID, nil
+}
+
+// defaultUploadFunc 返回普通文件的上传方式
+// appender文件在目标集群上仍以appender文件创建，以保证迁移后可以继续追加
+func defaultUploadFunc(target *fastdfs.PooledClient, fileInfo *fastdfs.FileInfo) uploadFunc {
+	groupName := fileInfo.GroupName
+	appender := fastdfs.IsAppenderFile(fileInfo.GetFileID())
+
+	return func(ctx context.Context, extName string, size int64, r io.Reader) (string, error) {
+		if appender {
+			return target.UploadAppenderFrom(ctx, groupName, extName, size, r)
+		}
+		return target.UploadFrom(ctx, groupName, extName, size, r)
+	}
+}
+
+// slaveUploadFunc 返回以targetMasterID为master上传slave文件的方式
+func slaveUploadFunc(target *fastdfs.PooledClient, targetMasterID string, prefix string) uploadFunc {
+	return func(ctx context.Context, extName string, size int64, r io.Reader) (string, error) {
+		return target.UploadSlaveFrom(ctx, targetMasterID, prefix, extName, size, r)
+	}
+}
+
+// copyFileContent 通过管道将源文件内容流式交给upload上传到目标集群
+func copyFileContent(ctx context.Context, source *fastdfs.PooledClient, fileInfo *fastdfs.FileInfo, upload uploadFunc) (string, error) {
+	fileID := fileInfo.GetFileID()
+	extName := strings.TrimPrefix(path.Ext(fileInfo.FileName), ".")
+
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := source.DownloadTo(ctx, fileID, writer)
+		writer.CloseWithError(err)
+	}()
+
+	targetFileID, err := upload(ctx, extName, fileInfo.FileSize, reader)
+	reader.CloseWithError(err)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy file %s: %w", fileID, err)
+	}
+
+	return targetFileID, nil
+}
+
+// copyMetadata 将源文件的元数据复制到目标文件
+func copyMetadata(ctx context.Context, source, target *fastdfs.PooledClient, sourceFileID string, targetFileID string) error {
+	metadata, err := source.GetMetadataContext(ctx, sourceFileID)
+	if err != nil {
+		return fmt.Errorf("failed to get metadata of %s: %w", sourceFileID, err)
+	}
+
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	if err := target.SetMetadataContext(ctx, targetFileID, metadata, fastdfs.MetadataOverwrite); err != nil {
+		return fmt.Errorf("failed to set metadata of %s: %w", targetFileID, err)
+	}
+
+	return nil
+}
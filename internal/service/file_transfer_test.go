@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/fastdfs/fdfstest"
+	"fastdfs-migration-system/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTransferTestService 启动源集群和目标集群，并创建已添加这两个集群的服务
+func newTransferTestService(t *testing.T) (*FastDFSService, *fdfstest.Cluster, *fdfstest.Cluster) {
+	source := fdfstest.NewCluster()
+	t.Cleanup(source.Close)
+	target := fdfstest.NewCluster()
+	t.Cleanup(target.Close)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	s := NewFastDFSService(nil, logger)
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.clusterManager.AddCluster(source.Model("source")); err != nil {
+		t.Fatalf("Failed to add source cluster: %v", err)
+	}
+	if err := s.clusterManager.AddCluster(target.Model("target")); err != nil {
+		t.Fatalf("Failed to add target cluster: %v", err)
+	}
+	return s, source, target
+}
+
+func TestTransferFile_EndToEnd(t *testing.T) {
+	s, source, target := newTransferTestService(t)
+
+	data := bytes.Repeat([]byte("fastdfs"), 1024)
+	fileID, err := source.PutFile("group1", "bin", data, map[string]string{"owner": "alice"})
+	if err != nil {
+		t.Fatalf("Failed to seed source file: %v", err)
+	}
+
+	config := &models.MigrationConfig{PreserveMetadata: true}
+	targetFileID, err := s.TransferFile(context.Background(), "source", "target", fileID, config)
+	if err != nil {
+		t.Fatalf("Unexpected transfer error: %v", err)
+	}
+
+	copied, exists := target.File(targetFileID)
+	if !exists {
+		t.Fatalf("Expected %s to exist in target cluster", targetFileID)
+	}
+	if !bytes.Equal(copied, data) {
+		t.Error("Expected target content to match source")
+	}
+	if metadata := target.Metadata(targetFileID); metadata["owner"] != "alice" {
+		t.Errorf("Expected metadata to be copied, got %v", metadata)
+	}
+}
+
+func TestTransferFileGroup_EndToEnd(t *testing.T) {
+	s, source, target := newTransferTestService(t)
+
+	client, err := source.Client()
+	if err != nil {
+		t.Fatalf("Failed to connect to source: %v", err)
+	}
+	defer client.Close()
+
+	masterID, err := client.UploadFile("group1", "photo.jpg", []byte("master"))
+	if err != nil {
+		t.Fatalf("Failed to seed master file: %v", err)
+	}
+	slaveID, err := client.UploadSlaveFile(masterID, "_small", "jpg", []byte("thumb"))
+	if err != nil {
+		t.Fatalf("Failed to seed slave file: %v", err)
+	}
+
+	groups := fastdfs.GroupMasterSlaveFiles([]string{slaveID, masterID})
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 master/slave group, got %d", len(groups))
+	}
+
+	results, err := s.TransferFileGroup(context.Background(), "source", "target", groups[0], nil)
+	if err != nil {
+		t.Fatalf("Unexpected transfer error: %v", err)
+	}
+
+	targetSlaveID := results[slaveID]
+	masterKey, prefix, err := fastdfs.SplitSlaveFileID(targetSlaveID)
+	if err != nil || prefix != "_small" {
+		t.Fatalf("Expected target slave file with prefix _small, got %s (%v)", targetSlaveID, err)
+	}
+	if wantKey, _, _ := fastdfs.SplitSlaveFileID(results[masterID]); masterKey != wantKey {
+		t.Errorf("Expected target slave to belong to %s, got %s", wantKey, masterKey)
+	}
+	if data, _ := target.File(targetSlaveID); string(data) != "thumb" {
+		t.Errorf("Unexpected target slave content: %q", data)
+	}
+}
@@ -0,0 +1,864 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"fastdfs-migration-system/internal/config"
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/models"
+	"fastdfs-migration-system/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// progressInterval 迁移进度写入数据库的最小间隔
+const progressInterval = time.Second
+
+// errVerificationMismatch 目标副本多次校验都与源文件不一致
+var errVerificationMismatch = errors.New("verification mismatch")
+
+// errFileFiltered 文件被迁移任务的过滤规则排除
+var errFileFiltered = errors.New("file filtered")
+
+// MigrationEngine 迁移引擎，按models.Migration的配置枚举源集群文件并复制到目标集群
+type MigrationEngine struct {
+	service *FastDFSService
+	repo    repository.Repository
+	config  config.MigrationConfig
+	logger  *logrus.Logger
+
+	mu      sync.Mutex
+	running map[string]*migrationRun
+}
+
+// migrationRun 一次正在执行的迁移
+type migrationRun struct {
+	migration *models.Migration
+	source    *fastdfs.PooledClient
+	target    *fastdfs.PooledClient
+	retry     models.RetryConfig
+	filter    *fileFilter
+	cancel    context.CancelFunc
+
+	// 已有的传输状态，按源文件ID索引，用于跳过之前已完成的文件
+	states map[string]*models.TransferState
+
+	mu               sync.Mutex
+	stopStatus       string // 被Pause或Cancel停止时的目标状态
+	totalFiles       int64
+	totalSize        int64
+	processedFiles   int64
+	processedSize    int64
+	skippedFiles     int64
+	failedFiles      int64
+	quarantinedFiles int64
+	filteredFiles    map[string]int64 // 每条过滤规则排除的文件数
+	lastProgress     time.Time
+}
+
+// migrationJob 一个迁移单元：单个文件，或master文件及其slave文件
+type migrationJob struct {
+	file   *fastdfs.FileInfo
+	slaves []*fastdfs.FileInfo
+}
+
+// NewMigrationEngine 创建迁移引擎，cfg提供迁移任务未指定并发数和重试配置时的默认值
+func NewMigrationEngine(fastdfsService *FastDFSService, repo repository.Repository, cfg config.MigrationConfig, logger *logrus.Logger) *MigrationEngine {
+	return &MigrationEngine{
+		service: fastdfsService,
+		repo:    repo,
+		config:  cfg,
+		logger:  logger,
+		running: make(map[string]*migrationRun),
+	}
+}
+
+// Run 执行迁移任务并等待其结束
+// 所有文件都复制成功时任务状态变为completed，否则为failed；ctx被取消时任务状态变为paused
+// 再次执行paused或failed的任务时，已完成的文件会被跳过
+func (e *MigrationEngine) Run(ctx context.Context, migrationID string) error {
+	run, ctx, err := e.prepare(ctx, migrationID)
+	if err != nil {
+		return err
+	}
+	return e.execute(ctx, run)
+}
+
+// Start 在后台执行迁移任务，任务无法启动时立即返回错误
+func (e *MigrationEngine) Start(migrationID string) error {
+	run, ctx, err := e.prepare(context.Background(), migrationID)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := e.execute(ctx, run); err != nil {
+			e.logger.Errorf("Migration %s failed: %v", migrationID, err)
+		}
+	}()
+	return nil
+}
+
+// Pause 暂停正在执行的迁移任务，之后可以再次启动
+func (e *MigrationEngine) Pause(migrationID string) error {
+	return e.stop(migrationID, models.MigrationStatusPaused)
+}
+
+// Cancel 取消正在执行的迁移任务
+func (e *MigrationEngine) Cancel(migrationID string) error {
+	return e.stop(migrationID, models.MigrationStatusCancelled)
+}
+
+// IsRunning 检查迁移任务是否正在执行
+func (e *MigrationEngine) IsRunning(migrationID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, exists := e.running[migrationID]
+	return exists
+}
+
+// stop 停止正在执行的迁移任务，status为任务停止后的状态
+func (e *MigrationEngine) stop(migrationID string, status string) error {
+	e.mu.Lock()
+	run, exists := e.running[migrationID]
+	e.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("migration %s is not running", migrationID)
+	}
+
+	run.mu.Lock()
+	run.stopStatus = status
+	run.mu.Unlock()
+	run.cancel()
+	return nil
+}
+
+// prepare 加载迁移任务、获取集群客户端并登记为正在执行
+func (e *MigrationEngine) prepare(ctx context.Context, migrationID string) (*migrationRun, context.Context, error) {
+	migration, err := e.repo.Migration().GetByID(migrationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load migration %s: %w", migrationID, err)
+	}
+
+	if !migration.CanStart() && !migration.IsFailed() {
+		return nil, nil, fmt.Errorf("migration %s cannot be started in status %s", migrationID, migration.Status)
+	}
+
+	source, target, err := e.service.getTransferClients(migration.SourceClusterID, migration.TargetClusterID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	states, err := e.repo.TransferState().GetByTaskID(migrationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load transfer states: %w", err)
+	}
+
+	run := &migrationRun{
+		migration: migration,
+		source:    source,
+		target:    target,
+		retry:     e.retryConfig(&migration.Config),
+		filter:    newFileFilter(&migration.Config),
+		states:    make(map[string]*models.TransferState),
+	}
+	// 同一文件有多条记录时以最新的为准，GetByTaskID按创建时间倒序返回
+	for _, state := range states {
+		if _, exists := run.states[state.FileID]; !exists {
+			run.states[state.FileID] = state
+		}
+	}
+
+	ctx, run.cancel = context.WithCancel(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, exists := e.running[migrationID]; exists {
+		run.cancel()
+		return nil, nil, fmt.Errorf("migration %s is already running", migrationID)
+	}
+	e.running[migrationID] = run
+	return run, ctx, nil
+}
+
+// execute 执行已登记的迁移任务并更新任务状态
+func (e *MigrationEngine) execute(ctx context.Context, run *migrationRun) error {
+	migrationID := run.migration.ID
+	defer func() {
+		run.cancel()
+		e.mu.Lock()
+		delete(e.running, migrationID)
+		e.mu.Unlock()
+	}()
+
+	if err := e.repo.Migration().UpdateStatus(migrationID, models.MigrationStatusRunning); err != nil {
+		return fmt.Errorf("failed to update migration status: %w", err)
+	}
+	e.logger.Infof("Migration %s started", migrationID)
+	e.log(migrationID, models.LogLevelInfo, "Migration started", nil)
+
+	runErr := e.migrate(ctx, run)
+
+	run.mu.Lock()
+	status := run.stopStatus
+	run.mu.Unlock()
+
+	switch {
+	case status != "":
+	case ctx.Err() != nil:
+		status = models.MigrationStatusPaused
+	case runErr != nil || run.failedFiles > 0:
+		status = models.MigrationStatusFailed
+	default:
+		status = models.MigrationStatusCompleted
+	}
+
+	errorMessage := ""
+	if runErr != nil && ctx.Err() == nil {
+		errorMessage = runErr.Error()
+	} else if run.failedFiles > 0 {
+		errorMessage = fmt.Sprintf("%d of %d files failed", run.failedFiles, run.totalFiles)
+	}
+
+	if err := e.finish(run, status, errorMessage); err != nil {
+		return err
+	}
+
+	details := models.LogDetails{
+		"status":          status,
+		"total_files":     run.totalFiles,
+		"processed_files": run.processedFiles,
+		"skipped_files":   run.skippedFiles,
+		"failed_files":    run.failedFiles,
+	}
+	if run.quarantinedFiles > 0 {
+		details["quarantined_files"] = run.quarantinedFiles
+	}
+	if len(run.filteredFiles) > 0 {
+		details["filtered_files"] = run.filteredFiles
+		for rule, count := range run.filteredFiles {
+			e.logger.Infof("Migration %s: filter rule %s rejected %d files", migrationID, rule, count)
+		}
+	}
+	if errorMessage != "" {
+		details["error"] = errorMessage
+	}
+	level := models.LogLevelInfo
+	if status == models.MigrationStatusFailed {
+		level = models.LogLevelError
+	}
+	e.logger.Infof("Migration %s finished with status %s: %d/%d files processed", migrationID, status, run.processedFiles, run.totalFiles)
+	e.log(migrationID, level, "Migration finished", details)
+
+	if status == models.MigrationStatusFailed {
+		if errorMessage == "" {
+			errorMessage = "migration failed"
+		}
+		return errors.New(errorMessage)
+	}
+	return nil
+}
+
+// migrate 枚举源文件并由ConcurrentWorkers个worker并发复制
+func (e *MigrationEngine) migrate(ctx context.Context, run *migrationRun) error {
+	enumerator, err := e.openEnumerator(run.migration)
+	if err != nil {
+		return err
+	}
+	defer enumerator.Close()
+
+	workers := run.migration.Config.ConcurrentWorkers
+	if workers <= 0 {
+		workers = e.config.DefaultWorkers
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+
+	jobs := make(chan *migrationJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				e.migrateJob(ctx, run, job)
+			}
+		}()
+	}
+
+	enumErr := e.enumerate(ctx, run, enumerator, jobs)
+	close(jobs)
+	wg.Wait()
+
+	e.reportProgress(run, true)
+
+	if enumErr != nil {
+		return enumErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 所有文件都已处理后才能保存枚举位置，否则中断后会漏掉尚未复制的文件
+	if checkpointer, ok := enumerator.(interface{ Checkpoint() error }); ok && run.failedFiles == 0 {
+		if err := checkpointer.Checkpoint(); err != nil {
+			return fmt.Errorf("failed to save enumeration position: %w", err)
+		}
+	}
+	return nil
+}
+
+// enumerate 从枚举器读取文件并发送到jobs
+// master/slave模式下需要先读取全部文件才能分组，其他情况下边枚举边复制
+func (e *MigrationEngine) enumerate(ctx context.Context, run *migrationRun, enumerator fastdfs.FileEnumerator, jobs chan<- *migrationJob) error {
+	masterSlave := run.migration.Config.MasterSlaveMode
+	files := make(map[string]*fastdfs.FileInfo)
+	var fileIDs []string
+
+	send := func(job *migrationJob) bool {
+		select {
+		case jobs <- job:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		file, err := enumerator.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to enumerate source files: %w", err)
+		}
+
+		run.mu.Lock()
+		run.totalFiles++
+		run.totalSize += file.FileSize
+		run.mu.Unlock()
+
+		if !masterSlave {
+			if !send(&migrationJob{file: file}) {
+				return nil
+			}
+			continue
+		}
+
+		fileID := file.GetFileID()
+		if _, exists := files[fileID]; !exists {
+			fileIDs = append(fileIDs, fileID)
+		}
+		files[fileID] = file
+	}
+
+	for _, group := range fastdfs.GroupMasterSlaveFiles(fileIDs) {
+		// master文件不在本批文件中时，slave文件只能作为普通文件迁移
+		if group.MasterFileID == "" {
+			for _, slaveFileID := range group.SlaveFileIDs {
+				e.logger.Warnf("Master of slave file %s not found, transferring it as a normal file", slaveFileID)
+				if !send(&migrationJob{file: files[slaveFileID]}) {
+					return nil
+				}
+			}
+			continue
+		}
+
+		job := &migrationJob{file: files[group.MasterFileID]}
+		for _, slaveFileID := range group.SlaveFileIDs {
+			job.slaves = append(job.slaves, files[slaveFileID])
+		}
+		if !send(job) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// migrateJob 复制一个迁移单元，master文件复制失败时其slave文件也记为失败
+func (e *MigrationEngine) migrateJob(ctx context.Context, run *migrationRun, job *migrationJob) {
+	targetFileID, err := e.migrateFile(ctx, run, job.file, nil)
+
+	for _, slave := range job.slaves {
+		if errors.Is(err, errFileFiltered) {
+			// master文件被排除时slave文件按自身的过滤结果作为普通文件迁移
+			e.migrateFile(ctx, run, slave, nil)
+			continue
+		}
+		if err != nil {
+			e.recordFailure(run, slave, nil, fmt.Errorf("master file %s was not migrated: %w", job.file.GetFileID(), err))
+			continue
+		}
+
+		var upload uploadFunc
+		if targetFileID != "" {
+			prefix, splitErr := fastdfs.SlavePrefixOf(job.file.GetFileID(), slave.GetFileID())
+			if splitErr != nil {
+				e.recordFailure(run, slave, nil, fmt.Errorf("invalid slave file ID: %w", splitErr))
+				continue
+			}
+			upload = slaveUploadFunc(run.target, targetFileID, prefix)
+		} else {
+			// master文件在之前的执行中已迁移，但没有找到其映射
+			e.logger.Warnf("Target of master file %s is unknown, transferring slave file %s as a normal file", job.file.GetFileID(), slave.GetFileID())
+		}
+		e.migrateFile(ctx, run, slave, upload)
+	}
+}
+
+// migrateFile 复制单个文件并记录传输状态，返回目标文件ID
+// 文件在之前的执行中已完成或已从源集群删除时返回空的目标文件ID和nil，被过滤规则排除时返回errFileFiltered
+func (e *MigrationEngine) migrateFile(ctx context.Context, run *migrationRun, file *fastdfs.FileInfo, upload uploadFunc) (string, error) {
+	fileID := file.GetFileID()
+
+	run.mu.Lock()
+	state := run.states[fileID]
+	run.mu.Unlock()
+
+	if state != nil && state.IsCompleted() {
+		e.recordSkipped(run, state.TotalSize)
+		// 返回之前记录的目标文件ID，master文件已完成时slave文件仍可基于它上传
+		if mapping, err := e.repo.FileMapping().Get(run.migration.ID, fileID); err == nil {
+			return mapping.TargetFileID, nil
+		}
+		return "", nil
+	}
+
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	if rule := run.filter.rejectByName(file); rule != "" {
+		e.recordFiltered(run, fileID, rule)
+		return "", errFileFiltered
+	}
+
+	var fileInfo *fastdfs.FileInfo
+	err := withRetry(ctx, run.retry, func() error {
+		var err error
+		fileInfo, err = run.source.GetFileInfoContext(ctx, fileID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, fastdfs.ErrFileNotFound) {
+			// 文件在枚举之后被删除
+			e.log(run.migration.ID, models.LogLevelWarn, "Source file not found, skipped", models.LogDetails{"file_id": fileID})
+			e.recordSkipped(run, 0)
+			return "", nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		e.recordFailure(run, file, state, fmt.Errorf("failed to get source file info: %w", err))
+		return "", err
+	}
+
+	var mimeType string
+	if run.filter.needsMimeType() {
+		err := withRetry(ctx, run.retry, func() error {
+			var err error
+			mimeType, err = sniffMimeType(ctx, run.source, fileInfo)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", err
+			}
+			e.recordFailure(run, file, state, fmt.Errorf("failed to detect MIME type: %w", err))
+			return "", err
+		}
+	}
+	// 枚举时创建时间未知的文件在这里检查时间规则
+	if rule := run.filter.rejectByInfo(fileInfo, file.CreateTime != 0, mimeType); rule != "" {
+		e.recordFiltered(run, fileID, rule)
+		return "", errFileFiltered
+	}
+
+	if state == nil {
+		state = &models.TransferState{
+			TaskID:   run.migration.ID,
+			FileID:   fileID,
+			FilePath: fileInfo.FileName,
+		}
+	}
+
+	// 超过ChunkSize的文件分块传输，slave文件只能一次上传
+	chunked := e.config.ChunkSize > 0 && fileInfo.FileSize > e.config.ChunkSize && upload == nil
+	if !chunked {
+		state.ResetChunks(fileInfo.FileSize, fileInfo.FileSize)
+	} else if !state.MatchesChunks(fileInfo.FileSize, e.config.ChunkSize) {
+		// 源文件在两次执行之间发生了变化，或者ChunkSize被修改，已传输的分块不再可用
+		state.ResetChunks(fileInfo.FileSize, e.config.ChunkSize)
+	}
+	state.Status = models.TransferStatusRunning
+	if err := e.saveState(state); err != nil {
+		e.logger.Warnf("Failed to save transfer state of %s: %v", fileID, err)
+	}
+
+	run.mu.Lock()
+	run.states[fileID] = state
+	run.mu.Unlock()
+
+	targetFileID, err := e.copyAndVerify(ctx, run, fileInfo, state, chunked, upload)
+	if err != nil {
+		if errors.Is(err, errVerificationMismatch) {
+			e.recordQuarantine(run, state, targetFileID, err)
+			return "", err
+		}
+		if ctx.Err() != nil {
+			// 被暂停或取消的文件保留为paused，下次执行时重新复制或从未完成的分块继续
+			state.Status = models.TransferStatusPaused
+			if saveErr := e.saveState(state); saveErr != nil {
+				e.logger.Warnf("Failed to save transfer state of %s: %v", fileID, saveErr)
+			}
+			return "", err
+		}
+		e.recordFailure(run, file, state, err)
+		return "", err
+	}
+
+	mapping := &models.FileMapping{
+		MigrationID:  run.migration.ID,
+		SourceFileID: fileID,
+		TargetFileID: targetFileID,
+		FileSize:     fileInfo.FileSize,
+		CRC32:        fileInfo.CRC32,
+	}
+	if err := e.repo.FileMapping().Save(mapping); err != nil {
+		// 没有映射时应用无法转换旧文件ID，按失败处理，下次执行时重新复制
+		e.recordFailure(run, file, state, fmt.Errorf("failed to save file mapping: %w", err))
+		return "", err
+	}
+
+	for _, chunk := range state.ChunkStates {
+		state.UpdateChunkState(chunk.Index, true, "")
+	}
+	state.TransferredSize = fileInfo.FileSize
+	state.TargetFileID = targetFileID
+	state.Status = models.TransferStatusCompleted
+	if !fastdfs.IsAppenderFile(fileID) {
+		state.Checksum = fmt.Sprintf("%08x", fileInfo.CRC32)
+	}
+	if err := e.saveState(state); err != nil {
+		e.logger.Warnf("Failed to save transfer state of %s: %v", fileID, err)
+	}
+
+	run.mu.Lock()
+	run.processedFiles++
+	run.processedSize += fileInfo.FileSize
+	run.mu.Unlock()
+	e.reportProgress(run, false)
+
+	e.logger.Debugf("Migration %s: copied %s to %s", run.migration.ID, fileID, targetFileID)
+	return targetFileID, nil
+}
+
+// copyAndVerify 复制文件，启用校验时校验目标副本，返回目标文件ID
+// 只有下载和上传步骤按RetryConfig重试，上传成功后元数据复制和校验单独重试，不会重复上传；
+// 之后的步骤失败时删除已上传的副本。
+// 校验不一致时删除副本并重新复制，重新复制RetryConfig.MaxRetries次后仍不一致时返回不一致的副本和errVerificationMismatch
+func (e *MigrationEngine) copyAndVerify(ctx context.Context, run *migrationRun, fileInfo *fastdfs.FileInfo, state *models.TransferState, chunked bool, upload uploadFunc) (string, error) {
+	config := &run.migration.Config
+	fileID := fileInfo.GetFileID()
+	if upload == nil {
+		upload = defaultUploadFunc(run.target, fileInfo)
+	}
+
+	for attempt := 0; ; attempt++ {
+		var targetFileID string
+		err := withRetry(ctx, run.retry, func() error {
+			var err error
+			if chunked {
+				targetFileID, err = e.service.transferChunked(ctx, run.source, run.target, fileInfo, state, e.saveState)
+			} else {
+				targetFileID, err = copyFileContent(ctx, run.source, fileInfo, upload)
+			}
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if config.PreserveMetadata {
+			err := withRetry(ctx, run.retry, func() error {
+				return copyMetadata(ctx, run.source, run.target, fileID, targetFileID)
+			})
+			if err != nil {
+				e.discardCopy(run, state, chunked, targetFileID)
+				return "", err
+			}
+		}
+		if !config.VerificationEnabled {
+			return targetFileID, nil
+		}
+
+		var result *VerificationResult
+		err = withRetry(ctx, run.retry, func() error {
+			var err error
+			result, err = verifyCopy(ctx, run.source, run.target, fileID, targetFileID, config.FullVerification)
+			return err
+		})
+		if err != nil {
+			e.discardCopy(run, state, chunked, targetFileID)
+			return "", fmt.Errorf("failed to verify copy %s: %w", targetFileID, err)
+		}
+		if result.Matched() {
+			return targetFileID, nil
+		}
+
+		details := result.Details()
+		details["attempt"] = attempt + 1
+		if attempt >= run.retry.MaxRetries {
+			e.log(run.migration.ID, models.LogLevelError, "File quarantined after verification mismatch", details)
+			return targetFileID, fmt.Errorf("copy %s of %s does not match source (%s): %w", targetFileID, fileID, result.Mismatch, errVerificationMismatch)
+		}
+		e.log(run.migration.ID, models.LogLevelWarn, "Verification mismatch, copying the file again", details)
+
+		// 删除不一致的副本后重新复制，分块传输从第一个分块开始
+		e.discardCopy(run, state, chunked, targetFileID)
+		if err := sleepContext(ctx, retryDelay(run.retry, attempt)); err != nil {
+			return "", err
+		}
+	}
+}
+
+// discardCopy 删除不再使用的目标副本，分块传输的状态同时重置，下次从第一个分块开始
+// ctx可能已被取消，因此删除不受ctx控制
+func (e *MigrationEngine) discardCopy(run *migrationRun, state *models.TransferState, chunked bool, targetFileID string) {
+	if err := run.target.DeleteFile(targetFileID); err != nil {
+		e.logger.Warnf("Failed to delete target file %s: %v", targetFileID, err)
+	}
+	if chunked {
+		state.TargetFileID = ""
+		state.ResetChunks(state.TotalSize, state.ChunkSize)
+		if err := e.saveState(state); err != nil {
+			e.logger.Warnf("Failed to save transfer state of %s: %v", state.FileID, err)
+		}
+	}
+}
+
+// recordQuarantine 记录多次校验不一致的文件，保留最后一次的副本供人工检查，不写入文件ID映射
+func (e *MigrationEngine) recordQuarantine(run *migrationRun, state *models.TransferState, targetFileID string, err error) {
+	e.logger.Errorf("Migration %s: %v", run.migration.ID, err)
+
+	state.Status = models.TransferStatusQuarantined
+	state.TargetFileID = targetFileID
+	if saveErr := e.saveState(state); saveErr != nil {
+		e.logger.Warnf("Failed to save transfer state of %s: %v", state.FileID, saveErr)
+	}
+
+	run.mu.Lock()
+	run.failedFiles++
+	run.quarantinedFiles++
+	run.mu.Unlock()
+	e.reportProgress(run, false)
+}
+
+// recordSkipped 记录无需复制的文件，size为之前已复制的字节数
+func (e *MigrationEngine) recordSkipped(run *migrationRun, size int64) {
+	run.mu.Lock()
+	run.skippedFiles++
+	run.processedSize += size
+	run.mu.Unlock()
+	e.reportProgress(run, false)
+}
+
+// recordFiltered 记录被过滤规则排除的文件，计入跳过的文件数
+func (e *MigrationEngine) recordFiltered(run *migrationRun, fileID string, rule string) {
+	e.logger.Debugf("Migration %s: %s rejected by filter rule %s", run.migration.ID, fileID, rule)
+
+	run.mu.Lock()
+	if run.filteredFiles == nil {
+		run.filteredFiles = make(map[string]int64)
+	}
+	run.filteredFiles[rule]++
+	run.skippedFiles++
+	run.mu.Unlock()
+	e.reportProgress(run, false)
+}
+
+// recordFailure 记录复制失败的文件，state为nil时不更新传输状态
+func (e *MigrationEngine) recordFailure(run *migrationRun, file *fastdfs.FileInfo, state *models.TransferState, err error) {
+	fileID := file.GetFileID()
+	e.logger.Errorf("Migration %s: failed to copy %s: %v", run.migration.ID, fileID, err)
+	e.log(run.migration.ID, models.LogLevelError, "Failed to migrate file", models.LogDetails{
+		"file_id": fileID,
+		"error":   err.Error(),
+	})
+
+	if state != nil {
+		state.Status = models.TransferStatusFailed
+		if saveErr := e.saveState(state); saveErr != nil {
+			e.logger.Warnf("Failed to save transfer state of %s: %v", fileID, saveErr)
+		}
+	}
+
+	run.mu.Lock()
+	run.failedFiles++
+	run.mu.Unlock()
+	e.reportProgress(run, false)
+}
+
+// reportProgress 将迁移进度写入数据库，force为false时按progressInterval限制写入频率
+func (e *MigrationEngine) reportProgress(run *migrationRun, force bool) {
+	run.mu.Lock()
+	if !force && time.Since(run.lastProgress) < progressInterval {
+		run.mu.Unlock()
+		return
+	}
+	run.lastProgress = time.Now()
+	progress := run.progress()
+	processedFiles := run.processedFiles + run.skippedFiles
+	processedSize := run.processedSize
+	run.mu.Unlock()
+
+	if err := e.repo.Migration().UpdateProgress(run.migration.ID, progress, processedFiles, processedSize); err != nil {
+		e.logger.Warnf("Failed to update progress of migration %s: %v", run.migration.ID, err)
+	}
+}
+
+// finish 写入迁移结果并将任务切换到最终状态
+func (e *MigrationEngine) finish(run *migrationRun, status string, errorMessage string) error {
+	migration, err := e.repo.Migration().GetByID(run.migration.ID)
+	if err != nil {
+		return fmt.Errorf("failed to reload migration: %w", err)
+	}
+
+	run.mu.Lock()
+	migration.TotalFiles = run.totalFiles
+	migration.TotalSize = run.totalSize
+	migration.ProcessedFiles = run.processedFiles + run.skippedFiles
+	migration.ProcessedSize = run.processedSize
+	migration.Progress = run.progress()
+	run.mu.Unlock()
+
+	migration.ErrorMessage = errorMessage
+	if status == models.MigrationStatusCompleted {
+		now := time.Now()
+		migration.CompletedAt = &now
+	}
+
+	if err := e.repo.Migration().Update(migration); err != nil {
+		return fmt.Errorf("failed to save migration result: %w", err)
+	}
+	if err := e.repo.Migration().UpdateStatus(migration.ID, status); err != nil {
+		return fmt.Errorf("failed to update migration status: %w", err)
+	}
+	return nil
+}
+
+// progress 计算已处理文件的百分比，调用方须持有run.mu
+func (run *migrationRun) progress() float64 {
+	if run.totalFiles == 0 {
+		return 0
+	}
+	handled := run.processedFiles + run.skippedFiles + run.failedFiles
+	return float64(handled) / float64(run.totalFiles) * 100
+}
+
+// openEnumerator 按迁移任务的文件来源创建枚举器
+func (e *MigrationEngine) openEnumerator(migration *models.Migration) (fastdfs.FileEnumerator, error) {
+	source := migration.Config.FileSource
+	if source == nil {
+		return nil, fmt.Errorf("file source is required")
+	}
+
+	switch source.Type {
+	case models.FileSourceFileIDs:
+		return fastdfs.NewFileIDEnumerator(source.FileIDs), nil
+	case models.FileSourceBinlog:
+		return fastdfs.NewBinlogEnumerator(fastdfs.BinlogEnumeratorConfig{
+			GroupName:    source.GroupName,
+			Dir:          source.Path,
+			PositionFile: source.PositionFile,
+		})
+	case models.FileSourceDataDir:
+		return fastdfs.NewDataDirEnumerator(fastdfs.DataDirConfig{
+			GroupName:      source.GroupName,
+			StorePath:      source.Path,
+			StorePathIndex: source.StorePathIndex,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported file source type: %s", source.Type)
+	}
+}
+
+// retryConfig 获取迁移任务的重试配置，未配置时使用引擎的默认值
+func (e *MigrationEngine) retryConfig(config *models.MigrationConfig) models.RetryConfig {
+	if config.RetryConfig != nil {
+		return *config.RetryConfig
+	}
+	return models.RetryConfig{
+		MaxRetries:    e.config.MaxRetry,
+		RetryInterval: e.config.RetryInterval,
+	}
+}
+
+// saveState 创建或更新传输状态
+func (e *MigrationEngine) saveState(state *models.TransferState) error {
+	if state.ID == "" {
+		return e.repo.TransferState().Create(state)
+	}
+	return e.repo.TransferState().Update(state)
+}
+
+// log 写入任务日志，写入失败时只记录到服务日志
+func (e *MigrationEngine) log(migrationID string, level string, message string, details models.LogDetails) {
+	taskLog := &models.TaskLog{
+		TaskID:   migrationID,
+		TaskType: models.TaskTypeMigration,
+		Level:    level,
+		Message:  message,
+		Details:  details,
+	}
+	if err := e.repo.TaskLog().Create(taskLog); err != nil {
+		e.logger.Warnf("Failed to write task log of migration %s: %v", migrationID, err)
+	}
+}
+
+// withRetry 执行op，遇到可重试的错误时按retry配置重试
+func withRetry(ctx context.Context, retry models.RetryConfig, op func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= retry.MaxRetries || !fastdfs.IsRetryable(err) {
+			return err
+		}
+
+		if sleepContext(ctx, retryDelay(retry, attempt)) != nil {
+			return err
+		}
+	}
+}
+
+// retryDelay 计算第attempt次（从0开始）失败后的等待时间，BackoffFactor大于1时按指数退避
+func retryDelay(retry models.RetryConfig, attempt int) time.Duration {
+	delay := retry.RetryInterval
+	for i := 0; i < attempt && retry.BackoffFactor > 1; i++ {
+		delay = time.Duration(float64(delay) * retry.BackoffFactor)
+	}
+	return delay
+}
+
+// sleepContext 等待d，ctx被取消时提前返回ctx的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fastdfs-migration-system/internal/config"
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/fastdfs/fdfstest"
+	"fastdfs-migration-system/internal/models"
+	"fastdfs-migration-system/internal/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRepository 创建使用临时SQLite数据库的仓库
+func newTestRepository(t *testing.T) repository.Repository {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migration.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("Failed to get sql.DB: %v", err)
+	}
+	// SQLite不支持并发写入
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&models.Migration{}, &models.TaskLog{}, &models.TransferState{}, &models.FileMapping{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	return repository.NewRepository(db)
+}
+
+// newEngineTest 创建迁移引擎及其使用的仓库、源集群和目标集群
+func newEngineTest(t *testing.T) (*MigrationEngine, repository.Repository, *fdfstest.Cluster, *fdfstest.Cluster) {
+	s, source, target := newTransferTestService(t)
+	repo := newTestRepository(t)
+	s.repo = repo
+
+	engine := NewMigrationEngine(s, repo, config.MigrationConfig{DefaultWorkers: 2}, s.logger)
+	return engine, repo, source, target
+}
+
+// createTestMigration 创建迁移源集群中fileIDs的迁移任务
+func createTestMigration(t *testing.T, repo repository.Repository, fileIDs []string, migrationConfig models.MigrationConfig) *models.Migration {
+	migrationConfig.FileSource = &models.FileSource{Type: models.FileSourceFileIDs, FileIDs: fileIDs}
+	if migrationConfig.RetryConfig == nil {
+		migrationConfig.RetryConfig = &models.RetryConfig{MaxRetries: 3, RetryInterval: time.Millisecond}
+	}
+
+	migration := &models.Migration{
+		Name:            "test",
+		SourceClusterID: "source",
+		TargetClusterID: "target",
+		Status:          models.MigrationStatusPending,
+		Config:          migrationConfig,
+	}
+	if err := repo.Migration().Create(migration); err != nil {
+		t.Fatalf("Failed to create migration: %v", err)
+	}
+	return migration
+}
+
+// seedFiles 在源集群中写入count个文件
+func seedFiles(t *testing.T, source *fdfstest.Cluster, count int) ([]string, int64) {
+	var fileIDs []string
+	var size int64
+	for i := 0; i < count; i++ {
+		data := []byte(fmt.Sprintf("file content %d", i))
+		fileID, err := source.PutFile("group1", "txt", data, map[string]string{"index": fmt.Sprint(i)})
+		if err != nil {
+			t.Fatalf("Failed to seed source file: %v", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+		size += int64(len(data))
+	}
+	return fileIDs, size
+}
+
+func TestMigrationEngine_Run(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+	fileIDs, size := seedFiles(t, source, 6)
+
+	// 目标集群繁忙两次，重试后成功
+	scenario, err := fdfstest.ParseScenario("storage upload status status=16 times=2")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	target.SetScenario(scenario)
+
+	migration := createTestMigration(t, repo, fileIDs, models.MigrationConfig{ConcurrentWorkers: 3, PreserveMetadata: true})
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected run error: %v", err)
+	}
+
+	result, err := repo.Migration().GetByID(migration.ID)
+	if err != nil {
+		t.Fatalf("Failed to reload migration: %v", err)
+	}
+	if result.Status != models.MigrationStatusCompleted || result.CompletedAt == nil {
+		t.Fatalf("Expected completed migration, got %s (%s)", result.Status, result.ErrorMessage)
+	}
+	if result.TotalFiles != 6 || result.ProcessedFiles != 6 || result.ProcessedSize != size || result.Progress != 100 {
+		t.Errorf("Unexpected migration result: %+v", result)
+	}
+
+	states, err := repo.TransferState().GetByTaskID(migration.ID)
+	if err != nil || len(states) != 6 {
+		t.Fatalf("Expected 6 transfer states, got %d (%v)", len(states), err)
+	}
+	for _, state := range states {
+		if !state.IsCompleted() || state.TransferredSize != state.TotalSize || state.Checksum == "" {
+			t.Errorf("Unexpected transfer state: %+v", state)
+		}
+	}
+
+	copied := target.FileIDs("group1")
+	if len(copied) != 6 {
+		t.Fatalf("Expected 6 files in target cluster, got %d", len(copied))
+	}
+	if metadata := target.Metadata(copied[0]); metadata["index"] == "" {
+		t.Errorf("Expected metadata to be copied, got %v", metadata)
+	}
+
+	targetFileIDs, err := NewFileMappingService(repo).TranslateFileIDs(fileIDs)
+	if err != nil || len(targetFileIDs) != 6 {
+		t.Fatalf("Expected 6 file mappings, got %v (%v)", targetFileIDs, err)
+	}
+	for i, fileID := range fileIDs {
+		data, exists := target.File(targetFileIDs[fileID])
+		if !exists || string(data) != fmt.Sprintf("file content %d", i) {
+			t.Errorf("Expected %s to map to a copy of it, got %s", fileID, targetFileIDs[fileID])
+		}
+	}
+
+	if err := engine.Run(context.Background(), migration.ID); err == nil {
+		t.Error("Expected error when running a completed migration")
+	}
+}
+
+func TestMigrationEngine_FailureAndResume(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+	fileIDs, _ := seedFiles(t, source, 4)
+	deleted, err := source.PutFile("group1", "txt", []byte("deleted"), nil)
+	if err != nil {
+		t.Fatalf("Failed to seed source file: %v", err)
+	}
+	client, err := source.Client()
+	if err != nil {
+		t.Fatalf("Failed to connect to source: %v", err)
+	}
+	defer client.Close()
+	if err := client.DeleteFile(deleted); err != nil {
+		t.Fatalf("Failed to delete source file: %v", err)
+	}
+
+	// 空间不足不可重试，该文件失败
+	scenario, err := fdfstest.ParseScenario("storage upload status status=28 times=1")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	target.SetScenario(scenario)
+
+	migration := createTestMigration(t, repo, append(fileIDs, deleted), models.MigrationConfig{ConcurrentWorkers: 1})
+	if err := engine.Run(context.Background(), migration.ID); err == nil {
+		t.Fatal("Expected run to fail")
+	}
+
+	result, _ := repo.Migration().GetByID(migration.ID)
+	if result.Status != models.MigrationStatusFailed || result.ErrorMessage != "1 of 5 files failed" {
+		t.Fatalf("Expected failed migration, got %s (%s)", result.Status, result.ErrorMessage)
+	}
+	if result.ProcessedFiles != 4 {
+		t.Errorf("Expected 3 copied and 1 missing file to be processed, got %d", result.ProcessedFiles)
+	}
+
+	logs, err := repo.TaskLog().GetByTaskID(migration.ID, &models.Pagination{Page: 1, PageSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to load task logs: %v", err)
+	}
+	var errorLogs int
+	for _, log := range logs {
+		if log.IsError() && log.Message == "Failed to migrate file" {
+			errorLogs++
+		}
+	}
+	if errorLogs != 1 {
+		t.Errorf("Expected 1 file error log, got %d", errorLogs)
+	}
+
+	// 再次执行时只复制失败的文件
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected error on resume: %v", err)
+	}
+	result, _ = repo.Migration().GetByID(migration.ID)
+	if result.Status != models.MigrationStatusCompleted || result.ProcessedFiles != 5 {
+		t.Errorf("Expected completed migration, got %+v", result)
+	}
+	if copied := target.FileIDs("group1"); len(copied) != 4 {
+		t.Errorf("Expected 4 files in target cluster without duplicates, got %d", len(copied))
+	}
+}
+
+func TestMigrationEngine_RetryAfterUpload(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+	fileIDs, _ := seedFiles(t, source, 1)
+
+	// 上传成功后设置元数据时连接中断，重试只重新设置元数据，不会再次上传
+	scenario, err := fdfstest.ParseScenario("storage set_metadata reset times=1")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	target.SetScenario(scenario)
+
+	migration := createTestMigration(t, repo, fileIDs, models.MigrationConfig{PreserveMetadata: true})
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected run error: %v", err)
+	}
+
+	copied := target.FileIDs("group1")
+	if len(copied) != 1 {
+		t.Fatalf("Expected a single copy without orphaned uploads, got %v", copied)
+	}
+	if metadata := target.Metadata(copied[0]); metadata["index"] != "0" {
+		t.Errorf("Expected metadata to be copied, got %v", metadata)
+	}
+	if mapping, err := repo.FileMapping().Get(migration.ID, fileIDs[0]); err != nil || mapping.TargetFileID != copied[0] {
+		t.Errorf("Expected mapping to %s, got %+v (%v)", copied[0], mapping, err)
+	}
+
+	// 校验失败时删除已上传的副本
+	scenario, err = fdfstest.ParseScenario("storage file_info status status=2")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	target.SetScenario(scenario)
+
+	fileIDs, _ = seedFiles(t, source, 1)
+	migration = createTestMigration(t, repo, fileIDs, models.MigrationConfig{VerificationEnabled: true})
+	if err := engine.Run(context.Background(), migration.ID); err == nil {
+		t.Fatal("Expected run to fail")
+	}
+	if copied := target.FileIDs("group1"); len(copied) != 1 {
+		t.Errorf("Expected the unverified copy to be deleted, got %v", copied)
+	}
+}
+
+func TestMigrationEngine_MasterSlaveMode(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+
+	client, err := source.Client()
+	if err != nil {
+		t.Fatalf("Failed to connect to source: %v", err)
+	}
+	defer client.Close()
+
+	masterID, err := client.UploadFile("group1", "photo.jpg", []byte("master"))
+	if err != nil {
+		t.Fatalf("Failed to seed master file: %v", err)
+	}
+	slaveID, err := client.UploadSlaveFile(masterID, "_small", "jpg", []byte("thumb"))
+	if err != nil {
+		t.Fatalf("Failed to seed slave file: %v", err)
+	}
+
+	migration := createTestMigration(t, repo, []string{slaveID, masterID}, models.MigrationConfig{MasterSlaveMode: true})
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected run error: %v", err)
+	}
+
+	copied := target.FileIDs("group1")
+	if len(copied) != 2 {
+		t.Fatalf("Expected 2 files in target cluster, got %v", copied)
+	}
+	groups := fastdfs.GroupMasterSlaveFiles(copied)
+	if len(groups) != 1 || groups[0].MasterFileID == "" || len(groups[0].SlaveFileIDs) != 1 {
+		t.Errorf("Expected target files to keep the master/slave relation, got %v", copied)
+	}
+}
+
+func TestMigrationEngine_Cancel(t *testing.T) {
+	engine, repo, source, _ := newEngineTest(t)
+	fileIDs, _ := seedFiles(t, source, 3)
+
+	// 下载被延迟，保证取消时任务仍在执行
+	scenario, err := fdfstest.ParseScenario("storage download delay delay=200ms")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	source.SetScenario(scenario)
+
+	migration := createTestMigration(t, repo, fileIDs, models.MigrationConfig{ConcurrentWorkers: 1})
+	if err := engine.Start(migration.ID); err != nil {
+		t.Fatalf("Unexpected start error: %v", err)
+	}
+	if err := engine.Start(migration.ID); err == nil {
+		t.Error("Expected error when starting a running migration")
+	}
+	if err := engine.Cancel(migration.ID); err != nil {
+		t.Fatalf("Unexpected cancel error: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for engine.IsRunning(migration.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("Migration did not stop after cancel")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	result, _ := repo.Migration().GetByID(migration.ID)
+	if result.Status != models.MigrationStatusCancelled {
+		t.Errorf("Expected cancelled migration, got %s", result.Status)
+	}
+}
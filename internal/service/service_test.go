@@ -40,9 +40,23 @@ func TestServiceInterface(t *testing.T) {
 		_ = service.GetClusterClient
 		_ = service.ListFiles
 		_ = service.DownloadFile
+		_ = service.DownloadTo
+		_ = service.DownloadRange
 		_ = service.UploadFile
+		_ = service.UploadFrom
 		_ = service.DeleteFile
 		_ = service.GetFileInfo
+		_ = service.GetMetadata
+		_ = service.SetMetadata
+		_ = service.GetClusterTopology
+		_ = service.DownloadFileContext
+		_ = service.UploadFileContext
+		_ = service.DeleteFileContext
+		_ = service.GetFileInfoContext
+		_ = service.GetMetadataContext
+		_ = service.SetMetadataContext
+		_ = service.TransferFile
+		_ = service.TransferFileGroup
 		_ = service.HealthCheck
 		_ = service.GetConnectionStats
 		_ = service.StartHealthCheckRoutine
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"fastdfs-migration-system/internal/fastdfs"
+	"fastdfs-migration-system/internal/models"
+)
+
+// VerificationResult 源文件与目标文件的校验结果
+type VerificationResult struct {
+	SourceFileID string
+	TargetFileID string
+	SourceSize   int64
+	TargetSize   int64
+	SourceCRC32  uint32
+	TargetCRC32  uint32
+	SourceHash   string // 完整校验时源文件内容的SHA-256
+	TargetHash   string // 完整校验时目标文件内容的SHA-256
+	Mismatch     string // 不一致的项：size、crc32或hash，一致时为空
+}
+
+// Matched 检查目标文件是否与源文件一致
+func (r *VerificationResult) Matched() bool {
+	return r.Mismatch == ""
+}
+
+// Details 转换为任务日志详情
+func (r *VerificationResult) Details() models.LogDetails {
+	details := models.LogDetails{
+		"source_file_id": r.SourceFileID,
+		"target_file_id": r.TargetFileID,
+		"source_size":    r.SourceSize,
+		"target_size":    r.TargetSize,
+		"source_crc32":   fmt.Sprintf("%08x", r.SourceCRC32),
+		"target_crc32":   fmt.Sprintf("%08x", r.TargetCRC32),
+		"mismatch":       r.Mismatch,
+	}
+	if r.SourceHash != "" || r.TargetHash != "" {
+		details["source_sha256"] = r.SourceHash
+		details["target_sha256"] = r.TargetHash
+	}
+	return details
+}
+
+// VerifyTransfer 校验目标集群中的文件是否与源文件一致
+// 比较两个集群QUERY_FILE_INFO返回的大小和CRC32，fullHash为true时再下载两个文件比较SHA-256
+func (s *FastDFSService) VerifyTransfer(ctx context.Context, sourceClusterID string, targetClusterID string, sourceFileID string, targetFileID string, fullHash bool) (*VerificationResult, error) {
+	source, target, err := s.getTransferClients(sourceClusterID, targetClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyCopy(ctx, source, target, sourceFileID, targetFileID, fullHash)
+}
+
+// verifyCopy 校验目标文件，查询或下载失败时返回错误，内容不一致时通过结果的Mismatch返回
+func verifyCopy(ctx context.Context, source, target *fastdfs.PooledClient, sourceFileID string, targetFileID string, fullHash bool) (*VerificationResult, error) {
+	sourceInfo, err := source.GetFileInfoContext(ctx, sourceFileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source file info: %w", err)
+	}
+
+	targetInfo, err := target.GetFileInfoContext(ctx, targetFileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target file info: %w", err)
+	}
+
+	result := &VerificationResult{
+		SourceFileID: sourceFileID,
+		TargetFileID: targetFileID,
+		SourceSize:   sourceInfo.FileSize,
+		TargetSize:   targetInfo.FileSize,
+		SourceCRC32:  sourceInfo.CRC32,
+		TargetCRC32:  targetInfo.CRC32,
+	}
+
+	// 旧版本存储服务器查询appender文件时CRC32为0，此时只能依靠大小和完整校验
+	switch {
+	case result.SourceSize != result.TargetSize:
+		result.Mismatch = "size"
+		return result, nil
+	case result.SourceCRC32 != 0 && result.TargetCRC32 != 0 && result.SourceCRC32 != result.TargetCRC32:
+		result.Mismatch = "crc32"
+		return result, nil
+	}
+
+	if !fullHash {
+		return result, nil
+	}
+
+	if result.SourceHash, err = hashFile(ctx, source, sourceFileID); err != nil {
+		return nil, fmt.Errorf("failed to hash source file: %w", err)
+	}
+	if result.TargetHash, err = hashFile(ctx, target, targetFileID); err != nil {
+		return nil, fmt.Errorf("failed to hash target file: %w", err)
+	}
+	if result.SourceHash != result.TargetHash {
+		result.Mismatch = "hash"
+	}
+	return result, nil
+}
+
+// hashFile 以流的方式下载文件并计算SHA-256
+func hashFile(ctx context.Context, client *fastdfs.PooledClient, fileID string) (string, error) {
+	hash := sha256.New()
+	if _, err := client.DownloadTo(ctx, fileID, hash); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"fastdfs-migration-system/internal/fastdfs/fdfstest"
+	"fastdfs-migration-system/internal/models"
+	"fastdfs-migration-system/internal/repository"
+)
+
+func TestVerifyTransfer(t *testing.T) {
+	s, source, target := newTransferTestService(t)
+
+	sourceFileID, err := source.PutFile("group1", "txt", []byte("original"), nil)
+	if err != nil {
+		t.Fatalf("Failed to seed source file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		mismatch string
+	}{
+		{"identical", "original", ""},
+		{"different content", "0riginal", "crc32"},
+		{"different size", "original copy", "size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetFileID, err := target.PutFile("group1", "txt", []byte(tt.data), nil)
+			if err != nil {
+				t.Fatalf("Failed to seed target file: %v", err)
+			}
+
+			result, err := s.VerifyTransfer(context.Background(), "source", "target", sourceFileID, targetFileID, true)
+			if err != nil {
+				t.Fatalf("Unexpected verify error: %v", err)
+			}
+			if result.Mismatch != tt.mismatch {
+				t.Errorf("Expected mismatch %q, got %q", tt.mismatch, result.Mismatch)
+			}
+			if tt.mismatch == "" && (result.SourceHash == "" || result.SourceHash != result.TargetHash) {
+				t.Errorf("Expected matching content hashes, got %+v", result)
+			}
+		})
+	}
+
+	if _, err := s.VerifyTransfer(context.Background(), "source", "target", sourceFileID, "group1/M00/00/00/missing.txt", false); err == nil {
+		t.Error("Expected error when the target file does not exist")
+	}
+}
+
+func TestMigrationEngine_VerificationMismatch(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+	fileIDs, _ := seedFiles(t, source, 1)
+
+	// 第一次上传的内容被破坏，校验不一致后重新复制
+	scenario, err := fdfstest.ParseScenario("storage upload corrupt times=1")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	target.SetScenario(scenario)
+
+	migration := createTestMigration(t, repo, fileIDs, models.MigrationConfig{VerificationEnabled: true, FullVerification: true})
+	if err := engine.Run(context.Background(), migration.ID); err != nil {
+		t.Fatalf("Unexpected run error: %v", err)
+	}
+
+	copied := target.FileIDs("group1")
+	if len(copied) != 1 {
+		t.Fatalf("Expected the mismatched copy to be replaced, got %v", copied)
+	}
+	if data, _ := target.File(copied[0]); string(data) != "file content 0" {
+		t.Errorf("Expected an intact copy, got %q", data)
+	}
+
+	if warnings := countTaskLogs(t, repo, migration.ID, "Verification mismatch, copying the file again"); warnings != 1 {
+		t.Errorf("Expected 1 mismatch warning, got %d", warnings)
+	}
+}
+
+func TestMigrationEngine_VerificationQuarantine(t *testing.T) {
+	engine, repo, source, target := newEngineTest(t)
+	fileIDs, _ := seedFiles(t, source, 1)
+
+	scenario, err := fdfstest.ParseScenario("storage upload corrupt")
+	if err != nil {
+		t.Fatalf("Failed to parse scenario: %v", err)
+	}
+	target.SetScenario(scenario)
+
+	migration := createTestMigration(t, repo, fileIDs, models.MigrationConfig{VerificationEnabled: true})
+	migration.Config.RetryConfig.MaxRetries = 1
+	if err := repo.Migration().Update(migration); err != nil {
+		t.Fatalf("Failed to update migration: %v", err)
+	}
+	if err := engine.Run(context.Background(), migration.ID); err == nil {
+		t.Fatal("Expected run to fail")
+	}
+
+	result, _ := repo.Migration().GetByID(migration.ID)
+	if result.Status != models.MigrationStatusFailed || result.ErrorMessage != "1 of 1 files failed" {
+		t.Errorf("Expected failed migration, got %s (%s)", result.Status, result.ErrorMessage)
+	}
+
+	states, err := repo.TransferState().GetByTaskID(migration.ID)
+	if err != nil || len(states) != 1 {
+		t.Fatalf("Expected 1 transfer state, got %d (%v)", len(states), err)
+	}
+	state := states[0]
+	if !state.IsQuarantined() || state.TargetFileID == "" {
+		t.Errorf("Expected quarantined state keeping the copy, got %+v", state)
+	}
+	if _, exists := target.File(state.TargetFileID); !exists {
+		t.Errorf("Expected quarantined copy %s to be kept", state.TargetFileID)
+	}
+	if copied := target.FileIDs("group1"); len(copied) != 1 {
+		t.Errorf("Expected earlier mismatched copies to be deleted, got %v", copied)
+	}
+	if mappings, _ := NewFileMappingService(repo).TranslateFileIDs(fileIDs); len(mappings) != 0 {
+		t.Errorf("Expected no file mapping for a quarantined file, got %v", mappings)
+	}
+
+	if warnings := countTaskLogs(t, repo, migration.ID, "Verification mismatch, copying the file again"); warnings != 1 {
+		t.Errorf("Expected 1 mismatch warning, got %d", warnings)
+	}
+	if quarantined := countTaskLogs(t, repo, migration.ID, "File quarantined after verification mismatch"); quarantined != 1 {
+		t.Errorf("Expected 1 quarantine error, got %d", quarantined)
+	}
+}
+
+// countTaskLogs 统计迁移任务中消息为message的日志条数
+func countTaskLogs(t *testing.T, repo repository.Repository, migrationID string, message string) int {
+	logs, err := repo.TaskLog().GetByTaskID(migrationID, &models.Pagination{Page: 1, PageSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to load task logs: %v", err)
+	}
+
+	count := 0
+	for _, log := range logs {
+		if log.Message == message {
+			count++
+		}
+	}
+	return count
+}